@@ -9,11 +9,13 @@ import (
 
 	"github.com/chrsoo/cloud-config-operator/pkg/apis"
 	"github.com/chrsoo/cloud-config-operator/pkg/controller"
+	"github.com/chrsoo/cloud-config-operator/pkg/webhook"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
 	"github.com/operator-framework/operator-sdk/pkg/ready"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -28,6 +30,27 @@ func printVersion() {
 	log.Info(fmt.Sprintf("operator-sdk Version: %v", sdkVersion.Version))
 }
 
+// serveWebhook serves the CRD conversion webhook in the background, required in a cluster as the controllers read the
+// v1alpha1 version of CloudConfigs stored as v1alpha2. Outside of a cluster, e.g. with `operator-sdk up local`, the
+// API server cannot call the webhook of the operator and it is only served if WEBHOOK_CERT_DIR is set.
+func serveWebhook() error {
+	certDir := os.Getenv("WEBHOOK_CERT_DIR")
+	if certDir == "" {
+		if _, err := rest.InClusterConfig(); err == rest.ErrNotInCluster {
+			log.Info("Not serving the CRD conversion webhook outside of a cluster as WEBHOOK_CERT_DIR is not set")
+			return nil
+		}
+		return fmt.Errorf("WEBHOOK_CERT_DIR must be set to the directory of the serving certificate of the CRD conversion webhook")
+	}
+	go func() {
+		if err := webhook.Serve(webhook.DefaultPort, certDir); err != nil {
+			log.Error(err, "webhook server exited")
+			os.Exit(1)
+		}
+	}()
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	// The logger instantiated here can be changed to any logger
@@ -53,9 +76,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Become the leader before proceeding
-	leader.Become(context.TODO(), "cloud-config-operator-lock")
+	// Serve the CRD conversion webhook on every replica and not only on the leader, as the API server converts
+	// CloudConfigs through any ready pod of the webhook service, also while the leader is being replaced
+	if err := serveWebhook(); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
 
+	// Ready as soon as the webhook is served, i.e. before becoming the leader
 	r := ready.NewFileReady()
 	err = r.Set()
 	if err != nil {
@@ -64,6 +92,9 @@ func main() {
 	}
 	defer r.Unset()
 
+	// Become the leader before proceeding
+	leader.Become(context.TODO(), "cloud-config-operator-lock")
+
 	// Create a new Cmd to provide shared dependencies and start components
 	// By default this will be the namespace that the operator is running in. To watch all
 	// namespaces leave the namespace option empty:
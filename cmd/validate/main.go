@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/controller/cloudconfig"
+)
+
+// validate checks rendered spec files offline as the operator does before applying them, e.g. in CI pipelines
+// of a config repository:
+//
+//	validate [--schema=swagger.json] deployment.yaml service.yaml
+//
+// The objects are validated against the bundled schema of the Kubernetes API types the command is built with or,
+// e.g. to include the custom resources of a cluster, an OpenAPI v2 schema retrieved from the cluster with
+// `kubectl get --raw /openapi/v2`. The exit code is 1 if any of the spec files has problems.
+func main() {
+	schemaFile := flag.String("schema", "", "OpenAPI v2 schema file, e.g. retrieved with 'kubectl get --raw /openapi/v2', defaults to the bundled schema")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: validate [--schema=<file>] <spec file>...")
+		os.Exit(2)
+	}
+
+	var schema []byte
+	if *schemaFile != "" {
+		var err error
+		if schema, err = ioutil.ReadFile(*schemaFile); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+	}
+
+	failed := false
+	for _, name := range flag.Args() {
+		spec, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		problems, err := cloudconfig.ValidateSpec(schema, spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, err.Error())
+			os.Exit(2)
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", name, problem)
+		}
+		failed = failed || len(problems) > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+#!/bin/sh
+# Regenerates the deepcopy functions and the CloudConfig CRD from the API types and their +kubebuilder markers. The
+# conversion webhook section of deploy/crds/cloudconfig_crd.yaml is kept as controller-gen does not generate it.
+#
+# Usage: hack/generate-crds.sh
+#
+# controller-gen is installed in bin/ at the version pinned below. It is not vendored by dep as controller-tools
+# requires a newer Kubernetes than the kubernetes-1.12.3 revisions of Gopkg.toml.
+set -e
+
+CONTROLLER_GEN_VERSION=v0.2.5
+CRD=deploy/crds/cloudconfig_crd.yaml
+
+cd "$(dirname "$0")/.."
+CONTROLLER_GEN=$PWD/bin/controller-gen-$CONTROLLER_GEN_VERSION
+if [ ! -x "$CONTROLLER_GEN" ]; then
+  TMP=$(mktemp -d)
+  (cd "$TMP" && go mod init tmp >/dev/null 2>&1 &&
+    GOBIN="$TMP" GO111MODULE=on go get "sigs.k8s.io/controller-tools/cmd/controller-gen@$CONTROLLER_GEN_VERSION")
+  mkdir -p bin
+  mv "$TMP/controller-gen" "$CONTROLLER_GEN"
+  rm -rf "$TMP"
+fi
+
+operator-sdk generate k8s
+
+OUT=$(mktemp -d)
+trap 'rm -rf "$OUT"' EXIT
+"$CONTROLLER_GEN" crd:trivialVersions=false,preserveUnknownFields=false paths=./pkg/apis/... output:crd:dir="$OUT"
+
+# insert the conversion section of the current CRD at the end of the spec of the generated CRD
+awk '/^  conversion:/ { found = 1; print; next } found && /^(  )?[^ ]/ { found = 0 } found' "$CRD" > "$OUT/conversion.yaml"
+awk -v conversion="$OUT/conversion.yaml" '
+  function insert() { while ((getline line < conversion) > 0) print line; inserted = 1 }
+  /^status:/ && !inserted { insert() }
+  { print }
+  END { if (!inserted) insert() }
+' "$OUT/k8s.jabberwocky.se_cloudconfigs.yaml" > "$CRD"
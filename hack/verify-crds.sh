@@ -0,0 +1,30 @@
+#!/bin/sh
+# Verifies that the deepcopy functions and the CloudConfig CRD are up to date with the API types, i.e. that
+# hack/generate-crds.sh does not change them. Fails with the diff of the outdated files, which are left
+# regenerated in the working tree.
+#
+# Usage: hack/verify-crds.sh
+set -e
+
+GENERATED="deploy/crds/cloudconfig_crd.yaml pkg/apis/k8s/v1alpha1/zz_generated.deepcopy.go pkg/apis/k8s/v1alpha2/zz_generated.deepcopy.go"
+
+cd "$(dirname "$0")/.."
+BEFORE=$(mktemp -d)
+trap 'rm -rf "$BEFORE"' EXIT
+for f in $GENERATED; do
+  mkdir -p "$BEFORE/$(dirname "$f")"
+  cp "$f" "$BEFORE/$f"
+done
+
+hack/generate-crds.sh
+
+STATUS=0
+for f in $GENERATED; do
+  if ! diff -u "$BEFORE/$f" "$f"; then
+    STATUS=1
+  fi
+done
+if [ $STATUS -ne 0 ]; then
+  echo "Generated files are out of date, run hack/generate-crds.sh and commit the result" >&2
+fi
+exit $STATUS
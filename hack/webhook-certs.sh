@@ -0,0 +1,29 @@
+#!/bin/sh
+# Creates a self-signed CA and a serving certificate for the conversion webhook of the cloud-config-operator,
+# stores the certificate in the cloud-config-operator-webhook TLS secret and prints the base64 encoded CA bundle
+# that replaces the CA_BUNDLE placeholder of deploy/crds/cloudconfig_crd.yaml.
+#
+# Usage: hack/webhook-certs.sh [namespace]
+#
+# The namespace is the namespace of the operator, 'default' if not given.
+set -e
+
+NAMESPACE=${1:-default}
+SERVICE=cloud-config-operator-webhook
+DIR=$(mktemp -d)
+trap 'rm -rf "$DIR"' EXIT
+
+openssl req -x509 -newkey rsa:2048 -nodes -days 3650 -subj "/CN=cloud-config-operator-webhook-ca" \
+  -keyout "$DIR/ca.key" -out "$DIR/ca.crt" 2>/dev/null
+
+openssl req -newkey rsa:2048 -nodes -subj "/CN=$SERVICE.$NAMESPACE.svc" \
+  -keyout "$DIR/tls.key" -out "$DIR/tls.csr" 2>/dev/null
+printf "subjectAltName=DNS:%s,DNS:%s.%s,DNS:%s.%s.svc\n" "$SERVICE" "$SERVICE" "$NAMESPACE" "$SERVICE" "$NAMESPACE" \
+  > "$DIR/san.ext"
+openssl x509 -req -days 3650 -in "$DIR/tls.csr" -CA "$DIR/ca.crt" -CAkey "$DIR/ca.key" -CAcreateserial \
+  -extfile "$DIR/san.ext" -out "$DIR/tls.crt" 2>/dev/null
+
+kubectl --namespace="$NAMESPACE" create secret tls "$SERVICE" --cert="$DIR/tls.crt" --key="$DIR/tls.key" \
+  --dry-run -o yaml | kubectl apply -f - >&2
+
+base64 < "$DIR/ca.crt" | tr -d '\n'
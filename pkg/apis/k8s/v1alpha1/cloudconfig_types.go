@@ -11,6 +11,7 @@ import (
 // CloudConfigSpec defines the desired state of CloudConfig
 type CloudConfigSpec struct {
 	// Application name, defaults to system name
+	// +kubebuilder:validation:Pattern=^[a-zA-Z0-9._-]*$
 	AppName string `json:"appName,omitempty"`
 
 	// List or profile names
@@ -22,16 +23,106 @@ type CloudConfigSpec struct {
 	// Cloud Config Server name or URL
 	Server string `json:"server,omitempty"`
 
-	// app spec file name, defaults to 'deployment.yaml'
+	// app spec file name, defaults to 'deployment.yaml'; ignored if SpecFiles is set
 	SpecFile string `json:"specFile,omitempty"`
 
+	// SpecFiles lists the app spec file names or glob patterns that are concatenated for each app.
+	// Files are retrieved in the order listed and pattern matches in alphabetical order. Entries
+	// prefixed with `optional:` are skipped if the file does not exist or the pattern does not match.
+	SpecFiles []string `json:"specFiles,omitempty"`
+
+	// SpecManifest is the app config property listing the spec files available for each app,
+	// required for resolving SpecFiles patterns
+	SpecManifest string `json:"specManifest,omitempty"`
+
+	// DependsOn is the app config property listing the apps of the app list each app depends on, optional.
+	// Apps are synchronized in dependency order and held back while any of their dependencies is not healthy.
+	DependsOn string `json:"dependsOn,omitempty"`
+
 	// Application list property name, optional
 	AppList string `json:"appList,omitempty"`
 
-	// Period is the number of seconds between cloud config synchronizations,
+	// Apps overrides the label, profiles and spec files of individual apps, keyed by app name
+	Apps map[string]AppSpec `json:"apps,omitempty"`
+
+	// Environments managed by the CloudConfig keyed by environment name, optional
+	Environments map[string]EnvironmentSpec `json:"environments,omitempty"`
+
+	// TargetNamespace is the namespace where the apps are applied, defaults to the namespace of the CloudConfig.
+	// The target namespace must allow CloudConfigs of the CloudConfig namespace to manage it.
+	// +kubebuilder:validation:Pattern=^[a-z0-9]([-a-z0-9]*[a-z0-9])?$
+	// +kubebuilder:validation:MaxLength=63
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// CreateNamespace creates the target namespace with the given labels and annotations if it does not exist
+	CreateNamespace *NamespaceSpec `json:"createNamespace,omitempty"`
+
+	// CommonLabels are set on all objects of the apps and the pod templates of their workloads, together with the
+	// labels identifying the app, CloudConfig and environment of the objects
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// CommonAnnotations are set on all objects of the apps and the pod templates of their workloads
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+
+	// Suspend stops fetching and applying the apps when true, the status of the last synchronization is kept
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Mode of the synchronization, `Apply` (default) applies the apps and `DryRun` only reports what a
+	// synchronization would change without changing the cluster
+	// +kubebuilder:validation:Enum=Apply;DryRun
+	Mode SyncMode `json:"mode,omitempty"`
+
+	// SyncPolicy of the apps, `Automatic` (default) applies changes as soon as they are found and `Manual` waits
+	// for each revision to be approved with the `k8s.jabberwocky.se/approve` annotation
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	SyncPolicy SyncPolicy `json:"syncPolicy,omitempty"`
+
+	// Rollback re-applies the last good revision of an app when the rollout of a new revision fails, optional
+	Rollback *RollbackSpec `json:"rollback,omitempty"`
+
+	// RevisionHistoryLimit is the number of applied revisions kept in the history of each app, defaults to 10
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit int `json:"revisionHistoryLimit,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount in the namespace of the CloudConfig the operator impersonates when
+	// applying and pruning the objects of the apps, restricting them to what the RBAC of the ServiceAccount allows;
+	// the objects are applied with the permissions of the operator if empty
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Images override the tags or digests of the container images of the workloads of the apps, e.g. for testing a
+	// build without changing the config repository; the overridden images are reported in the status of each app
+	Images []ImageOverride `json:"images,omitempty"`
+
+	// AllowedKinds restricts the objects of the apps to the kinds matching any of the patterns, all kinds are
+	// allowed if empty. Patterns are `<group>/<kind>` globs, e.g. `apps/*` or `/ConfigMap` for the core group; a
+	// pattern without a group, e.g. `ConfigMap`, matches the kind of any group.
+	AllowedKinds []string `json:"allowedKinds,omitempty"`
+
+	// DeniedKinds rejects the objects of the apps of the kinds matching any of the patterns, cf. AllowedKinds
+	DeniedKinds []string `json:"deniedKinds,omitempty"`
+
+	// Policy is the name of a ConfigMap with the policy rules checked for the workloads of the apps before they are
+	// applied, e.g. denying privileged containers; violations fail the apps or are only reported depending on the
+	// enforcement mode of the policy, optional
+	Policy string `json:"policy,omitempty"`
+
+	// PinnedRevision pins the apps to a revision of their history until it is removed; the revision is either
+	// the revision of an app or the config server version, e.g. the commit id, of a revision
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+
+	// Period is the number of seconds between cloud config synchronizations, cannot be combined with Schedule;
 	// a 0 value means that the environment is updated only once after each CloudConfig change
+	// +kubebuilder:validation:Minimum=0
 	Period int `json:"period,omitempty"`
 
+	// Schedule is a cron expression of the cloud config synchronizations, e.g. `*/10 8-17 * * MON-FRI`,
+	// optionally prefixed with the time zone, e.g. `CRON_TZ=Europe/Stockholm 0 8 * * *`
+	Schedule string `json:"schedule,omitempty"`
+
+	// SyncWindows restrict when apps are applied; apps are applied only while no deny window and, if there are
+	// allow windows, an allow window is active
+	SyncWindows []SyncWindow `json:"syncWindows,omitempty"`
+
 	// TrustStore optionally defines the name of a secret containing all trusted certificates
 	TrustStore string `json:"trustStore,omitempty"`
 
@@ -43,6 +134,74 @@ type CloudConfigSpec struct {
 	Insecure bool `json:"insecure,omitempty"`
 }
 
+// SyncMode is the mode of a synchronization
+type SyncMode string
+
+const (
+	// SyncModeApply applies the apps
+	SyncModeApply SyncMode = "Apply"
+	// SyncModeDryRun computes the diff of the apps against the live objects without changing the cluster
+	SyncModeDryRun SyncMode = "DryRun"
+)
+
+// SyncPolicy defines when changes of the apps are applied
+type SyncPolicy string
+
+const (
+	// SyncPolicyAutomatic applies changes of the apps without approval
+	SyncPolicyAutomatic SyncPolicy = "Automatic"
+	// SyncPolicyManual applies a changed revision of an app only after it has been approved
+	SyncPolicyManual SyncPolicy = "Manual"
+)
+
+// SyncWindowKind is the kind of a sync window
+type SyncWindowKind string
+
+const (
+	// SyncWindowAllow windows allow apps to be applied while they are active
+	SyncWindowAllow SyncWindowKind = "allow"
+	// SyncWindowDeny windows prevent apps from being applied while they are active
+	SyncWindowDeny SyncWindowKind = "deny"
+)
+
+// SyncWindow defines a recurring time window when apps are allowed or denied to be applied
+type SyncWindow struct {
+	// Kind of the window, either `allow` or `deny`
+	// +kubebuilder:validation:Enum=allow;deny
+	Kind SyncWindowKind `json:"kind"`
+	// Schedule is the cron expression of the start of the window, e.g. `0 8 * * MON-FRI`
+	Schedule string `json:"schedule"`
+	// Duration of the window, e.g. `9h`
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone of the schedule, e.g. `Europe/Stockholm`, defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ImageOverride replaces the tag or digest of the container images with the given name
+type ImageOverride struct {
+	// Name of the image without tag or digest as given in the spec files, e.g. `registry.example.com/team/alpha`
+	Name string `json:"name"`
+	// Tag replacing the tag or digest of the image, e.g. `1.2.3`
+	Tag string `json:"tag,omitempty"`
+	// Digest replacing the tag or digest of the image, e.g. `sha256:...`, takes precedence over Tag
+	Digest string `json:"digest,omitempty"`
+}
+
+// RollbackSpec defines the automatic rollback of apps whose rollout fails
+type RollbackSpec struct {
+	// ProgressDeadline is the time the objects of a new revision have to become healthy before the rollout
+	// fails, defaults to 10m
+	ProgressDeadline *metav1.Duration `json:"progressDeadline,omitempty"`
+}
+
+// NamespaceSpec defines the labels and annotations of a target namespace created by the operator
+type NamespaceSpec struct {
+	// Labels of the namespace
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations of the namespace
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
 // CloudConfigCredentials contains the metadata used to retrieve a Kubernetes secret containing
 // Cloud Config Server credentials.
 type CloudConfigCredentials struct {
@@ -63,29 +222,214 @@ type CloudConfigCredentials struct {
 	RootCA string `json:"rootCA,omitempty"`
 }
 
+// AppSpec defines the label, profiles and spec files of an individual app. AppSpecs are found
+// as entries in the appList of the AppName configuration or as app overrides in the CloudConfigSpec.
+type AppSpec struct {
+	// Name of the app, required in the appList
+	Name string `json:"name,omitempty"`
+
+	// Label overrides the CloudConfig label for the app, e.g. to pin the app to a release branch
+	Label string `json:"label,omitempty"`
+
+	// Profiles are added to the CloudConfig profiles for the app
+	Profiles []string `json:"profiles,omitempty"`
+
+	// SpecFile overrides the CloudConfig spec file(s) for the app; ignored if SpecFiles is set
+	SpecFile string `json:"specFile,omitempty"`
+
+	// SpecFiles overrides the CloudConfig spec files for the app
+	SpecFiles []string `json:"specFiles,omitempty"`
+
+	// PinnedRevision overrides the CloudConfig pinned revision for the app
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+}
+
+// Merge returns a copy of the AppSpec where all fields defined by the override replace the
+// corresponding fields of the app.
+func (app AppSpec) Merge(override AppSpec) AppSpec {
+	merged := *app.DeepCopy()
+	if override.Label != "" {
+		merged.Label = override.Label
+	}
+	if len(override.Profiles) > 0 {
+		merged.Profiles = append([]string{}, override.Profiles...)
+	}
+	if override.SpecFile != "" || len(override.SpecFiles) > 0 {
+		merged.SpecFile = override.SpecFile
+		merged.SpecFiles = append([]string(nil), override.SpecFiles...)
+	}
+	if override.PinnedRevision != "" {
+		merged.PinnedRevision = override.PinnedRevision
+	}
+	return merged
+}
+
+// GetAppSpec returns the effective CloudConfigSpec used for a single app, i.e. a copy of the spec
+// with the app's label, profiles, spec files and pinned revision applied.
+func (spec CloudConfigSpec) GetAppSpec(app AppSpec) *CloudConfigSpec {
+	eff := spec.DeepCopy()
+	eff.AppName = app.Name
+	eff.AppList = ""
+	eff.Apps = nil
+	if app.Label != "" {
+		eff.Label = app.Label
+	}
+	if len(app.Profiles) > 0 {
+		eff.Profile = append(eff.Profile, app.Profiles...)
+	}
+	if len(app.SpecFiles) > 0 {
+		eff.SpecFiles = append([]string{}, app.SpecFiles...)
+	} else if app.SpecFile != "" {
+		eff.SpecFiles = []string{app.SpecFile}
+	}
+	if app.PinnedRevision != "" {
+		eff.PinnedRevision = app.PinnedRevision
+	}
+	return eff
+}
+
+// EnvironmentSpec defines the profiles, label and target namespace of an environment
+type EnvironmentSpec struct {
+	// Name of the environment, defaults to the environment key
+	Name string `json:"name,omitempty"`
+
+	// Profile(s) added to the CloudConfig profiles for the environment
+	Profile []string `json:"profile,omitempty"`
+
+	// Label overrides the CloudConfig label for the environment
+	Label string `json:"label,omitempty"`
+
+	// Namespace where the environment's apps are managed, defaults to the CloudConfig namespace
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GetEnvironmentSpec returns the effective CloudConfigSpec used for an environment, i.e. a copy of the
+// spec with the environment's profiles and label applied.
+func (spec CloudConfigSpec) GetEnvironmentSpec(env EnvironmentSpec) *CloudConfigSpec {
+	eff := spec.DeepCopy()
+	eff.Environments = nil
+	if env.Label != "" {
+		eff.Label = env.Label
+	}
+	if len(env.Profile) > 0 {
+		eff.Profile = append(eff.Profile, env.Profile...)
+	}
+	return eff
+}
+
 // GetDurationUntilNextCycle returns the time.Duration until the start of the next reconciliation cycle.
 // This is calculated as the Period minus the duration from the start of the current cycle. If the
 // current cycle took longer than the period the boolean result is returned as true indicating that
 // one or more periods were skipped.
 func (c CloudConfig) GetDurationUntilNextCycle(startTime time.Time) (time.Duration, bool) {
+	return c.Spec.GetDurationUntilNextCycle(startTime)
+}
+
+// GetDurationUntilNextCycle returns the time.Duration until the start of the next reconciliation cycle
+// of the spec's Period, cf. CloudConfig.GetDurationUntilNextCycle.
+func (spec CloudConfigSpec) GetDurationUntilNextCycle(startTime time.Time) (time.Duration, bool) {
 	duration := time.Since(startTime)
-	period := time.Duration(c.Spec.Period) * time.Second
+	period := time.Duration(spec.Period) * time.Second
 	if duration < period {
 		return period - duration, true
 	}
 	return period, false
 }
 
+// GetSpecFiles returns the spec file names and patterns for each app, falling back on the SpecFile
+// if no SpecFiles are defined.
+func (spec CloudConfigSpec) GetSpecFiles() []string {
+	if len(spec.SpecFiles) > 0 {
+		return spec.SpecFiles
+	}
+	if spec.SpecFile == "" {
+		return nil
+	}
+	return []string{spec.SpecFile}
+}
+
 // CloudConfigStatus defines the observed state of CloudConfig
 type CloudConfigStatus struct {
 	// TODO think through how to defined the current status of a CloudConfig CloudConfigSpec
 	NamespaceStatus metav1.Status `json:"namsepaceStatus,omitempty"`
+
+	// Apps reports the effective label, profiles and spec files used for each app
+	Apps []AppStatus `json:"apps,omitempty"`
+
+	// Environments reports the CloudConfigEnv of each environment
+	Environments []EnvironmentStatus `json:"environments,omitempty"`
+
+	// Conditions of the CloudConfig, e.g. Ready
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// LastSync is the time of the last successful reconciliation
+	LastSync *metav1.Time `json:"lastSync,omitempty"`
+
+	// NextSync is the time of the next scheduled synchronization
+	NextSync *metav1.Time `json:"nextSync,omitempty"`
+}
+
+// EnvironmentStatus defines the CloudConfigEnv of an environment in the last reconciliation
+type EnvironmentStatus struct {
+	// Name of the environment key
+	Name string `json:"name"`
+	// CloudConfigEnv is the name of the environment's CloudConfigEnv
+	CloudConfigEnv string `json:"cloudConfigEnv"`
+	// Namespace of the environment
+	Namespace string `json:"namespace"`
+	// Label used for the environment
+	Label string `json:"label,omitempty"`
+	// Profiles used for the environment
+	Profiles []string `json:"profiles,omitempty"`
+	// Health of the environment, the worst health of its apps
+	// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+	Health HealthStatus `json:"health,omitempty"`
+}
+
+// AppStatus defines the effective configuration used for an app in the last reconciliation
+type AppStatus struct {
+	// Name of the app
+	Name string `json:"name"`
+	// Label used for the app
+	Label string `json:"label,omitempty"`
+	// Profiles used for the app
+	Profiles []string `json:"profiles,omitempty"`
+	// SpecFiles used for the app
+	SpecFiles []string `json:"specFiles,omitempty"`
+	// Namespace where the app is applied, only reported by ClusterCloudConfigs
+	Namespace string `json:"namespace,omitempty"`
+	// Health of the live objects of the app
+	// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+	Health HealthStatus `json:"health,omitempty"`
+	// DependsOn lists the apps the app depends on
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// PendingRevision is the revision of the app waiting for approval with the Manual sync policy
+	PendingRevision string `json:"pendingRevision,omitempty"`
+}
+
+// NewAppStatus returns the AppStatus for the effective spec of an app
+func NewAppStatus(spec *CloudConfigSpec) AppStatus {
+	return AppStatus{
+		Name:      spec.AppName,
+		Label:     spec.Label,
+		Profiles:  append([]string(nil), spec.Profile...),
+		SpecFiles: append([]string(nil), spec.GetSpecFiles()...),
+	}
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // CloudConfig is the Schema for the cloudconfigs API
 // +k8s:openapi-gen=true
+// +kubebuilder:resource:shortName=cc;ccfg
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.server"
+// +kubebuilder:printcolumn:name="Label",type="string",JSONPath=".spec.label"
+// +kubebuilder:printcolumn:name="Profiles",type="string",JSONPath=".spec.profile"
+// +kubebuilder:printcolumn:name="Apps",type="string",JSONPath=".status.apps[*].name"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Last Sync",type="date",JSONPath=".status.lastSync"
+// +kubebuilder:printcolumn:name="Next Sync",type="date",JSONPath=".status.nextSync",priority=1
 type CloudConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
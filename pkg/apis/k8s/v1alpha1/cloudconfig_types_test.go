@@ -1,9 +1,66 @@
 package v1alpha1
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
+func TestAppSpecMerge(t *testing.T) {
+	app := AppSpec{Name: "alpha", Label: "develop", Profiles: []string{"canary"}}
+
+	merged := app.Merge(AppSpec{Label: "release"})
+	assert.Equal(t, AppSpec{Name: "alpha", Label: "release", Profiles: []string{"canary"}}, merged)
+
+	merged = app.Merge(AppSpec{Profiles: []string{"blue"}, SpecFiles: []string{"blue.yaml"}})
+	assert.Equal(t, AppSpec{
+		Name:      "alpha",
+		Label:     "develop",
+		Profiles:  []string{"blue"},
+		SpecFiles: []string{"blue.yaml"},
+	}, merged)
+	assert.Equal(t, []string{"canary"}, app.Profiles, "the original app should not be modified")
+}
+
+func TestGetAppSpec(t *testing.T) {
+	var env CloudConfigSpec
+	k8MarshalYAML(t, TestEnv, &env)
+
+	spec := env.GetAppSpec(AppSpec{Name: "alpha", Profiles: []string{"canary"}})
+	assert.Equal(t, "alpha", spec.AppName)
+	assert.Equal(t, "", spec.AppList)
+	assert.Equal(t, "label", spec.Label)
+	assert.Equal(t, []string{"p1", "p2", "canary"}, spec.Profile)
+	assert.Equal(t, []string{"deployment.yaml"}, spec.GetSpecFiles())
+	assert.Equal(t, []string{"p1", "p2"}, env.Profile, "the CloudConfig spec should not be modified")
+
+	spec = env.GetAppSpec(AppSpec{Name: "beta", Label: "release", SpecFile: "beta.yaml"})
+	assert.Equal(t, "release", spec.Label)
+	assert.Equal(t, []string{"p1", "p2"}, spec.Profile)
+	assert.Equal(t, []string{"beta.yaml"}, spec.GetSpecFiles())
+	assert.Equal(t, "", spec.PinnedRevision)
+
+	spec = env.GetAppSpec(AppSpec{Name: "gamma", PinnedRevision: "0123456789ab"})
+	assert.Equal(t, "0123456789ab", spec.PinnedRevision, "the app should be pinned to its revision")
+}
+
+func TestGetEnvironmentSpec(t *testing.T) {
+	var env CloudConfigSpec
+	k8MarshalYAML(t, TestEnv, &env)
+	env.Environments = map[string]EnvironmentSpec{"dev": {Profile: []string{"dev"}}}
+
+	spec := env.GetEnvironmentSpec(EnvironmentSpec{Profile: []string{"dev"}, Label: "develop", Namespace: "dev"})
+	assert.Equal(t, "develop", spec.Label)
+	assert.Equal(t, []string{"p1", "p2", "dev"}, spec.Profile)
+	assert.Nil(t, spec.Environments)
+	assert.Equal(t, []string{"p1", "p2"}, env.Profile, "the CloudConfig spec should not be modified")
+
+	spec = env.GetEnvironmentSpec(EnvironmentSpec{})
+	assert.Equal(t, "label", spec.Label)
+	assert.Equal(t, []string{"p1", "p2"}, spec.Profile)
+}
+
 // -- test harness
 
 func getTestEnv(t *testing.T) CloudConfig {
@@ -11,3 +68,17 @@ func getTestEnv(t *testing.T) CloudConfig {
 	k8MarshalYAML(t, TestEnv, &env)
 	return env
 }
+
+func TestCloudConfigAppSpecJSON(t *testing.T) {
+	spec := CloudConfigAppSpec{
+		CloudConfigSpec: CloudConfigSpec{AppName: "consumer", DependsOn: "kubernetes.dependsOn"},
+		Dependencies:    []string{"broker"},
+	}
+	data, err := json.Marshal(spec)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var decoded CloudConfigAppSpec
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, spec, decoded, "the fields of the inlined CloudConfigSpec should not be shadowed")
+}
@@ -0,0 +1,225 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+
+const (
+	// CloudConfigLabel is the label identifying the CloudConfig of a CloudConfigApp
+	CloudConfigLabel = "k8s.jabberwocky.se/cloudconfig"
+	// AppLabel is the label identifying the app of a CloudConfigApp
+	AppLabel = "k8s.jabberwocky.se/app"
+	// CloudConfigAppLabel is the label identifying the CloudConfigApp of the app's Kubernetes objects
+	CloudConfigAppLabel = "k8s.jabberwocky.se/cloudconfigapp"
+	// AllowedNamespacesAnnotation is the namespace annotation listing the comma separated namespaces of the
+	// CloudConfigs allowed to manage the namespace, '*' allows CloudConfigs of all namespaces
+	AllowedNamespacesAnnotation = "k8s.jabberwocky.se/cloudconfig-namespaces"
+	// SuspendAnnotation suspends the synchronization of a CloudConfig, ClusterCloudConfig, CloudConfigEnv or
+	// CloudConfigApp when 'true' regardless of the suspend field of its spec
+	SuspendAnnotation = "k8s.jabberwocky.se/suspend"
+	// SuspendedByAnnotation is set by the operator on the CloudConfigEnvs and CloudConfigApps of a suspended owner
+	// and identifies the owner
+	SuspendedByAnnotation = "k8s.jabberwocky.se/suspended-by"
+	// SyncAnnotation requests a synchronization of a CloudConfig, ClusterCloudConfig, CloudConfigEnv or
+	// CloudConfigApp when its value changes; values prefixed with `force` override the sync windows and values
+	// prefixed with `dry-run` request a dry run
+	SyncAnnotation = "k8s.jabberwocky.se/sync"
+	// ApproveAnnotation approves the revisions of a CloudConfigApp with the Manual sync policy that it names, a comma
+	// separated list of revisions; the annotation of a CloudConfig, ClusterCloudConfig or CloudConfigEnv is
+	// propagated to its apps
+	ApproveAnnotation = "k8s.jabberwocky.se/approve"
+	// SyncWaveAnnotation sets the sync wave of an object in the spec files, an integer defaulting to 0. Objects
+	// are applied in the order of their waves and each wave waits for the previous waves to be healthy.
+	SyncWaveAnnotation = "k8s.jabberwocky.se/sync-wave"
+	// ConfigLabelAnnotation is set by the operator on the objects of an app to the label of its configuration
+	ConfigLabelAnnotation = "k8s.jabberwocky.se/config-label"
+	// ConfigVersionAnnotation is set by the operator on the objects of an app to the version of its configuration
+	// reported by the config server, e.g. the commit id of a Git backend
+	ConfigVersionAnnotation = "k8s.jabberwocky.se/config-version"
+)
+
+// CloudConfigAppSpec defines the desired state of CloudConfigApp
+type CloudConfigAppSpec struct {
+	// CloudConfigSpec is the effective CloudConfig spec of the app, the appList and apps fields are ignored
+	CloudConfigSpec `json:",inline"`
+
+	// Suspend stops synchronization of the app when true, it is managed independently of the suspend field of
+	// the CloudConfig
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Dependencies lists the sibling apps the app depends on as resolved by its CloudConfig or CloudConfigEnv
+	// from the dependsOn config property, the app is not synchronized while any of them is not healthy
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// CloudConfigAppStatus defines the observed state of CloudConfigApp
+type CloudConfigAppStatus struct {
+	// LastSync is the time of the last successful synchronization
+	LastSync *metav1.Time `json:"lastSync,omitempty"`
+
+	// Error is the error message of the last synchronization, empty if it succeeded
+	Error string `json:"error,omitempty"`
+
+	// NextSync is the time of the next scheduled synchronization
+	NextSync *metav1.Time `json:"nextSync,omitempty"`
+
+	// LastSyncRequest is the value of the last handled sync annotation
+	LastSyncRequest string `json:"lastSyncRequest,omitempty"`
+
+	// TargetNamespace is the namespace where the app was last applied
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// Kinds lists the kinds of the objects last applied, used for deleting the objects of apps applied to
+	// another namespace than the namespace of the CloudConfigApp
+	Kinds []string `json:"kinds,omitempty"`
+
+	// DryRun summarizes the last dry run
+	DryRun *DryRunStatus `json:"dryRun,omitempty"`
+
+	// Revision is the revision of the spec files last applied
+	Revision string `json:"revision,omitempty"`
+
+	// PendingRevision is the revision of the spec files waiting for approval, the diff of the revision is
+	// summarized in DryRun
+	PendingRevision string `json:"pendingRevision,omitempty"`
+
+	// Rollout is the status of the rollout of the last applied revision
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// LastGoodRevision is the last revision whose rollout succeeded, its spec files are kept in the history
+	// ConfigMap of the app
+	LastGoodRevision string `json:"lastGoodRevision,omitempty"`
+
+	// History lists the revisions applied most recent first, bounded by the revision history limit
+	History []RevisionHistory `json:"history,omitempty"`
+
+	// Health of the live objects of the last applied revision
+	// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+	Health HealthStatus `json:"health,omitempty"`
+
+	// HealthMessage describes why the app is not healthy
+	HealthMessage string `json:"healthMessage,omitempty"`
+
+	// Waves lists the sync waves of the objects of the app in ascending order
+	Waves []int `json:"waves,omitempty"`
+
+	// Wave is the sync wave waiting for the previous waves to become healthy, nil if all waves are applied
+	Wave *SyncWaveStatus `json:"wave,omitempty"`
+
+	// HeldBack describes why the synchronization of the app is held back by the apps it depends on, empty if all
+	// its dependencies are healthy
+	HeldBack string `json:"heldBack,omitempty"`
+
+	// Rejected lists the objects of the last rendered revision that were not applied as their kinds are not
+	// allowed by the allowed and denied kinds of the CloudConfig or the operator
+	Rejected []string `json:"rejected,omitempty"`
+
+	// Violations lists the violations of the policy by the objects of the last rendered revision
+	Violations []string `json:"violations,omitempty"`
+
+	// OverriddenImages lists the container images of the last rendered revision overridden by the images of the
+	// CloudConfig, e.g. `Deployment 'alpha' container 'alpha': alpha:1.0 -> alpha:1.1`
+	OverriddenImages []string `json:"overriddenImages,omitempty"`
+}
+
+// SyncWaveStatus describes a sync wave of a revision waiting for the previous waves to become healthy
+type SyncWaveStatus struct {
+	// Revision being applied
+	Revision string `json:"revision"`
+	// Wave waiting to be applied
+	Wave int `json:"wave"`
+	// Message describes what the wave is waiting for
+	Message string `json:"message,omitempty"`
+}
+
+// RevisionHistory describes an applied revision of an app
+type RevisionHistory struct {
+	// Revision identifies the rendered spec files of the app
+	Revision string `json:"revision"`
+	// Version of the configuration reported by the config server, e.g. the commit id of a Git backend
+	Version string `json:"version,omitempty"`
+	// Label of the configuration
+	Label string `json:"label,omitempty"`
+	// Profile(s) of the configuration
+	Profile []string `json:"profile,omitempty"`
+	// Applied is the time the revision was last applied
+	Applied metav1.Time `json:"applied"`
+	// Outcome is the phase of the rollout of the revision
+	Outcome RolloutPhase `json:"outcome,omitempty"`
+	// Pinned is true if the revision was applied because it was pinned
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// RolloutPhase is the phase of the rollout of a revision
+type RolloutPhase string
+
+const (
+	// RolloutProgressing is the phase of an applied revision whose objects are not yet healthy
+	RolloutProgressing RolloutPhase = "Progressing"
+	// RolloutSucceeded is the phase of an applied revision whose objects became healthy
+	RolloutSucceeded RolloutPhase = "Succeeded"
+	// RolloutFailed is the phase of an applied revision whose objects did not become healthy in time
+	RolloutFailed RolloutPhase = "Failed"
+	// RolloutRolledBack is the phase of a failed revision that was replaced by the last good revision
+	RolloutRolledBack RolloutPhase = "RolledBack"
+)
+
+// RolloutStatus is the status of the rollout of a revision
+type RolloutStatus struct {
+	// Revision that was applied
+	Revision string `json:"revision"`
+	// Phase of the rollout
+	Phase RolloutPhase `json:"phase"`
+	// Started is the time the revision was applied
+	Started metav1.Time `json:"started"`
+	// Message describes why the rollout is progressing or failed
+	Message string `json:"message,omitempty"`
+}
+
+// DryRunStatus summarizes what a synchronization would change, the diff of each object is stored in a ConfigMap
+type DryRunStatus struct {
+	// Time of the dry run
+	Time metav1.Time `json:"time"`
+	// Created is the number of objects that would be created
+	Created int `json:"created"`
+	// Updated is the number of objects that would be updated
+	Updated int `json:"updated"`
+	// Pruned is the number of objects that would be deleted
+	Pruned int `json:"pruned"`
+	// Unchanged is the number of objects that would not change
+	Unchanged int `json:"unchanged"`
+	// ConfigMap is the name of the ConfigMap holding the diff of each object
+	ConfigMap string `json:"configMap,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfigApp is the Schema for the cloudconfigapps API
+// +k8s:openapi-gen=true
+type CloudConfigApp struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudConfigAppSpec   `json:"spec,omitempty"`
+	Status CloudConfigAppStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfigAppList contains a list of CloudConfigApp
+type CloudConfigAppList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudConfigApp `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudConfigApp{}, &CloudConfigAppList{})
+}
+
+// GetCloudConfigAppName returns the name of the CloudConfigApp for an app of the named CloudConfig
+func GetCloudConfigAppName(cloudConfig, app string) string {
+	return cloudConfig + "-" + app
+}
@@ -0,0 +1,60 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+
+const (
+	// CloudConfigNamespaceLabel is the label identifying the namespace of the CloudConfig of a CloudConfigEnv
+	CloudConfigNamespaceLabel = "k8s.jabberwocky.se/cloudconfig-namespace"
+	// EnvLabel is the label identifying the environment of a CloudConfigEnv and its CloudConfigApps
+	EnvLabel = "k8s.jabberwocky.se/env"
+)
+
+// CloudConfigEnvSpec defines the desired state of CloudConfigEnv
+type CloudConfigEnvSpec struct {
+	// CloudConfigSpec is the effective CloudConfig spec of the environment, the environments field is ignored
+	CloudConfigSpec `json:",inline"`
+}
+
+// CloudConfigEnvStatus defines the observed state of CloudConfigEnv
+type CloudConfigEnvStatus struct {
+	// Apps reports the effective label, profiles and spec files used for each app
+	Apps []AppStatus `json:"apps,omitempty"`
+
+	// Error is the error message of the last reconciliation, e.g. the validation errors of the spec, empty if it
+	// succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfigEnv is the Schema for the cloudconfigenvs API
+// +k8s:openapi-gen=true
+type CloudConfigEnv struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudConfigEnvSpec   `json:"spec,omitempty"`
+	Status CloudConfigEnvStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfigEnvList contains a list of CloudConfigEnv
+type CloudConfigEnvList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudConfigEnv `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudConfigEnv{}, &CloudConfigEnvList{})
+}
+
+// GetCloudConfigEnvName returns the name of the CloudConfigEnv for an environment of the named CloudConfig
+func GetCloudConfigEnvName(cloudConfig, env string) string {
+	return cloudConfig + "-" + env
+}
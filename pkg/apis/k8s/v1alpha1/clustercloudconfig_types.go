@@ -0,0 +1,53 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+
+// ClusterCloudConfigLabel is the label identifying the ClusterCloudConfig of the app's Kubernetes objects
+const ClusterCloudConfigLabel = "k8s.jabberwocky.se/clustercloudconfig"
+
+// ClusterCloudConfigStatus defines the observed state of ClusterCloudConfig
+type ClusterCloudConfigStatus struct {
+	// Apps reports the effective label, profiles, spec files, namespace and health of each app, the apps of an
+	// environment are prefixed by the name of the environment
+	Apps []AppStatus `json:"apps,omitempty"`
+
+	// Conditions of the ClusterCloudConfig, e.g. Ready
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// LastSync is the time of the last successful reconciliation
+	LastSync *metav1.Time `json:"lastSync,omitempty"`
+
+	// NextSync is the time of the next scheduled synchronization
+	NextSync *metav1.Time `json:"nextSync,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCloudConfig is the Schema for the cluster scoped clustercloudconfigs API
+// +k8s:openapi-gen=true
+type ClusterCloudConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudConfigSpec          `json:"spec,omitempty"`
+	Status ClusterCloudConfigStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterCloudConfigList contains a list of ClusterCloudConfig
+type ClusterCloudConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterCloudConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterCloudConfig{}, &ClusterCloudConfigList{})
+}
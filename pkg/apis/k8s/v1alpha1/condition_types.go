@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is the type of a CloudConfig condition
+type ConditionType string
+
+const (
+	// ConditionReady is true when the last reconciliation succeeded and all apps are healthy
+	ConditionReady ConditionType = "Ready"
+	// ConditionSuspended is true when synchronization is suspended by the spec or the suspend annotation
+	ConditionSuspended ConditionType = "Suspended"
+)
+
+// HealthStatus is the health of the live objects of an app
+type HealthStatus string
+
+const (
+	// HealthHealthy is the health of an app whose workloads are available and whose custom resources are ready
+	HealthHealthy HealthStatus = "Healthy"
+	// HealthProgressing is the health of an app whose objects are being rolled out or not yet synchronized
+	HealthProgressing HealthStatus = "Progressing"
+	// HealthDegraded is the health of an app with failed, missing or not ready objects
+	HealthDegraded HealthStatus = "Degraded"
+)
+
+// Condition describes the state of a CloudConfig at a certain point
+type Condition struct {
+	// Type of the condition
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False or Unknown
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed status
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message with details about the last transition
+	Message string `json:"message,omitempty"`
+}
+
+// SetCondition adds the condition or replaces the condition of the same type. The last transition time is
+// only changed if the status of the condition changes.
+func SetCondition(conditions *[]Condition, condition Condition) {
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	for i := range *conditions {
+		existing := &(*conditions)[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = condition
+		return
+	}
+	*conditions = append(*conditions, condition)
+}
+
+// GetCondition returns the condition of the type or nil if it is not found
+func GetCondition(conditions []Condition, conditionType ConditionType) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns true if the condition of the type is found and its status is True
+func IsConditionTrue(conditions []Condition, conditionType ConditionType) bool {
+	condition := GetCondition(conditions, conditionType)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
@@ -0,0 +1,31 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	conditions := []Condition{}
+	assert.Nil(t, GetCondition(conditions, ConditionReady))
+	assert.False(t, IsConditionTrue(conditions, ConditionReady))
+
+	SetCondition(&conditions, Condition{Type: ConditionReady, Status: corev1.ConditionFalse, Reason: "Failed"})
+	assert.Len(t, conditions, 1)
+	assert.False(t, conditions[0].LastTransitionTime.IsZero(), "the transition time should default to now")
+
+	transition := metav1.NewTime(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	conditions[0].LastTransitionTime = transition
+	SetCondition(&conditions, Condition{Type: ConditionReady, Status: corev1.ConditionFalse, Reason: "StillFailing"})
+	assert.Len(t, conditions, 1)
+	assert.Equal(t, "StillFailing", conditions[0].Reason)
+	assert.Equal(t, transition, conditions[0].LastTransitionTime, "the transition time should be kept for the same status")
+
+	SetCondition(&conditions, Condition{Type: ConditionReady, Status: corev1.ConditionTrue})
+	assert.True(t, IsConditionTrue(conditions, ConditionReady))
+	assert.NotEqual(t, transition, conditions[0].LastTransitionTime, "the transition time should change with the status")
+}
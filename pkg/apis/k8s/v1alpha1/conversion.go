@@ -0,0 +1,354 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConversionAnnotation holds the v1alpha2 fields of a CloudConfig that cannot be represented in v1alpha1 so
+// that they survive a round-trip through v1alpha1
+const ConversionAnnotation = "k8s.jabberwocky.se/v1alpha2-conversion"
+
+// conversionData contains the v1alpha2 fields that are lost when converting to v1alpha1
+type conversionData struct {
+	// Servers are all servers of which only the first is kept in v1alpha1
+	Servers []string `json:"servers,omitempty"`
+	// Interval is the interval with sub-second precision that is truncated to whole seconds in v1alpha1
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ConvertTo converts the CloudConfig to the v1alpha2 storage version
+func (src *CloudConfig) ConvertTo(dst *v1alpha2.CloudConfig) error {
+	data := conversionData{}
+	if value, ok := src.Annotations[ConversionAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &data); err != nil {
+			return err
+		}
+	}
+
+	dst.TypeMeta = metav1.TypeMeta{APIVersion: v1alpha2.SchemeGroupVersion.String(), Kind: "CloudConfig"}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if _, ok := dst.Annotations[ConversionAnnotation]; ok {
+		delete(dst.Annotations, ConversionAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	convertSpecTo(&src.Spec, &dst.Spec, &data)
+	convertStatusTo(&src.Status, &dst.Status)
+	return nil
+}
+
+// ConvertFrom converts the CloudConfig from the v1alpha2 storage version
+func (dst *CloudConfig) ConvertFrom(src *v1alpha2.CloudConfig) error {
+	dst.TypeMeta = metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "CloudConfig"}
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+
+	data := convertSpecFrom(&src.Spec, &dst.Spec)
+	if data.Servers != nil || data.Interval != nil {
+		value, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = make(map[string]string, 1)
+		}
+		dst.Annotations[ConversionAnnotation] = string(value)
+	}
+
+	convertStatusFrom(&src.Status, &dst.Status)
+	return nil
+}
+
+func convertSpecTo(src *CloudConfigSpec, dst *v1alpha2.CloudConfigSpec, data *conversionData) {
+	dst.AppName = src.AppName
+	dst.Profiles = copyStrings(src.Profile)
+	dst.Label = src.Label
+	if len(data.Servers) > 0 && data.Servers[0] == src.Server {
+		dst.Servers = copyStrings(data.Servers)
+	} else if src.Server != "" {
+		dst.Servers = []string{src.Server}
+	}
+	dst.SpecFile = src.SpecFile
+	dst.SpecFiles = copyStrings(src.SpecFiles)
+	dst.SpecManifest = src.SpecManifest
+	dst.DependsOn = src.DependsOn
+	dst.AppList = src.AppList
+	if src.Apps != nil {
+		dst.Apps = make(map[string]v1alpha2.AppSpec, len(src.Apps))
+		for key, app := range src.Apps {
+			dst.Apps[key] = v1alpha2.AppSpec{
+				Name:           app.Name,
+				Label:          app.Label,
+				Profiles:       copyStrings(app.Profiles),
+				SpecFile:       app.SpecFile,
+				SpecFiles:      copyStrings(app.SpecFiles),
+				PinnedRevision: app.PinnedRevision,
+			}
+		}
+	}
+	if src.Environments != nil {
+		dst.Environments = make(map[string]v1alpha2.EnvironmentSpec, len(src.Environments))
+		for key, env := range src.Environments {
+			dst.Environments[key] = v1alpha2.EnvironmentSpec{
+				Name:      env.Name,
+				Profiles:  copyStrings(env.Profile),
+				Label:     env.Label,
+				Namespace: env.Namespace,
+			}
+		}
+	}
+	dst.TargetNamespace = src.TargetNamespace
+	if src.CreateNamespace != nil {
+		dst.CreateNamespace = &v1alpha2.NamespaceSpec{
+			Labels:      copyStringMap(src.CreateNamespace.Labels),
+			Annotations: copyStringMap(src.CreateNamespace.Annotations),
+		}
+	}
+	dst.CommonLabels = copyStringMap(src.CommonLabels)
+	dst.CommonAnnotations = copyStringMap(src.CommonAnnotations)
+	if data.Interval != nil && int(data.Interval.Seconds()) == src.Period {
+		dst.Interval = &metav1.Duration{Duration: data.Interval.Duration}
+	} else if src.Period != 0 {
+		dst.Interval = &metav1.Duration{Duration: time.Duration(src.Period) * time.Second}
+	}
+	dst.Schedule = src.Schedule
+	if src.SyncWindows != nil {
+		dst.SyncWindows = make([]v1alpha2.SyncWindow, len(src.SyncWindows))
+		for i, window := range src.SyncWindows {
+			dst.SyncWindows[i] = v1alpha2.SyncWindow{
+				Kind:     v1alpha2.SyncWindowKind(window.Kind),
+				Schedule: window.Schedule,
+				Duration: window.Duration,
+				TimeZone: window.TimeZone,
+			}
+		}
+	}
+	dst.Suspend = src.Suspend
+	dst.Mode = v1alpha2.SyncMode(src.Mode)
+	dst.SyncPolicy = v1alpha2.SyncPolicy(src.SyncPolicy)
+	if src.Rollback != nil {
+		dst.Rollback = &v1alpha2.RollbackSpec{ProgressDeadline: src.Rollback.ProgressDeadline.DeepCopy()}
+	}
+	dst.RevisionHistoryLimit = src.RevisionHistoryLimit
+	dst.ServiceAccountName = src.ServiceAccountName
+	if src.Images != nil {
+		dst.Images = make([]v1alpha2.ImageOverride, len(src.Images))
+		for i, image := range src.Images {
+			dst.Images[i] = v1alpha2.ImageOverride(image)
+		}
+	}
+	dst.AllowedKinds = copyStrings(src.AllowedKinds)
+	dst.DeniedKinds = copyStrings(src.DeniedKinds)
+	dst.Policy = src.Policy
+	dst.PinnedRevision = src.PinnedRevision
+	dst.TrustStore = src.TrustStore
+	dst.Credentials = v1alpha2.CloudConfigCredentials(src.Credentials)
+	dst.Insecure = src.Insecure
+}
+
+// convertSpecFrom converts the v1alpha2 spec and returns the fields that could not be converted
+func convertSpecFrom(src *v1alpha2.CloudConfigSpec, dst *CloudConfigSpec) conversionData {
+	data := conversionData{}
+	dst.AppName = src.AppName
+	dst.Profile = copyStrings(src.Profiles)
+	dst.Label = src.Label
+	if len(src.Servers) > 0 {
+		dst.Server = src.Servers[0]
+	}
+	if len(src.Servers) > 1 {
+		data.Servers = copyStrings(src.Servers)
+	}
+	dst.SpecFile = src.SpecFile
+	dst.SpecFiles = copyStrings(src.SpecFiles)
+	dst.SpecManifest = src.SpecManifest
+	dst.DependsOn = src.DependsOn
+	dst.AppList = src.AppList
+	if src.Apps != nil {
+		dst.Apps = make(map[string]AppSpec, len(src.Apps))
+		for key, app := range src.Apps {
+			dst.Apps[key] = AppSpec{
+				Name:           app.Name,
+				Label:          app.Label,
+				Profiles:       copyStrings(app.Profiles),
+				SpecFile:       app.SpecFile,
+				SpecFiles:      copyStrings(app.SpecFiles),
+				PinnedRevision: app.PinnedRevision,
+			}
+		}
+	}
+	if src.Environments != nil {
+		dst.Environments = make(map[string]EnvironmentSpec, len(src.Environments))
+		for key, env := range src.Environments {
+			dst.Environments[key] = EnvironmentSpec{
+				Name:      env.Name,
+				Profile:   copyStrings(env.Profiles),
+				Label:     env.Label,
+				Namespace: env.Namespace,
+			}
+		}
+	}
+	dst.TargetNamespace = src.TargetNamespace
+	if src.CreateNamespace != nil {
+		dst.CreateNamespace = &NamespaceSpec{
+			Labels:      copyStringMap(src.CreateNamespace.Labels),
+			Annotations: copyStringMap(src.CreateNamespace.Annotations),
+		}
+	}
+	dst.CommonLabels = copyStringMap(src.CommonLabels)
+	dst.CommonAnnotations = copyStringMap(src.CommonAnnotations)
+	if src.Interval != nil {
+		dst.Period = int(src.Interval.Seconds())
+		if src.Interval.Duration != time.Duration(dst.Period)*time.Second {
+			data.Interval = &metav1.Duration{Duration: src.Interval.Duration}
+		}
+	}
+	dst.Schedule = src.Schedule
+	if src.SyncWindows != nil {
+		dst.SyncWindows = make([]SyncWindow, len(src.SyncWindows))
+		for i, window := range src.SyncWindows {
+			dst.SyncWindows[i] = SyncWindow{
+				Kind:     SyncWindowKind(window.Kind),
+				Schedule: window.Schedule,
+				Duration: window.Duration,
+				TimeZone: window.TimeZone,
+			}
+		}
+	}
+	dst.Suspend = src.Suspend
+	dst.Mode = SyncMode(src.Mode)
+	dst.SyncPolicy = SyncPolicy(src.SyncPolicy)
+	if src.Rollback != nil {
+		dst.Rollback = &RollbackSpec{ProgressDeadline: src.Rollback.ProgressDeadline.DeepCopy()}
+	}
+	dst.RevisionHistoryLimit = src.RevisionHistoryLimit
+	dst.ServiceAccountName = src.ServiceAccountName
+	if src.Images != nil {
+		dst.Images = make([]ImageOverride, len(src.Images))
+		for i, image := range src.Images {
+			dst.Images[i] = ImageOverride(image)
+		}
+	}
+	dst.AllowedKinds = copyStrings(src.AllowedKinds)
+	dst.DeniedKinds = copyStrings(src.DeniedKinds)
+	dst.Policy = src.Policy
+	dst.PinnedRevision = src.PinnedRevision
+	dst.TrustStore = src.TrustStore
+	dst.Credentials = CloudConfigCredentials(src.Credentials)
+	dst.Insecure = src.Insecure
+	return data
+}
+
+func convertStatusTo(src *CloudConfigStatus, dst *v1alpha2.CloudConfigStatus) {
+	src.NamespaceStatus.DeepCopyInto(&dst.NamespaceStatus)
+	dst.LastSync = src.LastSync.DeepCopy()
+	dst.NextSync = src.NextSync.DeepCopy()
+	if src.Conditions != nil {
+		dst.Conditions = make([]v1alpha2.Condition, len(src.Conditions))
+		for i, condition := range src.Conditions {
+			dst.Conditions[i] = v1alpha2.Condition{
+				Type:               v1alpha2.ConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: *condition.LastTransitionTime.DeepCopy(),
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	if src.Apps != nil {
+		dst.Apps = make([]v1alpha2.AppStatus, len(src.Apps))
+		for i, app := range src.Apps {
+			dst.Apps[i] = v1alpha2.AppStatus{
+				Name:            app.Name,
+				Label:           app.Label,
+				Profiles:        copyStrings(app.Profiles),
+				SpecFiles:       copyStrings(app.SpecFiles),
+				Namespace:       app.Namespace,
+				Health:          v1alpha2.HealthStatus(app.Health),
+				DependsOn:       copyStrings(app.DependsOn),
+				PendingRevision: app.PendingRevision,
+			}
+		}
+	}
+	if src.Environments != nil {
+		dst.Environments = make([]v1alpha2.EnvironmentStatus, len(src.Environments))
+		for i, env := range src.Environments {
+			dst.Environments[i] = v1alpha2.EnvironmentStatus{
+				Name:           env.Name,
+				CloudConfigEnv: env.CloudConfigEnv,
+				Namespace:      env.Namespace,
+				Label:          env.Label,
+				Profiles:       copyStrings(env.Profiles),
+				Health:         v1alpha2.HealthStatus(env.Health),
+			}
+		}
+	}
+}
+
+func convertStatusFrom(src *v1alpha2.CloudConfigStatus, dst *CloudConfigStatus) {
+	src.NamespaceStatus.DeepCopyInto(&dst.NamespaceStatus)
+	dst.LastSync = src.LastSync.DeepCopy()
+	dst.NextSync = src.NextSync.DeepCopy()
+	if src.Conditions != nil {
+		dst.Conditions = make([]Condition, len(src.Conditions))
+		for i, condition := range src.Conditions {
+			dst.Conditions[i] = Condition{
+				Type:               ConditionType(condition.Type),
+				Status:             condition.Status,
+				LastTransitionTime: *condition.LastTransitionTime.DeepCopy(),
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+			}
+		}
+	}
+	if src.Apps != nil {
+		dst.Apps = make([]AppStatus, len(src.Apps))
+		for i, app := range src.Apps {
+			dst.Apps[i] = AppStatus{
+				Name:            app.Name,
+				Label:           app.Label,
+				Profiles:        copyStrings(app.Profiles),
+				SpecFiles:       copyStrings(app.SpecFiles),
+				Namespace:       app.Namespace,
+				Health:          HealthStatus(app.Health),
+				DependsOn:       copyStrings(app.DependsOn),
+				PendingRevision: app.PendingRevision,
+			}
+		}
+	}
+	if src.Environments != nil {
+		dst.Environments = make([]EnvironmentStatus, len(src.Environments))
+		for i, env := range src.Environments {
+			dst.Environments[i] = EnvironmentStatus{
+				Name:           env.Name,
+				CloudConfigEnv: env.CloudConfigEnv,
+				Namespace:      env.Namespace,
+				Label:          env.Label,
+				Profiles:       copyStrings(env.Profiles),
+				Health:         HealthStatus(env.Health),
+			}
+		}
+	}
+}
+
+func copyStrings(src []string) []string {
+	if src == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(src)), src...)
+}
+
+func copyStringMap(src map[string]string) map[string]string {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[string]string, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha2"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertTo(t *testing.T) {
+	var c CloudConfig
+	k8MarshalYAML(t, CloudConfigExample, &c)
+	c.Spec.Environments = map[string]EnvironmentSpec{"dev": {Profile: []string{"dev"}, Namespace: "dev"}}
+
+	var v2 v1alpha2.CloudConfig
+	assert.NoError(t, c.ConvertTo(&v2))
+	assert.Equal(t, "k8s.jabberwocky.se/v1alpha2", v2.APIVersion)
+	assert.Equal(t, "test", v2.Name)
+	assert.Equal(t, []string{"cloud-config-server:8888"}, v2.Spec.Servers)
+	assert.Equal(t, []string{"prd"}, v2.Spec.Profiles)
+	assert.Equal(t, &metav1.Duration{Duration: 10 * time.Second}, v2.Spec.Interval)
+	assert.Equal(t, []string{"dev"}, v2.Spec.Environments["dev"].Profiles)
+	assert.Nil(t, v2.Annotations)
+}
+
+func TestConvertRoundTripFromV1alpha1(t *testing.T) {
+	var c CloudConfig
+	k8MarshalYAML(t, CloudConfigExample, &c)
+	c.Spec.Apps = map[string]AppSpec{"alpha": {Label: "release", Profiles: []string{"canary"}}}
+	c.Spec.Environments = map[string]EnvironmentSpec{"dev": {Profile: []string{"dev"}, Label: "develop"}}
+	c.Spec.CreateNamespace = &NamespaceSpec{Labels: map[string]string{"team": "alpha"}}
+	c.Spec.CommonLabels = map[string]string{"sys": "shop"}
+	c.Spec.CommonAnnotations = map[string]string{"team": "alpha@example.com"}
+	c.Spec.Suspend = true
+	c.Spec.Mode = SyncModeDryRun
+	c.Spec.SyncPolicy = SyncPolicyManual
+	c.Spec.RevisionHistoryLimit = 5
+	c.Spec.PinnedRevision = "0123456789ab"
+	c.Spec.ServiceAccountName = "deployer"
+	c.Spec.Images = []ImageOverride{{Name: "registry.example.com/alpha", Tag: "1.2.3"}}
+	c.Spec.AllowedKinds = []string{"apps/*", "/ConfigMap"}
+	c.Spec.DeniedKinds = []string{"rbac.authorization.k8s.io/*"}
+	c.Spec.Policy = "restricted"
+	c.Spec.Rollback = &RollbackSpec{ProgressDeadline: &metav1.Duration{Duration: 5 * time.Minute}}
+	c.Spec.Schedule = "0 8 * * MON-FRI"
+	c.Spec.SyncWindows = []SyncWindow{{Kind: SyncWindowDeny, Schedule: "0 17 * * FRI", Duration: metav1.Duration{Duration: 63 * time.Hour}, TimeZone: "Europe/Stockholm"}}
+	c.Status.Apps = []AppStatus{{Name: "alpha", Label: "release", Profiles: []string{"prd", "canary"}, Health: HealthHealthy, DependsOn: []string{"broker"}, PendingRevision: "8e41c07d5a92"}}
+	c.Status.Environments = []EnvironmentStatus{{Name: "dev", CloudConfigEnv: "test-dev", Namespace: "default", Health: HealthDegraded}}
+	lastSync := metav1.NewTime(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Status.LastSync = &lastSync
+	c.Status.NextSync = &lastSync
+	c.Status.Conditions = []Condition{{Type: ConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: lastSync, Reason: "Reconciled"}}
+
+	var v2 v1alpha2.CloudConfig
+	assert.NoError(t, c.ConvertTo(&v2))
+
+	var rt CloudConfig
+	assert.NoError(t, rt.ConvertFrom(&v2))
+	c.APIVersion = "k8s.jabberwocky.se/v1alpha1"
+	assert.Equal(t, c, rt)
+}
+
+func TestConvertRoundTripFromV1alpha2(t *testing.T) {
+	v2 := v1alpha2.CloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: map[string]string{"owner": "ops"}},
+		Spec: v1alpha2.CloudConfigSpec{
+			AppName:  "cluster",
+			Servers:  []string{"https://config-1", "https://config-2"},
+			Profiles: []string{"prd"},
+			Interval: &metav1.Duration{Duration: 1500 * time.Millisecond},
+		},
+		Status: v1alpha2.CloudConfigStatus{
+			NamespaceStatus: metav1.Status{Status: metav1.StatusSuccess},
+		},
+	}
+
+	var c CloudConfig
+	assert.NoError(t, c.ConvertFrom(&v2))
+	assert.Equal(t, "https://config-1", c.Spec.Server, "v1alpha1 should use the first server")
+	assert.Equal(t, 1, c.Spec.Period, "the interval should be truncated to whole seconds")
+	assert.Equal(t, metav1.StatusSuccess, c.Status.NamespaceStatus.Status)
+	assert.Contains(t, c.Annotations, ConversionAnnotation, "lost fields should be kept in an annotation")
+
+	var rt v1alpha2.CloudConfig
+	assert.NoError(t, c.ConvertTo(&rt))
+	v2.TypeMeta = metav1.TypeMeta{APIVersion: "k8s.jabberwocky.se/v1alpha2", Kind: "CloudConfig"}
+	assert.Equal(t, v2, rt)
+
+	c.Spec.Server = "https://config-3"
+	c.Spec.Period = 60
+	assert.NoError(t, c.ConvertTo(&rt))
+	assert.Equal(t, []string{"https://config-3"}, rt.Spec.Servers, "v1alpha1 changes should take precedence")
+	assert.Equal(t, &metav1.Duration{Duration: time.Minute}, rt.Spec.Interval)
+}
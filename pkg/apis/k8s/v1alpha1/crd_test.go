@@ -0,0 +1,103 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha2"
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+// crdFile is the CloudConfig CRD relative to this package
+const crdFile = "../../../../deploy/crds/cloudconfig_crd.yaml"
+
+// TestCRDSchema verifies that the schema of each version of the CloudConfig CRD declares every field of the spec
+// and status of the version; undeclared fields are pruned by the API server.
+func TestCRDSchema(t *testing.T) {
+	data, err := ioutil.ReadFile(crdFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if !assert.NoError(t, yaml.Unmarshal(data, &crd)) {
+		return
+	}
+
+	types := map[string]reflect.Type{
+		"v1alpha1": reflect.TypeOf(CloudConfig{}),
+		"v1alpha2": reflect.TypeOf(v1alpha2.CloudConfig{}),
+	}
+	assert.Len(t, crd.Spec.Versions, len(types))
+	for _, version := range crd.Spec.Versions {
+		typ, ok := types[version.Name]
+		if !assert.True(t, ok, "unexpected version %s", version.Name) {
+			continue
+		}
+		properties := getSchemaMap(version.Schema.OpenAPIV3Schema, "properties")
+		for _, field := range []string{"Spec", "Status"} {
+			f, _ := typ.FieldByName(field)
+			assertSchema(t, version.Name+"."+getJSONName(f), f.Type, getSchemaMap(properties, getJSONName(f)))
+		}
+	}
+}
+
+// assertSchema asserts that the schema declares every JSON field of the type
+func assertSchema(t *testing.T, path string, typ reflect.Type, schema map[string]interface{}) {
+	if !assert.NotNil(t, schema, "%s is not declared in the schema", path) {
+		return
+	}
+	if preserve, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool); preserve {
+		return
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PtrTo(typ).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Slice:
+		assertSchema(t, path+"[]", typ.Elem(), getSchemaMap(schema, "items"))
+	case reflect.Map:
+		assertSchema(t, path+"{}", typ.Elem(), getSchemaMap(schema, "additionalProperties"))
+	case reflect.Struct:
+		properties := getSchemaMap(schema, "properties")
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := getJSONName(f)
+			if name == "-" || f.PkgPath != "" {
+				continue
+			}
+			if name == "" && f.Anonymous {
+				assertSchema(t, path, f.Type, schema)
+				continue
+			}
+			assertSchema(t, path+"."+name, f.Type, getSchemaMap(properties, name))
+		}
+	}
+}
+
+// getJSONName returns the JSON name of the struct field
+func getJSONName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// getSchemaMap returns the named object of the schema, nil if not found
+func getSchemaMap(schema map[string]interface{}, name string) map[string]interface{} {
+	m, _ := schema[name].(map[string]interface{})
+	return m
+}
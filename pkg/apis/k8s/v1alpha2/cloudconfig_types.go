@@ -0,0 +1,351 @@
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
+
+// CloudConfigSpec defines the desired state of CloudConfig
+type CloudConfigSpec struct {
+	// Application name, defaults to system name
+	// +kubebuilder:validation:Pattern=^[a-zA-Z0-9._-]*$
+	AppName string `json:"appName,omitempty"`
+
+	// Profiles used for all apps
+	Profiles []string `json:"profiles,omitempty"`
+
+	// label used for all apps, defaults to 'master'
+	Label string `json:"label,omitempty"`
+
+	// Servers lists the Cloud Config Server names or URLs, the operator uses the first server
+	// +kubebuilder:validation:MinItems=1
+	Servers []string `json:"servers,omitempty"`
+
+	// app spec file name, defaults to 'deployment.yaml'; ignored if SpecFiles is set
+	SpecFile string `json:"specFile,omitempty"`
+
+	// SpecFiles lists the app spec file names or glob patterns that are concatenated for each app.
+	// Files are retrieved in the order listed and pattern matches in alphabetical order. Entries
+	// prefixed with `optional:` are skipped if the file does not exist or the pattern does not match.
+	SpecFiles []string `json:"specFiles,omitempty"`
+
+	// SpecManifest is the app config property listing the spec files available for each app,
+	// required for resolving SpecFiles patterns
+	SpecManifest string `json:"specManifest,omitempty"`
+
+	// DependsOn is the app config property listing the apps of the app list each app depends on, optional.
+	// Apps are synchronized in dependency order and held back while any of their dependencies is not healthy.
+	DependsOn string `json:"dependsOn,omitempty"`
+
+	// Application list property name, optional
+	AppList string `json:"appList,omitempty"`
+
+	// Apps overrides the label, profiles and spec files of individual apps, keyed by app name
+	Apps map[string]AppSpec `json:"apps,omitempty"`
+
+	// Environments managed by the CloudConfig keyed by environment name, optional
+	Environments map[string]EnvironmentSpec `json:"environments,omitempty"`
+
+	// TargetNamespace is the namespace where the apps are applied, defaults to the namespace of the CloudConfig.
+	// The target namespace must allow CloudConfigs of the CloudConfig namespace to manage it.
+	// +kubebuilder:validation:Pattern=^[a-z0-9]([-a-z0-9]*[a-z0-9])?$
+	// +kubebuilder:validation:MaxLength=63
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// CreateNamespace creates the target namespace with the given labels and annotations if it does not exist
+	CreateNamespace *NamespaceSpec `json:"createNamespace,omitempty"`
+
+	// CommonLabels are set on all objects of the apps and the pod templates of their workloads, together with the
+	// labels identifying the app, CloudConfig and environment of the objects
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// CommonAnnotations are set on all objects of the apps and the pod templates of their workloads
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+
+	// Suspend stops fetching and applying the apps when true, the status of the last synchronization is kept
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Mode of the synchronization, `Apply` (default) applies the apps and `DryRun` only reports what a
+	// synchronization would change without changing the cluster
+	// +kubebuilder:validation:Enum=Apply;DryRun
+	Mode SyncMode `json:"mode,omitempty"`
+
+	// SyncPolicy of the apps, `Automatic` (default) applies changes as soon as they are found and `Manual` waits
+	// for each revision to be approved with the `k8s.jabberwocky.se/approve` annotation
+	// +kubebuilder:validation:Enum=Automatic;Manual
+	SyncPolicy SyncPolicy `json:"syncPolicy,omitempty"`
+
+	// Rollback re-applies the last good revision of an app when the rollout of a new revision fails, optional
+	Rollback *RollbackSpec `json:"rollback,omitempty"`
+
+	// RevisionHistoryLimit is the number of applied revisions kept in the history of each app, defaults to 10
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit int `json:"revisionHistoryLimit,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount in the namespace of the CloudConfig the operator impersonates when
+	// applying and pruning the objects of the apps, restricting them to what the RBAC of the ServiceAccount allows;
+	// the objects are applied with the permissions of the operator if empty
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Images override the tags or digests of the container images of the workloads of the apps, e.g. for testing a
+	// build without changing the config repository; the overridden images are reported in the status of each app
+	Images []ImageOverride `json:"images,omitempty"`
+
+	// AllowedKinds restricts the objects of the apps to the kinds matching any of the patterns, all kinds are
+	// allowed if empty. Patterns are `<group>/<kind>` globs, e.g. `apps/*` or `/ConfigMap` for the core group; a
+	// pattern without a group, e.g. `ConfigMap`, matches the kind of any group.
+	AllowedKinds []string `json:"allowedKinds,omitempty"`
+
+	// DeniedKinds rejects the objects of the apps of the kinds matching any of the patterns, cf. AllowedKinds
+	DeniedKinds []string `json:"deniedKinds,omitempty"`
+
+	// Policy is the name of a ConfigMap with the policy rules checked for the workloads of the apps before they are
+	// applied, e.g. denying privileged containers; violations fail the apps or are only reported depending on the
+	// enforcement mode of the policy, optional
+	Policy string `json:"policy,omitempty"`
+
+	// PinnedRevision pins the apps to a revision of their history until it is removed; the revision is either
+	// the revision of an app or the config server version, e.g. the commit id, of a revision
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+
+	// Interval between cloud config synchronizations, e.g. `5m`; if not set the environment is updated
+	// only once after each CloudConfig change
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Schedule is a cron expression of the cloud config synchronizations, e.g. `*/10 8-17 * * MON-FRI`,
+	// optionally prefixed with the time zone, e.g. `CRON_TZ=Europe/Stockholm 0 8 * * *`
+	Schedule string `json:"schedule,omitempty"`
+
+	// SyncWindows restrict when apps are applied; apps are applied only while no deny window and, if there are
+	// allow windows, an allow window is active
+	SyncWindows []SyncWindow `json:"syncWindows,omitempty"`
+
+	// TrustStore optionally defines the name of a secret containing all trusted certificates
+	TrustStore string `json:"trustStore,omitempty"`
+
+	// Cloud Config Server secret containing cloud config credentials, optional
+	Credentials CloudConfigCredentials `json:"credentials,omitempty"`
+
+	// If Insecure is 'true' certificates are not required for
+	// servers outside the cluster and SSL errors are ignored.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// SyncMode is the mode of a synchronization
+type SyncMode string
+
+const (
+	// SyncModeApply applies the apps
+	SyncModeApply SyncMode = "Apply"
+	// SyncModeDryRun computes the diff of the apps against the live objects without changing the cluster
+	SyncModeDryRun SyncMode = "DryRun"
+)
+
+// SyncPolicy defines when changes of the apps are applied
+type SyncPolicy string
+
+const (
+	// SyncPolicyAutomatic applies changes of the apps without approval
+	SyncPolicyAutomatic SyncPolicy = "Automatic"
+	// SyncPolicyManual applies a changed revision of an app only after it has been approved
+	SyncPolicyManual SyncPolicy = "Manual"
+)
+
+// SyncWindowKind is the kind of a sync window
+type SyncWindowKind string
+
+const (
+	// SyncWindowAllow windows allow apps to be applied while they are active
+	SyncWindowAllow SyncWindowKind = "allow"
+	// SyncWindowDeny windows prevent apps from being applied while they are active
+	SyncWindowDeny SyncWindowKind = "deny"
+)
+
+// SyncWindow defines a recurring time window when apps are allowed or denied to be applied
+type SyncWindow struct {
+	// Kind of the window, either `allow` or `deny`
+	// +kubebuilder:validation:Enum=allow;deny
+	Kind SyncWindowKind `json:"kind"`
+	// Schedule is the cron expression of the start of the window, e.g. `0 8 * * MON-FRI`
+	Schedule string `json:"schedule"`
+	// Duration of the window, e.g. `9h`
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone of the schedule, e.g. `Europe/Stockholm`, defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ImageOverride replaces the tag or digest of the container images with the given name
+type ImageOverride struct {
+	// Name of the image without tag or digest as given in the spec files, e.g. `registry.example.com/team/alpha`
+	Name string `json:"name"`
+	// Tag replacing the tag or digest of the image, e.g. `1.2.3`
+	Tag string `json:"tag,omitempty"`
+	// Digest replacing the tag or digest of the image, e.g. `sha256:...`, takes precedence over Tag
+	Digest string `json:"digest,omitempty"`
+}
+
+// RollbackSpec defines the automatic rollback of apps whose rollout fails
+type RollbackSpec struct {
+	// ProgressDeadline is the time the objects of a new revision have to become healthy before the rollout
+	// fails, defaults to 10m
+	ProgressDeadline *metav1.Duration `json:"progressDeadline,omitempty"`
+}
+
+// NamespaceSpec defines the labels and annotations of a target namespace created by the operator
+type NamespaceSpec struct {
+	// Labels of the namespace
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations of the namespace
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// CloudConfigCredentials contains the metadata used to retrieve a Kubernetes secret containing
+// Cloud Config Server credentials.
+type CloudConfigCredentials struct {
+	// Secret is the name of the secret that holds all credentials, required
+	Secret string `json:"secret,omitempty"`
+	// Username is the name of the username secret entry, defaults to `username`
+	Username string `json:"username,omitempty"`
+	// Password is the name of the password secret entry, defaults to `password`
+	Password string `json:"password,omitempty"`
+	// Token is the name of the token secret entry, defaults to `token`
+	Token string `json:"token,omitempty"`
+	// Cert is the name of the client certificate secret entry, defaults to `cert.pem`
+	Cert string `json:"cert,omitempty"`
+	// Key is the name of the client certificate key secret entry, defaults to `cert.key`
+	Key string `json:"key,omitempty"`
+	// RootCA is the name of the secret entry for the certificate used to sign the server certificate,
+	// defaults to `ca.pem`
+	RootCA string `json:"rootCA,omitempty"`
+}
+
+// AppSpec defines the label, profiles and spec files of an individual app
+type AppSpec struct {
+	// Name of the app, required in the appList
+	Name string `json:"name,omitempty"`
+
+	// Label overrides the CloudConfig label for the app, e.g. to pin the app to a release branch
+	Label string `json:"label,omitempty"`
+
+	// Profiles are added to the CloudConfig profiles for the app
+	Profiles []string `json:"profiles,omitempty"`
+
+	// SpecFile overrides the CloudConfig spec file(s) for the app; ignored if SpecFiles is set
+	SpecFile string `json:"specFile,omitempty"`
+
+	// SpecFiles overrides the CloudConfig spec files for the app
+	SpecFiles []string `json:"specFiles,omitempty"`
+
+	// PinnedRevision overrides the CloudConfig pinned revision for the app
+	PinnedRevision string `json:"pinnedRevision,omitempty"`
+}
+
+// EnvironmentSpec defines the profiles, label and target namespace of an environment
+type EnvironmentSpec struct {
+	// Name of the environment, defaults to the environment key
+	Name string `json:"name,omitempty"`
+
+	// Profiles added to the CloudConfig profiles for the environment
+	Profiles []string `json:"profiles,omitempty"`
+
+	// Label overrides the CloudConfig label for the environment
+	Label string `json:"label,omitempty"`
+
+	// Namespace where the environment's apps are managed, defaults to the CloudConfig namespace
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// CloudConfigStatus defines the observed state of CloudConfig
+type CloudConfigStatus struct {
+	// NamespaceStatus is the status of the managed namespace
+	NamespaceStatus metav1.Status `json:"namespaceStatus,omitempty"`
+
+	// Apps reports the effective label, profiles and spec files used for each app
+	Apps []AppStatus `json:"apps,omitempty"`
+
+	// Environments reports the CloudConfigEnv of each environment
+	Environments []EnvironmentStatus `json:"environments,omitempty"`
+
+	// Conditions of the CloudConfig, e.g. Ready
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// LastSync is the time of the last successful reconciliation
+	LastSync *metav1.Time `json:"lastSync,omitempty"`
+
+	// NextSync is the time of the next scheduled synchronization
+	NextSync *metav1.Time `json:"nextSync,omitempty"`
+}
+
+// EnvironmentStatus defines the CloudConfigEnv of an environment in the last reconciliation
+type EnvironmentStatus struct {
+	// Name of the environment key
+	Name string `json:"name"`
+	// CloudConfigEnv is the name of the environment's CloudConfigEnv
+	CloudConfigEnv string `json:"cloudConfigEnv"`
+	// Namespace of the environment
+	Namespace string `json:"namespace"`
+	// Label used for the environment
+	Label string `json:"label,omitempty"`
+	// Profiles used for the environment
+	Profiles []string `json:"profiles,omitempty"`
+	// Health of the environment, the worst health of its apps
+	// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+	Health HealthStatus `json:"health,omitempty"`
+}
+
+// AppStatus defines the effective configuration used for an app in the last reconciliation
+type AppStatus struct {
+	// Name of the app
+	Name string `json:"name"`
+	// Label used for the app
+	Label string `json:"label,omitempty"`
+	// Profiles used for the app
+	Profiles []string `json:"profiles,omitempty"`
+	// SpecFiles used for the app
+	SpecFiles []string `json:"specFiles,omitempty"`
+	// Namespace where the app is applied, only reported by ClusterCloudConfigs
+	Namespace string `json:"namespace,omitempty"`
+	// Health of the live objects of the app
+	// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+	Health HealthStatus `json:"health,omitempty"`
+	// DependsOn lists the apps the app depends on
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// PendingRevision is the revision of the app waiting for approval with the Manual sync policy
+	PendingRevision string `json:"pendingRevision,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfig is the Schema for the cloudconfigs API
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:shortName=cc;ccfg
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.servers[0]"
+// +kubebuilder:printcolumn:name="Label",type="string",JSONPath=".spec.label"
+// +kubebuilder:printcolumn:name="Profiles",type="string",JSONPath=".spec.profiles"
+// +kubebuilder:printcolumn:name="Apps",type="string",JSONPath=".status.apps[*].name"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Last Sync",type="date",JSONPath=".status.lastSync"
+// +kubebuilder:printcolumn:name="Next Sync",type="date",JSONPath=".status.nextSync",priority=1
+type CloudConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudConfigSpec   `json:"spec,omitempty"`
+	Status CloudConfigStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CloudConfigList contains a list of CloudConfig
+type CloudConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudConfig{}, &CloudConfigList{})
+}
@@ -0,0 +1,27 @@
+package v1alpha2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is the type of a CloudConfig condition
+type ConditionType string
+
+// HealthStatus is the health of the live objects of an app, one of Healthy, Progressing or Degraded
+type HealthStatus string
+
+// Condition describes the state of a CloudConfig at a certain point
+type Condition struct {
+	// Type of the condition
+	Type ConditionType `json:"type"`
+	// Status of the condition, one of True, False or Unknown
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// LastTransitionTime is the last time the condition changed status
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the condition's last transition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message with details about the last transition
+	Message string `json:"message,omitempty"`
+}
@@ -0,0 +1,4 @@
+// Package v1alpha2 contains API Schema definitions for the k8 v1alpha2 API group
+// +k8s:deepcopy-gen=package,register
+// +groupName=k8s.jabberwocky.se
+package v1alpha2
@@ -0,0 +1,42 @@
+package cloudconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// revisionLength is the number of hex characters of a revision
+const revisionLength = 12
+
+// getRevision returns the revision of the rendered spec files, i.e. the truncated SHA-256 hash of the YAML
+// documents. The revision changes whenever the objects that would be applied change.
+func getRevision(rendered ...[]byte) string {
+	h := sha256.New()
+	for _, r := range rendered {
+		h.Write(r)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:revisionLength]
+}
+
+// isManual returns true if revisions of the spec must be approved before they are applied
+func isManual(spec *k8v1alpha1.CloudConfigSpec) bool {
+	return spec.SyncPolicy == k8v1alpha1.SyncPolicyManual
+}
+
+// isApproved returns true if the revision may be applied, i.e. if it is the revision last applied or if the
+// approve annotation of the object, a comma separated list of revisions, names the revision
+func isApproved(obj metav1.Object, revision, lastRevision string) bool {
+	if revision == lastRevision {
+		return true
+	}
+	for _, approved := range strings.Split(obj.GetAnnotations()[k8v1alpha1.ApproveAnnotation], ",") {
+		if strings.TrimSpace(approved) == revision {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetRevision(t *testing.T) {
+	revision := getRevision([]byte(testManifest))
+	assert.Len(t, revision, revisionLength)
+	assert.Equal(t, revision, getRevision([]byte(testManifest)), "the revision should be stable")
+	assert.NotEqual(t, revision, getRevision([]byte(testManifest+"---\n")))
+	assert.Equal(t, getRevision([]byte("alphabeta")), getRevision([]byte("alpha"), []byte("beta")))
+}
+
+func TestIsApproved(t *testing.T) {
+	obj := &metav1.ObjectMeta{}
+	assert.False(t, isApproved(obj, "0123456789ab", ""))
+	assert.True(t, isApproved(obj, "0123456789ab", "0123456789ab"), "the last applied revision is approved")
+
+	obj.Annotations = map[string]string{k8v1alpha1.ApproveAnnotation: "0123456789ab"}
+	assert.True(t, isApproved(obj, "0123456789ab", "ba9876543210"))
+	assert.False(t, isApproved(obj, "abcdef012345", "ba9876543210"), "approvals should name the exact revision")
+
+	obj.Annotations[k8v1alpha1.ApproveAnnotation] = "0123456789ab, abcdef012345"
+	assert.True(t, isApproved(obj, "abcdef012345", "ba9876543210"), "any of the listed revisions should be approved")
+	assert.False(t, isApproved(obj, "abcdef", "ba9876543210"))
+}
+
+func TestIsManual(t *testing.T) {
+	assert.False(t, isManual(&k8v1alpha1.CloudConfigSpec{}))
+	assert.False(t, isManual(&k8v1alpha1.CloudConfigSpec{SyncPolicy: k8v1alpha1.SyncPolicyAutomatic}))
+	assert.True(t, isManual(&k8v1alpha1.CloudConfigSpec{SyncPolicy: k8v1alpha1.SyncPolicyManual}))
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
@@ -29,6 +30,24 @@ type CloudConfigClient struct {
 	token    string
 }
 
+// httpError is returned for unhandled HTTP responses from the Cloud Config Server
+type httpError struct {
+	status string
+	code   int
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("Unhandled HTTP response '%s'", e.status)
+}
+
+// IsNotFound returns true if the error is a 404 Not Found response from the Cloud Config Server
+func IsNotFound(err error) bool {
+	if e, ok := err.(*httpError); ok {
+		return e.code == http.StatusNotFound
+	}
+	return false
+}
+
 // Option type for the CloudConfigClient
 type Option func(*CloudConfigClient)
 
@@ -180,6 +199,25 @@ func (client CloudConfigClient) GetConfigFile(file, app, label string, profile .
 	return client.execute(http.MethodGet, url)
 }
 
+// GetVersion returns the version of the configuration reported by the config server, e.g. the commit id of a
+// Git backend. The version is empty if the backend does not report one.
+func (client CloudConfigClient) GetVersion(app, label string, profile ...string) (string, error) {
+	profiles := strings.Join(profile, ",")
+	fallBackIfEmpty(&profiles, "default")
+	body, err := client.execute(http.MethodGet, client.url+app+"/"+profiles+"/"+label)
+	if err != nil {
+		return "", err
+	}
+
+	env := struct {
+		Version string `json:"version"`
+	}{}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return "", fmt.Errorf("could not unmarshal the environment of '%s': %s", app, err.Error())
+	}
+	return env.Version, nil
+}
+
 func (client CloudConfigClient) execute(method string, url string) ([]byte, error) {
 	request, err := http.NewRequest(method, url, nil)
 	if err != nil {
@@ -202,7 +240,7 @@ func (client CloudConfigClient) execute(method string, url string) ([]byte, erro
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Unhandled HTTP response '%s'", resp.Status)
+		return nil, &httpError{status: resp.Status, code: resp.StatusCode}
 	}
 
 	// read the body
@@ -214,34 +252,83 @@ func (client CloudConfigClient) execute(method string, url string) ([]byte, erro
 	return body, nil
 }
 
-func (client CloudConfigClient) getApps(list, app, label string, profile ...string) ([]string, error) {
+// getApps returns the apps listed in the list property of the app configuration. Apps are listed
+// either by name or as objects defining the app name and its label, profiles and spec files.
+func (client CloudConfigClient) getApps(list, app, label string, profile ...string) ([]k8v1alpha1.AppSpec, error) {
+	apps, exists, err := client.getConfigProperty(list, app, label, profile...)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("AppList field '%s' does not exist in the '%s' configuration", list, app)
+	}
+
+	switch apps := apps.(type) {
+	case []interface{}:
+		return interfaceToAppSlice(list, apps)
+	case string:
+		// make sure we return a slice even if there is a single string value
+		return []k8v1alpha1.AppSpec{{Name: apps}}, nil
+	default:
+		err := fmt.Errorf("AppList field '%s' should be an array of strings or objects, found %T", list, apps)
+		return nil, err
+	}
+}
+
+// getStringList returns the string list property of the app configuration, a single string value
+// is returned as a list with one element and nil is returned if the property does not exist.
+func (client CloudConfigClient) getStringList(property, app, label string, profile ...string) ([]string, error) {
+	values, exists, err := client.getConfigProperty(property, app, label, profile...)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	kind := reflect.ValueOf(values).Kind()
+	switch kind {
+	case reflect.Slice:
+		return interfaceToStringSlice(values.([]interface{})), nil
+	case reflect.String:
+		// make sure we return a slice even if there is a single string value
+		return []string{values.(string)}, nil
+	default:
+		err := fmt.Errorf("Field '%s' should be an array of strings, found %T", property, values)
+		return nil, err
+	}
+}
+
+// getConfigProperty returns the property value of the app configuration and true if it exists
+func (client CloudConfigClient) getConfigProperty(property, app, label string, profile ...string) (interface{}, bool, error) {
 	var body []byte
 	body, err := client.GetConfig(app, label, profile...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// marshal the body as a map
 	var m map[string]interface{}
 	if err := json.Unmarshal(body, &m); err != nil {
 		err := fmt.Errorf("Could not marshal configuration as map")
-		return nil, err
-	}
-	if apps, exists := m[list]; exists {
-		kind := reflect.ValueOf(apps).Kind()
-		switch kind {
-		case reflect.Slice:
-			return interfaceToStringSlice(apps.([]interface{})), nil
-		case reflect.String:
-			// make sure we return a slice even if there is a single string value
-			return []string{apps.(string)}, nil
-		default:
-			err := fmt.Errorf("AppList field '%s' should be an array of strings, found %T", list, apps)
-			return nil, err
-		}
+		return nil, false, err
 	}
 
-	return nil, fmt.Errorf("AppList field '%s' does not exist in the '%s' configuration", list, app)
+	value, exists := getProperty(m, property)
+	return value, exists, nil
+}
+
+// getProperty looks up a property by its full name and then by its dot separated path, e.g.
+// `kubernetes.files` is found both as a top level key and as the `files` key of `kubernetes`.
+func getProperty(m map[string]interface{}, name string) (interface{}, bool) {
+	if value, exists := m[name]; exists {
+		return value, true
+	}
+	path := strings.SplitN(name, ".", 2)
+	if len(path) < 2 {
+		return nil, false
+	}
+	if sub, ok := m[path[0]].(map[string]interface{}); ok {
+		return getProperty(sub, path[1])
+	}
+	return nil, false
 }
 
 // Configure authentication and give priority to Bearer vs Basic auth
@@ -267,6 +354,31 @@ func interfaceToStringSlice(interfaces []interface{}) []string {
 	return strings
 }
 
+// interfaceToAppSlice converts the interface slice of an app list to a slice of AppSpecs
+func interfaceToAppSlice(list string, interfaces []interface{}) ([]k8v1alpha1.AppSpec, error) {
+	apps := make([]k8v1alpha1.AppSpec, len(interfaces))
+	for i, v := range interfaces {
+		switch v := v.(type) {
+		case string:
+			apps[i] = k8v1alpha1.AppSpec{Name: v}
+		case map[string]interface{}:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(b, &apps[i]); err != nil {
+				return nil, fmt.Errorf("AppList field '%s' entry %d is not a valid app: %s", list, i, err.Error())
+			}
+		default:
+			return nil, fmt.Errorf("AppList field '%s' entry %d should be a string or an object, found %T", list, i, v)
+		}
+		if apps[i].Name == "" {
+			return nil, fmt.Errorf("AppList field '%s' entry %d does not define the app name", list, i)
+		}
+	}
+	return apps, nil
+}
+
 // appendYAMLDoc appends a doc to an existing YAML configuration that is assumed to be valid YAML
 func appendYAMLDoc(app string, config, doc []byte) []byte {
 	delim := []byte("---\n")
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"testing"
 
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	httpmock "gopkg.in/jarcoal/httpmock.v1"
 )
@@ -137,6 +138,27 @@ func TestGetConfigFile(t *testing.T) {
 	assert.Equal(t, `SOME_FILE_CONTENT`, string(config))
 }
 
+func TestGetVersion(t *testing.T) {
+	mockHTTPClientFactory()
+	defer restoreDefaultHTTPClientFactory()
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1,p2/label", httpmock.NewStringResponder(
+			200, `{"name": "app", "profiles": ["p1", "p2"], "label": "label", "version": "3f2a9c6", "propertySources": []}`))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/default/label", httpmock.NewStringResponder(
+			200, `{"name": "app", "profiles": ["default"], "label": "label", "version": null, "propertySources": []}`))
+
+	client, _ := New(TestBaseURL)
+	version, err := client.GetVersion("app", "label", "p1", "p2")
+	assert.NoError(t, err)
+	assert.Equal(t, "3f2a9c6", version)
+
+	version, err = client.GetVersion("app", "label")
+	assert.NoError(t, err)
+	assert.Empty(t, version, "backends without versions should be supported")
+}
+
 func TestGetApps(t *testing.T) {
 	mockHTTPClientFactory()
 	defer restoreDefaultHTTPClientFactory()
@@ -148,7 +170,7 @@ func TestGetApps(t *testing.T) {
 			200, `{"services": [ "app-1", "app-2" ], "key": "value"}`))
 	apps, err := client.getApps("services", "app", "label", "p1", "p2")
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"app-1", "app-2"}, apps)
+	assert.Equal(t, []k8v1alpha1.AppSpec{{Name: "app-1"}, {Name: "app-2"}}, apps)
 
 	// string with a single app
 	httpmock.RegisterResponder(
@@ -156,7 +178,26 @@ func TestGetApps(t *testing.T) {
 			200, `{"services": "app-1", "key": "value"}`))
 	apps, err = client.getApps("services", "app", "label", "p1", "p2")
 	assert.NoError(t, err)
-	assert.Equal(t, []string{"app-1"}, apps)
+	assert.Equal(t, []k8v1alpha1.AppSpec{{Name: "app-1"}}, apps)
+
+	// list with app names and objects
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"label/app-p1,p2.json", httpmock.NewStringResponder(
+			200, `{"services": [ "app-1", { "name": "app-2", "label": "release", "profiles": [ "canary" ] } ]}`))
+	apps, err = client.getApps("services", "app", "label", "p1", "p2")
+	assert.NoError(t, err)
+	assert.Equal(t, []k8v1alpha1.AppSpec{
+		{Name: "app-1"},
+		{Name: "app-2", Label: "release", Profiles: []string{"canary"}},
+	}, apps)
+
+	// object without a name
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"label/app-p1,p2.json", httpmock.NewStringResponder(
+			200, `{"services": [ { "label": "release" } ]}`))
+	apps, err = client.getApps("services", "app", "label", "p1", "p2")
+	assert.Error(t, err)
+	assert.Nil(t, apps)
 
 	// object instead of string
 	httpmock.RegisterResponder(
@@ -189,6 +230,49 @@ func TestGetApps(t *testing.T) {
 
 }
 
+func TestIsNotFound(t *testing.T) {
+	mockHTTPClientFactory()
+	defer restoreDefaultHTTPClientFactory()
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/missing.yaml", httpmock.NewStringResponder(404, ""))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/forbidden.yaml", httpmock.NewStringResponder(403, ""))
+
+	client, _ := New(TestBaseURL)
+	_, err := client.GetConfigFile("missing.yaml", "app", "label", "p1")
+	assert.True(t, IsNotFound(err), "404 responses should be reported as not found")
+
+	_, err = client.GetConfigFile("forbidden.yaml", "app", "label", "p1")
+	assert.Error(t, err)
+	assert.False(t, IsNotFound(err), "403 responses should not be reported as not found")
+}
+
+func TestGetProperty(t *testing.T) {
+	m := map[string]interface{}{
+		"services":         "alpha",
+		"kubernetes.files": "flat.yaml",
+		"kubernetes": map[string]interface{}{
+			"spec": map[string]interface{}{"files": "nested.yaml"},
+		},
+	}
+
+	value, exists := getProperty(m, "services")
+	assert.True(t, exists)
+	assert.Equal(t, "alpha", value)
+
+	value, exists = getProperty(m, "kubernetes.files")
+	assert.True(t, exists)
+	assert.Equal(t, "flat.yaml", value, "full property names should take precedence")
+
+	value, exists = getProperty(m, "kubernetes.spec.files")
+	assert.True(t, exists)
+	assert.Equal(t, "nested.yaml", value)
+
+	_, exists = getProperty(m, "kubernetes.spec.missing")
+	assert.False(t, exists)
+}
+
 func TestInterfaceToStringSlice(t *testing.T) {
 	i := []interface{}{"apa", "banan"}
 	s := interfaceToStringSlice(i)
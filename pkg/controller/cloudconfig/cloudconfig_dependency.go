@@ -0,0 +1,116 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// getDependencies returns the apps each app depends on as listed by the dependsOn property of its config, apps
+// without the property have no dependencies. Dependencies must be apps of the app list.
+func getDependencies(client *CloudConfigClient, apps []*k8v1alpha1.CloudConfigSpec) (map[string][]string, error) {
+	names := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		names[app.AppName] = true
+	}
+
+	dependencies := make(map[string][]string, len(apps))
+	for _, app := range apps {
+		if app.DependsOn == "" {
+			continue
+		}
+		list, err := client.getStringList(app.DependsOn, app.AppName, app.Label, app.Profile...)
+		if err != nil {
+			return nil, err
+		}
+		unique := make(map[string]bool, len(list))
+		for _, dependency := range list {
+			if !names[dependency] {
+				return nil, fmt.Errorf("app '%s' depends on app '%s' that is not in the app list", app.AppName, dependency)
+			}
+			if !unique[dependency] {
+				unique[dependency] = true
+				dependencies[app.AppName] = append(dependencies[app.AppName], dependency)
+			}
+		}
+	}
+	return dependencies, nil
+}
+
+// sortByDependencies returns the apps in topological order where each app follows the apps it depends on, apps
+// that do not depend on each other keep their order. An error naming the apps of a cycle is returned if the
+// dependencies are cyclic.
+func sortByDependencies(
+	apps []*k8v1alpha1.CloudConfigSpec,
+	dependencies map[string][]string) ([]*k8v1alpha1.CloudConfigSpec, error) {
+
+	sorted := make([]*k8v1alpha1.CloudConfigSpec, 0, len(apps))
+	placed := make(map[string]bool, len(apps))
+	remaining := append([]*k8v1alpha1.CloudConfigSpec(nil), apps...)
+	for len(remaining) > 0 {
+		next := -1
+		for i, app := range remaining {
+			if isResolved(dependencies[app.AppName], placed) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("apps have cyclic dependencies: %s", findCycle(remaining[0].AppName, dependencies, placed))
+		}
+		sorted = append(sorted, remaining[next])
+		placed[remaining[next].AppName] = true
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+	return sorted, nil
+}
+
+// isResolved returns true if all dependencies have been placed
+func isResolved(dependencies []string, placed map[string]bool) bool {
+	for _, dependency := range dependencies {
+		if !placed[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle returns the path of a dependency cycle reached from the app, e.g. 'a -> b -> a'. Every app that has
+// not been placed depends on another app that has not been placed.
+func findCycle(app string, dependencies map[string][]string, placed map[string]bool) string {
+	path := make([]string, 0, 2)
+	visited := make(map[string]int)
+	for {
+		if i, found := visited[app]; found {
+			return strings.Join(append(path[i:], app), " -> ")
+		}
+		visited[app] = len(path)
+		path = append(path, app)
+		for _, dependency := range dependencies[app] {
+			if !placed[dependency] {
+				app = dependency
+				break
+			}
+		}
+	}
+}
+
+// getDependencyMessage returns a message naming the first dependency of the app among its siblings that is not
+// healthy, empty if all dependencies are healthy
+func getDependencyMessage(siblings []k8v1alpha1.CloudConfigApp, dependsOn []string) string {
+	apps := make(map[string]*k8v1alpha1.CloudConfigApp, len(siblings))
+	for i := range siblings {
+		apps[siblings[i].Labels[k8v1alpha1.AppLabel]] = &siblings[i]
+	}
+	for _, dependency := range dependsOn {
+		sibling, found := apps[dependency]
+		if !found {
+			return fmt.Sprintf("app '%s' does not exist", dependency)
+		}
+		if health := getAssessedHealth(sibling.Status.Health); health != k8v1alpha1.HealthHealthy {
+			return fmt.Sprintf("app '%s' is %s", dependency, health)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestApps(names ...string) []*k8v1alpha1.CloudConfigSpec {
+	apps := make([]*k8v1alpha1.CloudConfigSpec, len(names))
+	for i, name := range names {
+		apps[i] = &k8v1alpha1.CloudConfigSpec{AppName: name}
+	}
+	return apps
+}
+
+func getAppSpecNames(apps []*k8v1alpha1.CloudConfigSpec) []string {
+	names := make([]string, len(apps))
+	for i, app := range apps {
+		names[i] = app.AppName
+	}
+	return names
+}
+
+func TestSortByDependencies(t *testing.T) {
+	apps := newTestApps("alpha", "beta", "broker", "gamma")
+
+	sorted, err := sortByDependencies(apps, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alpha", "beta", "broker", "gamma"}, getAppSpecNames(sorted), "apps without dependencies should keep their order")
+
+	dependencies := map[string][]string{
+		"alpha": {"broker"},
+		"beta":  {"alpha", "gamma"},
+	}
+	sorted, err = sortByDependencies(apps, dependencies)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"broker", "alpha", "gamma", "beta"}, getAppSpecNames(sorted), "apps should follow their dependencies")
+	assert.Equal(t, []string{"alpha", "beta", "broker", "gamma"}, getAppSpecNames(apps), "the apps should not be modified")
+
+	dependencies["broker"] = []string{"beta"}
+	sorted, err = sortByDependencies(apps, dependencies)
+	assert.EqualError(t, err, "apps have cyclic dependencies: alpha -> broker -> beta -> alpha")
+	assert.Nil(t, sorted)
+
+	_, err = sortByDependencies(apps, map[string][]string{"gamma": {"gamma"}})
+	assert.EqualError(t, err, "apps have cyclic dependencies: gamma -> gamma", "apps should not depend on themselves")
+}
+
+func TestResolveAppsDependencies(t *testing.T) {
+	mockHTTPClientFactory()
+	defer restoreDefaultHTTPClientFactory()
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/cluster-dev.json", httpmock.NewStringResponder(
+			200, `{"services": [ "consumer", "broker", "producer" ]}`))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/broker-dev.json", httpmock.NewStringResponder(200, `{}`))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/consumer-dev.json", httpmock.NewStringResponder(
+			200, `{"kubernetes": {"dependsOn": [ "broker", "producer", "broker" ]}}`))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/producer-dev.json", httpmock.NewStringResponder(
+			200, `{"kubernetes": {"dependsOn": "broker"}}`))
+
+	client, _ := New(TestBaseURL)
+	spec := k8v1alpha1.NewCloudConfigSpec()
+	spec.AppName = "cluster"
+	spec.AppList = "services"
+	spec.Profile = []string{"dev"}
+	spec.DependsOn = "kubernetes.dependsOn"
+
+	apps, dependencies, err := resolveApps(client, spec)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"broker", "producer", "consumer"}, getAppSpecNames(apps))
+	assert.Equal(t, map[string][]string{
+		"consumer": {"broker", "producer"},
+		"producer": {"broker"},
+	}, dependencies, "dependencies should be listed once")
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/broker-dev.json", httpmock.NewStringResponder(
+			200, `{"kubernetes": {"dependsOn": "database"}}`))
+	_, _, err = resolveApps(client, spec)
+	assert.EqualError(t, err, "app 'broker' depends on app 'database' that is not in the app list")
+}
+
+func TestGetDependencyMessage(t *testing.T) {
+	newSibling := func(app string, health k8v1alpha1.HealthStatus) k8v1alpha1.CloudConfigApp {
+		return k8v1alpha1.CloudConfigApp{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "cluster-" + app,
+				Labels: map[string]string{k8v1alpha1.AppLabel: app},
+			},
+			Status: k8v1alpha1.CloudConfigAppStatus{Health: health},
+		}
+	}
+	siblings := []k8v1alpha1.CloudConfigApp{
+		newSibling("broker", k8v1alpha1.HealthHealthy),
+		newSibling("producer", ""),
+	}
+
+	assert.Empty(t, getDependencyMessage(siblings, nil), "apps without dependencies should not be held back")
+	assert.Empty(t, getDependencyMessage(siblings, []string{"broker"}))
+	assert.Equal(t, "app 'producer' is Progressing", getDependencyMessage(siblings, []string{"broker", "producer"}),
+		"dependencies that have not been assessed should hold back the app")
+	assert.Equal(t, "app 'database' does not exist", getDependencyMessage(siblings, []string{"database"}))
+}
@@ -0,0 +1,372 @@
+package cloudconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// diffAction is the action a synchronization would take for an object
+type diffAction string
+
+const (
+	diffCreate    diffAction = "Create"
+	diffUpdate    diffAction = "Update"
+	diffPrune     diffAction = "Prune"
+	diffUnchanged diffAction = "Unchanged"
+)
+
+// objectDiff is the difference between the desired state of an object in the manifest and its live state
+type objectDiff struct {
+	APIVersion string      `json:"apiVersion"`
+	Kind       string      `json:"kind"`
+	Namespace  string      `json:"namespace,omitempty"`
+	Name       string      `json:"name"`
+	Action     diffAction  `json:"action"`
+	Fields     []fieldDiff `json:"fields,omitempty"`
+}
+
+// fieldDiff is the difference of a single field, Live is nil for fields that would be added
+type fieldDiff struct {
+	Path    string      `json:"path"`
+	Live    interface{} `json:"live,omitempty"`
+	Desired interface{} `json:"desired"`
+}
+
+// dryRunSyncPrefix is the prefix of sync annotation values that request a dry run
+const dryRunSyncPrefix = "dry-run"
+
+// isDryRun returns true if the spec or the sync request asks for a dry run
+func isDryRun(spec *k8v1alpha1.CloudConfigSpec, syncRequest string) bool {
+	return spec.Mode == k8v1alpha1.SyncModeDryRun || strings.HasPrefix(syncRequest, dryRunSyncPrefix)
+}
+
+// dryRunConfigMapKey is the key of the ConfigMap entry holding the diff of a dry run
+const dryRunConfigMapKey = "diff.json"
+
+// DryRunLabel identifies the ConfigMap holding the diff of the last dry run of a CloudConfigApp or
+// ClusterCloudConfig, the ConfigMap is deliberately not labelled as an object of the app so that it is not pruned
+const DryRunLabel = "k8s.jabberwocky.se/dry-run"
+
+// diffManifest computes the diff of the manifest against the live objects without changing the cluster. Live
+// objects of the kinds matching the selector that are not in the manifest are reported as pruned. The live objects
+// are read as the impersonated user, if any.
+func diffManifest(namespace, selector, user string, m manifest, kinds []string) ([]objectDiff, error) {
+	var live []*unstructured.Unstructured
+	if len(m) > 0 {
+		rendered, err := m.toYAML()
+		if err != nil {
+			return nil, err
+		}
+		live, err = getObjects(namespace, user, []string{"-f", "-", "--ignore-not-found"}, rendered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	var selected []*unstructured.Unstructured
+	if len(kinds) > 0 {
+		selected, err = getObjects(namespace, user, []string{strings.Join(kinds, ","), "--selector=" + selector}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return diffObjects(namespace, m, live, selected), nil
+}
+
+// getObjects returns the live objects listed by `kubectl get` with the arguments as the impersonated user, if any
+func getObjects(namespace, user string, args []string, stdin []byte) ([]*unstructured.Unstructured, error) {
+	cmdArgs := make([]string, 0, len(args)+5)
+	if namespace != "" {
+		cmdArgs = append(cmdArgs, "--namespace="+namespace)
+	}
+	cmdArgs = append(cmdArgs, getImpersonationArgs(user)...)
+	cmdArgs = append(cmdArgs, "get")
+	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, "--output=json")
+	cmd := execCommand("kubectl", cmdArgs...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	log.Info(strings.Join(cmd.Args, " "))
+	out, err := cmd.Output()
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Could not get objects in '%s'", namespace), "command", strings.Join(cmd.Args, " "))
+		return nil, err
+	}
+	return parseObjects(out)
+}
+
+// parseObjects parses the JSON output of `kubectl get`, i.e. a single object or a List of objects
+func parseObjects(out []byte) ([]*unstructured.Unstructured, error) {
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+
+	obj := make(map[string]interface{})
+	if err := json.Unmarshal(out, &obj); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(fmt.Sprint(obj["kind"]), "List") {
+		return []*unstructured.Unstructured{{Object: obj}}, nil
+	}
+
+	items, _ := obj["items"].([]interface{})
+	objects := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if o, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, &unstructured.Unstructured{Object: o})
+		}
+	}
+	return objects, nil
+}
+
+// objectKey identifies an object independently of the version of its API group
+type objectKey struct {
+	group, kind, namespace, name string
+}
+
+func newObjectKey(obj *unstructured.Unstructured, namespace string) objectKey {
+	gvk := obj.GroupVersionKind()
+	return objectKey{group: gvk.Group, kind: gvk.Kind, namespace: namespace, name: obj.GetName()}
+}
+
+// diffObjects computes the diff of the desired objects of the manifest against the live objects and reports the
+// selected live objects that are not in the manifest as pruned. Desired objects without a namespace are matched
+// with live objects in the given namespace or cluster scoped live objects.
+func diffObjects(namespace string, m manifest, live, selected []*unstructured.Unstructured) []objectDiff {
+	liveObjects := make(map[objectKey]*unstructured.Unstructured, len(live)+len(selected))
+	for _, obj := range append(selected, live...) {
+		liveObjects[newObjectKey(obj, obj.GetNamespace())] = obj
+	}
+
+	diffs := make([]objectDiff, 0, len(m)+len(selected))
+	desired := make(map[objectKey]bool, len(m))
+	for _, obj := range m {
+		d := objectDiff{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		}
+
+		var liveObj *unstructured.Unstructured
+		keys := []objectKey{newObjectKey(obj, obj.GetNamespace())}
+		if obj.GetNamespace() == "" && namespace != "" {
+			keys = []objectKey{newObjectKey(obj, namespace), newObjectKey(obj, "")}
+		}
+		for _, key := range keys {
+			desired[key] = true
+			if liveObj == nil {
+				liveObj = liveObjects[key]
+			}
+		}
+
+		switch {
+		case liveObj == nil:
+			d.Action = diffCreate
+		default:
+			d.Namespace = liveObj.GetNamespace()
+			d.Fields = diffFields("", obj.Object, liveObj.Object)
+			if isSecret(obj) {
+				d.Fields = redactSecretFields(d.Fields)
+			}
+			d.Action = diffUnchanged
+			if len(d.Fields) > 0 {
+				d.Action = diffUpdate
+			}
+		}
+		diffs = append(diffs, d)
+	}
+
+	for _, obj := range selected {
+		if desired[newObjectKey(obj, obj.GetNamespace())] {
+			continue
+		}
+		diffs = append(diffs, objectDiff{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			Action:     diffPrune,
+		})
+	}
+	return diffs
+}
+
+// diffFields returns the fields of the desired value that differ from the live value. Fields that are only
+// present in the live value, e.g. defaults and the status, are ignored as they are not changed by an apply.
+func diffFields(path string, desired, live interface{}) []fieldDiff {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(d))
+		for key := range d {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		diffs := []fieldDiff{}
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			lv, found := l[key]
+			if !found {
+				diffs = append(diffs, fieldDiff{Path: fieldPath, Desired: d[key]})
+				continue
+			}
+			diffs = append(diffs, diffFields(fieldPath, d[key], lv)...)
+		}
+		return diffs
+
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			break
+		}
+		diffs := []fieldDiff{}
+		for i := range d {
+			diffs = append(diffs, diffFields(fmt.Sprintf("%s[%d]", path, i), d[i], l[i])...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(desired, live) {
+		return nil
+	}
+	return []fieldDiff{{Path: path, Live: live, Desired: desired}}
+}
+
+// redactedValue replaces the values of the data of Secrets in diffs
+const redactedValue = "<redacted>"
+
+// isSecret returns true if the object is a Secret
+func isSecret(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// redactSecretFields replaces the values of the data and stringData fields of a Secret so that the diff only
+// reports which keys change and the dry run does not disclose the Secret to readers of its ConfigMap
+func redactSecretFields(fields []fieldDiff) []fieldDiff {
+	redacted := make([]fieldDiff, 0, len(fields))
+	for _, f := range fields {
+		if f.Path != "data" && f.Path != "stringData" &&
+			!strings.HasPrefix(f.Path, "data.") && !strings.HasPrefix(f.Path, "stringData.") {
+			redacted = append(redacted, f)
+			continue
+		}
+		// data added as a whole is reported key by key
+		if values, ok := f.Desired.(map[string]interface{}); ok && !strings.Contains(f.Path, ".") {
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				redacted = append(redacted, fieldDiff{Path: f.Path + "." + key, Desired: redactedValue})
+			}
+			continue
+		}
+		if f.Live != nil {
+			f.Live = redactedValue
+		}
+		f.Desired = redactedValue
+		redacted = append(redacted, f)
+	}
+	return redacted
+}
+
+// newDryRunStatus summarizes the diffs of a dry run
+func newDryRunStatus(diffs []objectDiff, configMap string) *k8v1alpha1.DryRunStatus {
+	status := &k8v1alpha1.DryRunStatus{Time: metav1.Now(), ConfigMap: configMap}
+	for _, d := range diffs {
+		switch d.Action {
+		case diffCreate:
+			status.Created++
+		case diffUpdate:
+			status.Updated++
+		case diffPrune:
+			status.Pruned++
+		case diffUnchanged:
+			status.Unchanged++
+		}
+	}
+	return status
+}
+
+// getDryRunConfigMapName returns the name of the ConfigMap holding the diff of the last dry run of the owner
+func getDryRunConfigMapName(owner string) string {
+	return owner + "-dry-run"
+}
+
+// newDryRunConfigMap returns the ConfigMap holding the diffs of the dry run of the owner
+func newDryRunConfigMap(owner, namespace string, diffs []objectDiff) (*corev1.ConfigMap, error) {
+	data, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getDryRunConfigMapName(owner),
+			Namespace: namespace,
+			Labels:    map[string]string{DryRunLabel: owner},
+		},
+		Data: map[string]string{dryRunConfigMapKey: string(data)},
+	}, nil
+}
+
+// createOrUpdateConfigMap creates the ConfigMap if it does not exist or updates its labels, owners and data
+func createOrUpdateConfigMap(k8client client.Client, cm *corev1.ConfigMap) error {
+	existing := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: cm.Name, Namespace: cm.Namespace}
+	if err := k8client.Get(context.TODO(), name, existing); err != nil {
+		if k8errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("Creating ConfigMap '%s'", cm.Name), "Namespace", cm.Namespace)
+			return k8client.Create(context.TODO(), cm)
+		}
+		return err
+	}
+
+	existing.Labels = cm.Labels
+	existing.OwnerReferences = cm.OwnerReferences
+	existing.Data = cm.Data
+	existing.BinaryData = cm.BinaryData
+	return k8client.Update(context.TODO(), existing)
+}
+
+// mergeKinds returns the sorted union of the kinds
+func mergeKinds(kinds ...[]string) []string {
+	unique := make(map[string]bool)
+	merged := make([]string, 0, 10)
+	for _, list := range kinds {
+		for _, kind := range list {
+			if !unique[kind] {
+				unique[kind] = true
+				merged = append(merged, kind)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
@@ -0,0 +1,219 @@
+package cloudconfig
+
+import (
+	"os/exec"
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testLiveObjects = `{
+	"apiVersion": "v1",
+	"kind": "List",
+	"items": [
+		{
+			"apiVersion": "v1",
+			"kind": "Namespace",
+			"metadata": {"name": "test", "uid": "1"},
+			"status": {"phase": "Active"}
+		},
+		{
+			"apiVersion": "apps/v1",
+			"kind": "Deployment",
+			"metadata": {"name": "alpha", "namespace": "test", "labels": {"app": "beta"}},
+			"spec": {"replicas": 1}
+		}
+	]
+}`
+
+func mustParseObjects(t *testing.T, out string) []*unstructured.Unstructured {
+	objects, err := parseObjects([]byte(out))
+	if err != nil {
+		t.Fatalf("could not parse objects: %s", err.Error())
+	}
+	return objects
+}
+
+func TestParseObjects(t *testing.T) {
+	objects := mustParseObjects(t, testLiveObjects)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "Deployment", objects[1].GetKind())
+
+	objects = mustParseObjects(t, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "alpha"}}`)
+	assert.Len(t, objects, 1, "a single object should be parsed")
+
+	assert.Empty(t, mustParseObjects(t, " \n"), "empty output should not be an error")
+
+	_, err := parseObjects([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestDiffFields(t *testing.T) {
+	desired := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "alpha", "labels": map[string]interface{}{"app": "alpha"}},
+		"spec": map[string]interface{}{
+			"replicas": float64(2),
+			"ports":    []interface{}{map[string]interface{}{"port": float64(80)}},
+			"paused":   false,
+		},
+	}
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "alpha", "uid": "1", "labels": map[string]interface{}{"app": "beta"}},
+		"spec": map[string]interface{}{
+			"replicas": float64(1),
+			"ports":    []interface{}{map[string]interface{}{"port": float64(80), "protocol": "TCP"}},
+		},
+		"status": map[string]interface{}{"replicas": float64(1)},
+	}
+
+	diffs := diffFields("", desired, live)
+	assert.Len(t, diffs, 3, "fields only present in the live object should be ignored")
+	assert.Equal(t, fieldDiff{Path: "metadata.labels.app", Live: "beta", Desired: "alpha"}, diffs[0])
+	assert.Equal(t, fieldDiff{Path: "spec.paused", Desired: false}, diffs[1])
+	assert.Equal(t, fieldDiff{Path: "spec.replicas", Live: float64(1), Desired: float64(2)}, diffs[2])
+
+	assert.Empty(t, diffFields("", desired, desired))
+
+	diffs = diffFields("args", []interface{}{"a", "b"}, []interface{}{"a"})
+	assert.Len(t, diffs, 1, "lists of different length should be replaced")
+	assert.Equal(t, "args", diffs[0].Path)
+}
+
+func TestDiffObjects(t *testing.T) {
+	m, err := parseManifest([]byte(testManifest + `
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: gamma
+`))
+	assert.NoError(t, err)
+
+	live := mustParseObjects(t, testLiveObjects)
+	selected := mustParseObjects(t, `{"apiVersion": "v1", "kind": "List", "items": [
+		{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "alpha", "namespace": "test"}},
+		{"apiVersion": "extensions/v1beta1", "kind": "Deployment", "metadata": {"name": "delta", "namespace": "test"}}
+	]}`)
+
+	diffs := diffObjects("test", m, live, selected)
+	assert.Len(t, diffs, 4)
+
+	assert.Equal(t, "test", diffs[0].Name)
+	assert.Equal(t, diffUnchanged, diffs[0].Action, "cluster scoped objects should be matched")
+
+	assert.Equal(t, "alpha", diffs[1].Name)
+	assert.Equal(t, "test", diffs[1].Namespace, "objects should be matched in the target namespace")
+	assert.Equal(t, diffUpdate, diffs[1].Action)
+	assert.Len(t, diffs[1].Fields, 1)
+	assert.Equal(t, "metadata.labels.app", diffs[1].Fields[0].Path)
+
+	assert.Equal(t, "gamma", diffs[2].Name)
+	assert.Equal(t, diffCreate, diffs[2].Action)
+
+	assert.Equal(t, "delta", diffs[3].Name)
+	assert.Equal(t, diffPrune, diffs[3].Action, "selected objects not in the manifest should be pruned")
+
+	status := newDryRunStatus(diffs, "app-dry-run")
+	assert.Equal(t, 1, status.Created)
+	assert.Equal(t, 1, status.Updated)
+	assert.Equal(t, 1, status.Pruned)
+	assert.Equal(t, 1, status.Unchanged)
+	assert.Equal(t, "app-dry-run", status.ConfigMap)
+}
+
+func TestDiffManifestError(t *testing.T) {
+	execCommand = fakeExecCommand
+	defer func() { execCommand = exec.Command }()
+
+	m, err := parseManifest([]byte(testManifest))
+	assert.NoError(t, err)
+	_, err = diffManifest("", "app=alpha", "", m, m.getKinds())
+	assert.Error(t, err, "errors getting the live objects should be returned")
+}
+
+func TestDiffManifestImpersonation(t *testing.T) {
+	var commands [][]string
+	execCommand = recordExecCommand(&commands)
+	defer func() { execCommand = exec.Command }()
+
+	m, err := parseManifest([]byte(testManifest))
+	assert.NoError(t, err)
+	_, err = diffManifest("test", "app=alpha", "system:serviceaccount:test:deployer", m, []string{"deployment.v1.apps"})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"kubectl", "--namespace=test", "--as=system:serviceaccount:test:deployer", "get", "-f", "-", "--ignore-not-found", "--output=json"},
+		{"kubectl", "--namespace=test", "--as=system:serviceaccount:test:deployer", "get", "deployment.v1.apps", "--selector=app=alpha", "--output=json"},
+	}, commands, "the live objects should be read as the ServiceAccount")
+}
+
+func TestDiffObjectsSecret(t *testing.T) {
+	m, err := parseManifest([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  labels: { app: alpha }
+data:
+  password: bmV3
+  username: YWRtaW4=
+stringData:
+  token: s3cr3t
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: tls
+data:
+  tls.key: a2V5
+`))
+	assert.NoError(t, err)
+	live := mustParseObjects(t, `{"apiVersion": "v1", "kind": "List", "items": [
+		{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "credentials", "namespace": "test"},
+		 "data": {"password": "b2xk", "username": "YWRtaW4="}},
+		{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "tls", "namespace": "test"}}
+	]}`)
+
+	diffs := diffObjects("test", m, live, nil)
+	assert.Equal(t, []fieldDiff{
+		{Path: "data.password", Live: redactedValue, Desired: redactedValue},
+		{Path: "metadata.labels", Desired: map[string]interface{}{"app": "alpha"}},
+		{Path: "stringData.token", Desired: redactedValue},
+	}, diffs[0].Fields, "only the changed keys of Secrets should be reported")
+	assert.Equal(t, []fieldDiff{{Path: "data.tls.key", Desired: redactedValue}}, diffs[1].Fields,
+		"the keys of added data should be reported")
+
+	cm, err := newDryRunConfigMap("alpha", "test", diffs)
+	assert.NoError(t, err)
+	for _, value := range []string{"bmV3", "b2xk", "s3cr3t", "a2V5"} {
+		assert.NotContains(t, cm.Data[dryRunConfigMapKey], value, "Secret values should not be disclosed")
+	}
+}
+
+func TestIsDryRun(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{}
+	assert.False(t, isDryRun(spec, ""))
+	assert.False(t, isDryRun(spec, "force-1557939600"))
+	assert.True(t, isDryRun(spec, "dry-run-1557939600"))
+
+	spec.Mode = k8v1alpha1.SyncModeDryRun
+	assert.True(t, isDryRun(spec, ""))
+}
+
+func TestNewDryRunConfigMap(t *testing.T) {
+	diffs := []objectDiff{{APIVersion: "v1", Kind: "ConfigMap", Name: "gamma", Action: diffCreate}}
+	cm, err := newDryRunConfigMap("alpha", "test", diffs)
+	assert.NoError(t, err)
+	assert.Equal(t, "alpha-dry-run", cm.Name)
+	assert.Equal(t, "test", cm.Namespace)
+	assert.Equal(t, "alpha", cm.Labels[DryRunLabel])
+	assert.NotContains(t, cm.Labels, k8v1alpha1.CloudConfigAppLabel, "the ConfigMap should not be pruned")
+	assert.Contains(t, cm.Data[dryRunConfigMapKey], `"action": "Create"`)
+}
+
+func TestMergeKinds(t *testing.T) {
+	assert.Equal(t,
+		[]string{"configmap", "deployment.v1.apps"},
+		mergeKinds([]string{"deployment.v1.apps", "configmap"}, []string{"configmap"}))
+	assert.Empty(t, mergeKinds())
+}
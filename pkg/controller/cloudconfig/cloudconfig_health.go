@@ -0,0 +1,244 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// healthRanks orders the health of apps from best to worst, unknown health ranks as progressing
+var healthRanks = map[k8v1alpha1.HealthStatus]int{
+	k8v1alpha1.HealthHealthy:     0,
+	k8v1alpha1.HealthProgressing: 1,
+	k8v1alpha1.HealthDegraded:    2,
+}
+
+// isWorse returns true if the health is worse than the other health
+func isWorse(health, other k8v1alpha1.HealthStatus) bool {
+	return getHealthRank(health) > getHealthRank(other)
+}
+
+// getHealthRank returns the rank of the health, an empty health has not yet been assessed and is progressing
+func getHealthRank(health k8v1alpha1.HealthStatus) int {
+	if rank, found := healthRanks[health]; found {
+		return rank
+	}
+	return healthRanks[k8v1alpha1.HealthProgressing]
+}
+
+// assessHealth returns the health of the live objects of the manifest and a message describing why they are not
+// healthy. Deployments, StatefulSets, DaemonSets and Jobs are assessed by their rollout, other objects by their
+// Ready or Available condition, if any. The live objects are read as the impersonated user, if any.
+func assessHealth(namespace, user string, m manifest) (k8v1alpha1.HealthStatus, string, error) {
+	if len(m) == 0 {
+		return k8v1alpha1.HealthHealthy, "", nil
+	}
+
+	rendered, err := m.toYAML()
+	if err != nil {
+		return "", "", err
+	}
+	live, err := getObjects(namespace, user, []string{"-f", "-", "--ignore-not-found"}, rendered)
+	if err != nil {
+		return "", "", err
+	}
+	if len(live) < len(m) {
+		return k8v1alpha1.HealthDegraded, fmt.Sprintf("%d of %d objects found", len(live), len(m)), nil
+	}
+
+	health, message := k8v1alpha1.HealthHealthy, ""
+	for _, obj := range live {
+		if h, msg := getHealth(obj); isWorse(h, health) {
+			health, message = h, msg
+		}
+	}
+	return health, message, nil
+}
+
+// getHealth returns the health of the live object and a message describing why it is not healthy
+func getHealth(obj *unstructured.Unstructured) (k8v1alpha1.HealthStatus, string) {
+	switch obj.GetKind() {
+	case "Deployment":
+		return getDeploymentHealth(obj)
+	case "StatefulSet":
+		return getStatefulSetHealth(obj)
+	case "DaemonSet":
+		return getDaemonSetHealth(obj)
+	case "Job":
+		return getJobHealth(obj)
+	case "CustomResourceDefinition":
+		return getConditionHealth(obj, "Established")
+	}
+	return getConditionHealth(obj, "Ready", "Available")
+}
+
+// getDeploymentHealth returns the health of the live Deployment, it is healthy if its rollout is complete and all
+// its replicas are available
+func getDeploymentHealth(obj *unstructured.Unstructured) (k8v1alpha1.HealthStatus, string) {
+	name := obj.GetName()
+	if !isObserved(obj) {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("Deployment '%s' is waiting for its rollout to be observed", name)
+	}
+
+	if condition := getCondition(obj, "Progressing"); condition["reason"] == "ProgressDeadlineExceeded" {
+		return k8v1alpha1.HealthDegraded, fmt.Sprintf("Deployment '%s' exceeded its progress deadline", name)
+	}
+
+	replicas := getInt64(obj, 1, "spec", "replicas")
+	updated := getInt64(obj, 0, "status", "updatedReplicas")
+	current := getInt64(obj, 0, "status", "replicas")
+	available := getInt64(obj, 0, "status", "availableReplicas")
+	switch {
+	case updated < replicas:
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("Deployment '%s' has %d of %d updated replicas", name, updated, replicas)
+	case current > updated:
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("Deployment '%s' has %d old replicas pending termination", name, current-updated)
+	case available < updated:
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("Deployment '%s' has %d of %d updated replicas available", name, available, updated)
+	}
+	return k8v1alpha1.HealthHealthy, ""
+}
+
+// getStatefulSetHealth returns the health of the live StatefulSet, it is healthy if the replicas up to the
+// partition of a rolling update are updated and all replicas are ready
+func getStatefulSetHealth(obj *unstructured.Unstructured) (k8v1alpha1.HealthStatus, string) {
+	name := obj.GetName()
+	if !isObserved(obj) {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("StatefulSet '%s' is waiting for its rollout to be observed", name)
+	}
+
+	replicas := getInt64(obj, 1, "spec", "replicas")
+	if strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type"); strategy != "OnDelete" {
+		updated := getInt64(obj, 0, "status", "updatedReplicas")
+		partition := getInt64(obj, 0, "spec", "updateStrategy", "rollingUpdate", "partition")
+		current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if partition > 0 && updated < replicas-partition || partition == 0 && current != update {
+			return k8v1alpha1.HealthProgressing, fmt.Sprintf("StatefulSet '%s' has %d of %d updated replicas", name, updated, replicas-partition)
+		}
+	}
+
+	if ready := getInt64(obj, 0, "status", "readyReplicas"); ready < replicas {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("StatefulSet '%s' has %d of %d replicas ready", name, ready, replicas)
+	}
+	return k8v1alpha1.HealthHealthy, ""
+}
+
+// getDaemonSetHealth returns the health of the live DaemonSet, it is healthy if its pods are updated and
+// available on all nodes where they are scheduled
+func getDaemonSetHealth(obj *unstructured.Unstructured) (k8v1alpha1.HealthStatus, string) {
+	name := obj.GetName()
+	if !isObserved(obj) {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("DaemonSet '%s' is waiting for its rollout to be observed", name)
+	}
+
+	desired := getInt64(obj, 0, "status", "desiredNumberScheduled")
+	if strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type"); strategy != "OnDelete" {
+		if updated := getInt64(obj, 0, "status", "updatedNumberScheduled"); updated < desired {
+			return k8v1alpha1.HealthProgressing, fmt.Sprintf("DaemonSet '%s' has %d of %d updated pods", name, updated, desired)
+		}
+	}
+	if available := getInt64(obj, 0, "status", "numberAvailable"); available < desired {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("DaemonSet '%s' has %d of %d pods available", name, available, desired)
+	}
+	return k8v1alpha1.HealthHealthy, ""
+}
+
+// getJobHealth returns the health of the live Job, it is healthy once it is complete and degraded if it failed
+func getJobHealth(obj *unstructured.Unstructured) (k8v1alpha1.HealthStatus, string) {
+	name := obj.GetName()
+	if condition := getCondition(obj, "Failed"); condition["status"] == "True" {
+		return k8v1alpha1.HealthDegraded, fmt.Sprintf("Job '%s' failed: %v", name, condition["message"])
+	}
+	if condition := getCondition(obj, "Complete"); condition["status"] == "True" {
+		return k8v1alpha1.HealthHealthy, ""
+	}
+	return k8v1alpha1.HealthProgressing, fmt.Sprintf("Job '%s' has not completed", name)
+}
+
+// getConditionHealth returns the health of the live object given by the first of the condition types it reports.
+// Objects without any of the conditions are healthy.
+func getConditionHealth(obj *unstructured.Unstructured, conditionTypes ...string) (k8v1alpha1.HealthStatus, string) {
+	kind, name := obj.GetKind(), obj.GetName()
+	if !isObserved(obj) {
+		return k8v1alpha1.HealthProgressing, fmt.Sprintf("%s '%s' is waiting for its changes to be observed", kind, name)
+	}
+
+	for _, conditionType := range conditionTypes {
+		condition := getCondition(obj, conditionType)
+		switch condition["status"] {
+		case "True":
+			return k8v1alpha1.HealthHealthy, ""
+		case "False":
+			return k8v1alpha1.HealthDegraded, fmt.Sprintf("%s '%s' is not %s: %v", kind, name, conditionType, condition["message"])
+		case "Unknown":
+			return k8v1alpha1.HealthProgressing, fmt.Sprintf("%s '%s' is not yet %s", kind, name, conditionType)
+		}
+	}
+	return k8v1alpha1.HealthHealthy, ""
+}
+
+// isObserved returns false if the controller of the object has not yet observed its latest generation. Objects
+// that do not report an observed generation are considered observed.
+func isObserved(obj *unstructured.Unstructured) bool {
+	generation := getInt64(obj, 0, "metadata", "generation")
+	return getInt64(obj, generation, "status", "observedGeneration") >= generation
+}
+
+// getCondition returns the status condition of the type, nil if the object does not report the condition
+func getCondition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
+
+// getAssessedHealth returns the health or progressing if the health has not yet been assessed
+func getAssessedHealth(health k8v1alpha1.HealthStatus) k8v1alpha1.HealthStatus {
+	if health == "" {
+		return k8v1alpha1.HealthProgressing
+	}
+	return health
+}
+
+// getAppsHealth returns the worst health of the apps, healthy if there are no apps
+func getAppsHealth(apps []k8v1alpha1.AppStatus) k8v1alpha1.HealthStatus {
+	health := k8v1alpha1.HealthHealthy
+	for _, app := range apps {
+		if isWorse(app.Health, health) {
+			health = getAssessedHealth(app.Health)
+		}
+	}
+	return health
+}
+
+// getStatusHealth returns the worst health of the apps and environments of a status together with a message naming
+// the apps or environments that are not healthy
+func getStatusHealth(apps []k8v1alpha1.AppStatus, envs []k8v1alpha1.EnvironmentStatus) (k8v1alpha1.HealthStatus, string) {
+	health := k8v1alpha1.HealthHealthy
+	var unhealthy []string
+	report := func(name string, h k8v1alpha1.HealthStatus) {
+		h = getAssessedHealth(h)
+		if h != k8v1alpha1.HealthHealthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", name, h))
+		}
+		if isWorse(h, health) {
+			health = h
+		}
+	}
+	for _, app := range apps {
+		report("app "+app.Name, app.Health)
+	}
+	for _, env := range envs {
+		report("environment "+env.Name, env.Health)
+	}
+	if len(unhealthy) == 0 {
+		return health, ""
+	}
+	return health, "Not healthy: " + strings.Join(unhealthy, ", ")
+}
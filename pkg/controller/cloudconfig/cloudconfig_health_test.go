@@ -0,0 +1,147 @@
+package cloudconfig
+
+import (
+	"os/exec"
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHealth(t *testing.T) {
+	tests := []struct {
+		obj     string
+		health  k8v1alpha1.HealthStatus
+		message string
+	}{
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 2},
+			"spec": {"replicas": 2},
+			"status": {"observedGeneration": 2, "replicas": 2, "updatedReplicas": 2, "availableReplicas": 2}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 1},
+			"status": {"observedGeneration": 1, "replicas": 1, "updatedReplicas": 1, "availableReplicas": 1}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 3},
+			"status": {"observedGeneration": 2}}`,
+			k8v1alpha1.HealthProgressing, "Deployment 'alpha' is waiting for its rollout to be observed"},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 2},
+			"status": {"observedGeneration": 2, "conditions": [{"type": "Progressing", "reason": "ProgressDeadlineExceeded"}]}}`,
+			k8v1alpha1.HealthDegraded, "Deployment 'alpha' exceeded its progress deadline"},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 2},
+			"spec": {"replicas": 3},
+			"status": {"observedGeneration": 2, "replicas": 3, "updatedReplicas": 1}}`,
+			k8v1alpha1.HealthProgressing, "Deployment 'alpha' has 1 of 3 updated replicas"},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 2},
+			"status": {"observedGeneration": 2, "replicas": 2, "updatedReplicas": 1, "availableReplicas": 1}}`,
+			k8v1alpha1.HealthProgressing, "Deployment 'alpha' has 1 old replicas pending termination"},
+		{`{"kind": "Deployment", "metadata": {"name": "alpha", "generation": 2},
+			"status": {"observedGeneration": 2, "replicas": 1, "updatedReplicas": 1}}`,
+			k8v1alpha1.HealthProgressing, "Deployment 'alpha' has 0 of 1 updated replicas available"},
+		{`{"kind": "StatefulSet", "metadata": {"name": "beta", "generation": 1},
+			"spec": {"replicas": 3},
+			"status": {"observedGeneration": 1, "readyReplicas": 3, "updatedReplicas": 3, "currentRevision": "b-1", "updateRevision": "b-1"}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "StatefulSet", "metadata": {"name": "beta", "generation": 2},
+			"spec": {"replicas": 3},
+			"status": {"observedGeneration": 2, "readyReplicas": 3, "updatedReplicas": 1, "currentRevision": "b-1", "updateRevision": "b-2"}}`,
+			k8v1alpha1.HealthProgressing, "StatefulSet 'beta' has 1 of 3 updated replicas"},
+		{`{"kind": "StatefulSet", "metadata": {"name": "beta", "generation": 2},
+			"spec": {"replicas": 3, "updateStrategy": {"type": "RollingUpdate", "rollingUpdate": {"partition": 2}}},
+			"status": {"observedGeneration": 2, "readyReplicas": 3, "updatedReplicas": 1, "currentRevision": "b-1", "updateRevision": "b-2"}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "StatefulSet", "metadata": {"name": "beta", "generation": 1},
+			"spec": {"replicas": 2},
+			"status": {"observedGeneration": 1, "readyReplicas": 1, "currentRevision": "b-1", "updateRevision": "b-1"}}`,
+			k8v1alpha1.HealthProgressing, "StatefulSet 'beta' has 1 of 2 replicas ready"},
+		{`{"kind": "DaemonSet", "metadata": {"name": "gamma", "generation": 1},
+			"status": {"observedGeneration": 1, "desiredNumberScheduled": 3, "updatedNumberScheduled": 3, "numberAvailable": 3}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "DaemonSet", "metadata": {"name": "gamma", "generation": 1},
+			"status": {"observedGeneration": 1, "desiredNumberScheduled": 3, "updatedNumberScheduled": 2, "numberAvailable": 3}}`,
+			k8v1alpha1.HealthProgressing, "DaemonSet 'gamma' has 2 of 3 updated pods"},
+		{`{"kind": "DaemonSet", "metadata": {"name": "gamma", "generation": 1},
+			"status": {"observedGeneration": 1, "desiredNumberScheduled": 3, "updatedNumberScheduled": 3, "numberAvailable": 1}}`,
+			k8v1alpha1.HealthProgressing, "DaemonSet 'gamma' has 1 of 3 pods available"},
+		{`{"kind": "Job", "metadata": {"name": "delta"},
+			"status": {"conditions": [{"type": "Complete", "status": "True"}]}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "Job", "metadata": {"name": "delta"},
+			"status": {"conditions": [{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"}]}}`,
+			k8v1alpha1.HealthDegraded, "Job 'delta' failed: BackoffLimitExceeded"},
+		{`{"kind": "Job", "metadata": {"name": "delta"}, "status": {"active": 1}}`,
+			k8v1alpha1.HealthProgressing, "Job 'delta' has not completed"},
+		{`{"kind": "CustomResourceDefinition", "metadata": {"name": "epsilons.example.com"},
+			"status": {"conditions": [{"type": "Established", "status": "True"}]}}`,
+			k8v1alpha1.HealthHealthy, ""},
+		{`{"kind": "Epsilon", "metadata": {"name": "epsilon", "generation": 2},
+			"status": {"observedGeneration": 1, "conditions": [{"type": "Ready", "status": "True"}]}}`,
+			k8v1alpha1.HealthProgressing, "Epsilon 'epsilon' is waiting for its changes to be observed"},
+		{`{"kind": "Epsilon", "metadata": {"name": "epsilon"},
+			"status": {"conditions": [{"type": "Ready", "status": "False", "message": "database unavailable"}]}}`,
+			k8v1alpha1.HealthDegraded, "Epsilon 'epsilon' is not Ready: database unavailable"},
+		{`{"kind": "Epsilon", "metadata": {"name": "epsilon"},
+			"status": {"conditions": [{"type": "Available", "status": "Unknown"}]}}`,
+			k8v1alpha1.HealthProgressing, "Epsilon 'epsilon' is not yet Available"},
+		{`{"kind": "ConfigMap", "metadata": {"name": "zeta"}, "data": {"key": "value"}}`,
+			k8v1alpha1.HealthHealthy, ""},
+	}
+	for _, test := range tests {
+		obj := mustParseObjects(t, test.obj)[0]
+		health, message := getHealth(obj)
+		assert.Equal(t, test.health, health, test.obj)
+		assert.Equal(t, test.message, message, test.obj)
+	}
+}
+
+func TestAssessHealth(t *testing.T) {
+	var commands [][]string
+	execCommand = recordExecCommand(&commands)
+	defer func() { execCommand = exec.Command }()
+
+	health, _, err := assessHealth("test", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, k8v1alpha1.HealthHealthy, health, "apps without objects should be healthy")
+
+	m, err := parseManifest([]byte(testManifest))
+	assert.NoError(t, err)
+	health, message, err := assessHealth("test", "system:serviceaccount:test:deployer", m)
+	assert.NoError(t, err)
+	assert.Equal(t, k8v1alpha1.HealthDegraded, health, "missing objects should be degraded")
+	assert.Equal(t, "0 of 2 objects found", message)
+	assert.Equal(t, [][]string{
+		{"kubectl", "--namespace=test", "--as=system:serviceaccount:test:deployer", "get", "-f", "-", "--ignore-not-found", "--output=json"},
+	}, commands, "the live objects should be read as the ServiceAccount")
+}
+
+func TestGetStatusHealth(t *testing.T) {
+	status := &k8v1alpha1.CloudConfigStatus{}
+	health, message := getStatusHealth(status.Apps, status.Environments)
+	assert.Equal(t, k8v1alpha1.HealthHealthy, health)
+	assert.Empty(t, message)
+
+	status.Apps = []k8v1alpha1.AppStatus{
+		{Name: "alpha", Health: k8v1alpha1.HealthHealthy},
+		{Name: "beta"},
+	}
+	health, message = getStatusHealth(status.Apps, status.Environments)
+	assert.Equal(t, k8v1alpha1.HealthProgressing, health, "apps not yet assessed should be progressing")
+	assert.Equal(t, "Not healthy: app beta (Progressing)", message)
+
+	status.Apps = nil
+	status.Environments = []k8v1alpha1.EnvironmentStatus{
+		{Name: "dev", Health: k8v1alpha1.HealthDegraded},
+		{Name: "prd", Health: k8v1alpha1.HealthProgressing},
+	}
+	health, message = getStatusHealth(status.Apps, status.Environments)
+	assert.Equal(t, k8v1alpha1.HealthDegraded, health)
+	assert.Equal(t, "Not healthy: environment dev (Degraded), environment prd (Progressing)", message)
+}
+
+func TestGetAppsHealth(t *testing.T) {
+	assert.Equal(t, k8v1alpha1.HealthHealthy, getAppsHealth(nil))
+	assert.Equal(t, k8v1alpha1.HealthProgressing, getAppsHealth([]k8v1alpha1.AppStatus{{Name: "alpha"}}))
+	assert.Equal(t, k8v1alpha1.HealthDegraded, getAppsHealth([]k8v1alpha1.AppStatus{
+		{Name: "alpha", Health: k8v1alpha1.HealthDegraded},
+		{Name: "beta", Health: k8v1alpha1.HealthProgressing},
+	}))
+}
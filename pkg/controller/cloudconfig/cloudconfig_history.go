@@ -0,0 +1,167 @@
+package cloudconfig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HistoryLabel identifies the ConfigMap holding the spec files of the revision history of a CloudConfigApp, the
+// ConfigMap is deliberately not labelled as an object of the app so that it is not pruned
+const HistoryLabel = "k8s.jabberwocky.se/history"
+
+// lastGoodRevisionKey is the key of the history ConfigMap entry naming the last good revision
+const lastGoodRevisionKey = "lastGoodRevision"
+
+// getHistoryConfigMapName returns the name of the ConfigMap holding the revision history of the owner
+func getHistoryConfigMapName(owner string) string {
+	return owner + "-history"
+}
+
+// getRevisionKey returns the key of the history ConfigMap entry holding the compressed spec files of the revision
+func getRevisionKey(revision string) string {
+	return revision + ".yaml.gz"
+}
+
+// getHistory returns the history ConfigMap of the owner or a new ConfigMap if it does not exist
+func getHistory(k8client client.Client, owner, namespace string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: getHistoryConfigMapName(owner), Namespace: namespace}
+	if err := k8client.Get(context.TODO(), name, cm); err != nil {
+		if !k8errors.IsNotFound(err) {
+			return nil, err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name.Name,
+				Namespace: name.Namespace,
+				Labels:    map[string]string{HistoryLabel: owner},
+			},
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = make(map[string][]byte, 2)
+	}
+	return cm, nil
+}
+
+// setRevision stores the compressed rendered spec files of the revision in the history
+func setRevision(history *corev1.ConfigMap, revision string, rendered []byte) error {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(rendered); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	history.BinaryData[getRevisionKey(revision)] = buf.Bytes()
+	return nil
+}
+
+// getRevisionSpec returns the rendered spec files of the revision, false if the revision is not in the history
+func getRevisionSpec(history *corev1.ConfigMap, revision string) ([]byte, bool, error) {
+	data, found := history.BinaryData[getRevisionKey(revision)]
+	if !found {
+		return nil, false, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, true, err
+	}
+	defer r.Close()
+	rendered, err := ioutil.ReadAll(r)
+	return rendered, true, err
+}
+
+// getRevisionManifest returns the objects of the revision, false if the revision is not in the history
+func getRevisionManifest(history *corev1.ConfigMap, revision string) (manifest, bool, error) {
+	rendered, found, err := getRevisionSpec(history, revision)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	m, err := parseManifest(rendered)
+	return m, true, err
+}
+
+// pruneHistory removes all revisions from the history except the given revisions
+func pruneHistory(history *corev1.ConfigMap, keep ...string) {
+	keys := make(map[string]bool, len(keep))
+	for _, revision := range keep {
+		keys[getRevisionKey(revision)] = true
+	}
+	for key := range history.BinaryData {
+		if !keys[key] {
+			delete(history.BinaryData, key)
+		}
+	}
+}
+
+// defaultRevisionHistoryLimit is the number of applied revisions kept in the history by default
+const defaultRevisionHistoryLimit = 10
+
+// getRevisionHistoryLimit returns the number of applied revisions kept in the history
+func getRevisionHistoryLimit(spec *k8v1alpha1.CloudConfigSpec) int {
+	if spec.RevisionHistoryLimit > 0 {
+		return spec.RevisionHistoryLimit
+	}
+	return defaultRevisionHistoryLimit
+}
+
+// addRevisionHistory adds the applied revision first in the history, replacing earlier applications of the same
+// revision, and drops the oldest revisions exceeding the limit
+func addRevisionHistory(
+	history []k8v1alpha1.RevisionHistory,
+	entry k8v1alpha1.RevisionHistory,
+	limit int) []k8v1alpha1.RevisionHistory {
+
+	updated := make([]k8v1alpha1.RevisionHistory, 0, len(history)+1)
+	updated = append(updated, entry)
+	for _, h := range history {
+		if h.Revision != entry.Revision && len(updated) < limit {
+			updated = append(updated, h)
+		}
+	}
+	return updated
+}
+
+// setRevisionOutcome sets the outcome of the revision in the history
+func setRevisionOutcome(history []k8v1alpha1.RevisionHistory, revision string, outcome k8v1alpha1.RolloutPhase) {
+	for i := range history {
+		if history[i].Revision == revision {
+			history[i].Outcome = outcome
+			return
+		}
+	}
+}
+
+// findRevision returns the most recent revision of the history matching the pinned revision, i.e. the revision
+// or the config server version of the revision
+func findRevision(history []k8v1alpha1.RevisionHistory, pinned string) (k8v1alpha1.RevisionHistory, bool) {
+	for _, h := range history {
+		if h.Revision == pinned || h.Version == pinned {
+			return h, true
+		}
+	}
+	return k8v1alpha1.RevisionHistory{}, false
+}
+
+// getRevisions returns the revisions of the history
+func getRevisions(history []k8v1alpha1.RevisionHistory) []string {
+	revisions := make([]string, len(history))
+	for i, h := range history {
+		revisions[i] = h.Revision
+	}
+	return revisions
+}
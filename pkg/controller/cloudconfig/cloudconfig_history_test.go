@@ -0,0 +1,77 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestHistory(t *testing.T) {
+	history := &corev1.ConfigMap{BinaryData: make(map[string][]byte)}
+	assert.NoError(t, setRevision(history, "0123456789ab", []byte(testManifest)))
+	assert.NoError(t, setRevision(history, "ba9876543210", []byte("kind: Namespace")))
+	assert.Contains(t, history.BinaryData, "0123456789ab.yaml.gz")
+
+	rendered, found, err := getRevisionSpec(history, "0123456789ab")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, testManifest, string(rendered), "the spec files should survive compression")
+
+	_, found, err = getRevisionSpec(history, "abcdef012345")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	pruneHistory(history, "ba9876543210", "")
+	assert.Len(t, history.BinaryData, 1)
+	_, found, _ = getRevisionSpec(history, "ba9876543210")
+	assert.True(t, found, "kept revisions should not be pruned")
+}
+
+func TestGetHistoryConfigMapName(t *testing.T) {
+	assert.Equal(t, "alpha-history", getHistoryConfigMapName("alpha"))
+}
+
+func TestGetRevisionHistoryLimit(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{}
+	assert.Equal(t, defaultRevisionHistoryLimit, getRevisionHistoryLimit(spec))
+	spec.RevisionHistoryLimit = 3
+	assert.Equal(t, 3, getRevisionHistoryLimit(spec))
+}
+
+func TestAddRevisionHistory(t *testing.T) {
+	var history []k8v1alpha1.RevisionHistory
+	for _, revision := range []string{"a", "b", "c", "b"} {
+		history = addRevisionHistory(history, k8v1alpha1.RevisionHistory{Revision: revision}, 2)
+	}
+	assert.Equal(t, []string{"b", "c"}, getRevisions(history), "the most recent revisions should be kept first")
+
+	history = addRevisionHistory(history, k8v1alpha1.RevisionHistory{Revision: "d"}, 3)
+	assert.Equal(t, []string{"d", "b", "c"}, getRevisions(history))
+}
+
+func TestFindRevision(t *testing.T) {
+	history := []k8v1alpha1.RevisionHistory{
+		{Revision: "0123456789ab", Version: "f00d"},
+		{Revision: "ba9876543210", Version: "beef"},
+	}
+
+	h, found := findRevision(history, "ba9876543210")
+	assert.True(t, found)
+	assert.Equal(t, "beef", h.Version)
+
+	h, found = findRevision(history, "f00d")
+	assert.True(t, found, "revisions should be found by config server version")
+	assert.Equal(t, "0123456789ab", h.Revision)
+
+	_, found = findRevision(history, "abcdef012345")
+	assert.False(t, found)
+}
+
+func TestSetRevisionOutcome(t *testing.T) {
+	history := []k8v1alpha1.RevisionHistory{{Revision: "a"}, {Revision: "b"}}
+	setRevisionOutcome(history, "b", k8v1alpha1.RolloutFailed)
+	assert.Equal(t, k8v1alpha1.RolloutPhase(""), history[0].Outcome)
+	assert.Equal(t, k8v1alpha1.RolloutFailed, history[1].Outcome)
+}
@@ -0,0 +1,91 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// tagPattern matches valid image tags
+var tagPattern = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+// digestPattern matches valid image digests, e.g. `sha256:...`
+var digestPattern = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-fA-F0-9]{32,}$`)
+
+// getImageName returns the name of the image without its tag and digest
+func getImageName(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// overrideImage returns the image with the tag or digest of the first override of its name, the result is
+// false if no override matches
+func overrideImage(image string, overrides []k8v1alpha1.ImageOverride) (string, bool) {
+	name := getImageName(image)
+	for _, override := range overrides {
+		if override.Name != name {
+			continue
+		}
+		if override.Digest != "" {
+			return name + "@" + override.Digest, true
+		}
+		return name + ":" + override.Tag, true
+	}
+	return image, false
+}
+
+// overrideImages replaces the tags and digests of the container images of the workloads of the manifest with the
+// overrides and describes the overridden images, images that already match their override are not reported
+func (m manifest) overrideImages(overrides []k8v1alpha1.ImageOverride) []string {
+	if len(overrides) == 0 {
+		return nil
+	}
+	var overridden []string
+	for _, obj := range m {
+		podSpec := getPodSpec(obj)
+		if podSpec == nil {
+			continue
+		}
+		for _, container := range getContainers(podSpec) {
+			image, _ := container["image"].(string)
+			if override, found := overrideImage(image, overrides); found && override != image {
+				container["image"] = override
+				overridden = append(overridden, fmt.Sprintf("%s '%s' container '%s': %s -> %s",
+					obj.GetKind(), obj.GetName(), container["name"], image, override))
+			}
+		}
+	}
+	return overridden
+}
+
+// validateImages validates the image overrides of the spec
+func validateImages(spec *k8v1alpha1.CloudConfigSpec) field.ErrorList {
+	validationErrors := field.ErrorList{}
+	for i, override := range spec.Images {
+		path := field.NewPath("images").Index(i)
+		if override.Name == "" || getImageName(override.Name) != override.Name {
+			validationErrors = append(validationErrors, field.Invalid(path.Child("name"), override.Name,
+				"must be an image name without tag or digest"))
+		}
+		switch {
+		case override.Digest != "":
+			if !digestPattern.MatchString(override.Digest) {
+				validationErrors = append(validationErrors, field.Invalid(path.Child("digest"), override.Digest,
+					"must be a digest, e.g. `sha256:<hex>`"))
+			}
+		case override.Tag == "":
+			validationErrors = append(validationErrors, field.Required(path.Child("tag"), "a tag or digest must be specified"))
+		case !tagPattern.MatchString(override.Tag):
+			validationErrors = append(validationErrors, field.Invalid(path.Child("tag"), override.Tag, "must be a valid image tag"))
+		}
+	}
+	return validationErrors
+}
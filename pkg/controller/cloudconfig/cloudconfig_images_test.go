@@ -0,0 +1,61 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest = "sha256:2f1cd90e00fe2c991e18272bb35d6a8258eeb27785d121aa4cc1ae4235167cfd"
+
+func TestGetImageName(t *testing.T) {
+	assert.Equal(t, "nginx", getImageName("nginx"))
+	assert.Equal(t, "nginx", getImageName("nginx:1.17"))
+	assert.Equal(t, "registry.example.com:5000/nginx", getImageName("registry.example.com:5000/nginx"))
+	assert.Equal(t, "registry.example.com:5000/nginx", getImageName("registry.example.com:5000/nginx:1.17"))
+	assert.Equal(t, "nginx", getImageName("nginx:1.17@"+testDigest))
+}
+
+func TestOverrideImages(t *testing.T) {
+	m, _ := parseManifest([]byte(testPolicyManifest))
+	overridden := m.overrideImages([]k8v1alpha1.ImageOverride{
+		{Name: "nginx", Tag: "1.17"},
+		{Name: "registry.example.com/team/init", Tag: "1.0"},
+		{Name: "registry.example.com/other/cleanup", Tag: "2.0", Digest: testDigest},
+	})
+	assert.Equal(t, []string{
+		"Deployment 'alpha' container 'alpha': nginx -> nginx:1.17",
+		"CronJob 'cleanup' container 'cleanup': registry.example.com/other/cleanup:latest -> registry.example.com/other/cleanup@" + testDigest,
+	}, overridden, "images already matching their override should not be reported")
+
+	var images []string
+	for _, obj := range m[:2] {
+		for _, container := range getContainers(getPodSpec(obj)) {
+			images = append(images, container["image"].(string))
+		}
+	}
+	assert.Equal(t, []string{
+		"registry.example.com/team/init:1.0",
+		"nginx:1.17",
+		"registry.example.com/other/cleanup@" + testDigest,
+	}, images)
+	assert.Nil(t, m.overrideImages(nil))
+}
+
+func TestValidateImages(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{Images: []k8v1alpha1.ImageOverride{
+		{Name: "registry.example.com/team/alpha", Tag: "1.2.3-rc1"},
+		{Name: "nginx", Digest: testDigest},
+		{Name: "nginx:1.17", Tag: "1.18"},
+		{Name: "beta"},
+		{Name: "gamma", Tag: "-rc1"},
+		{Name: "delta", Digest: "sha256:latest"},
+	}}
+	errs := validateImages(spec)
+	assert.Len(t, errs, 4)
+	assert.Equal(t, "images[2].name", errs[0].Field)
+	assert.Equal(t, "images[3].tag", errs[1].Field)
+	assert.Equal(t, "images[4].tag", errs[2].Field)
+	assert.Equal(t, "images[5].digest", errs[3].Field)
+}
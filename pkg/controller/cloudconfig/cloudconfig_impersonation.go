@@ -0,0 +1,46 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getServiceAccountUser returns the user name of the ServiceAccount of the spec in the namespace, impersonated
+// when applying and pruning the objects of the apps; empty if the spec has no ServiceAccount
+func getServiceAccountUser(namespace string, spec *k8v1alpha1.CloudConfigSpec) string {
+	if spec.ServiceAccountName == "" {
+		return ""
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, spec.ServiceAccountName)
+}
+
+// getServiceAccountGroups returns the groups the API server adds to the ServiceAccount user when impersonating it,
+// none if the user is not a ServiceAccount
+func getServiceAccountGroups(user string) []string {
+	parts := strings.Split(user, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" {
+		return nil
+	}
+	return []string{"system:serviceaccounts", "system:serviceaccounts:" + parts[2], "system:authenticated"}
+}
+
+// getAppUser returns the user impersonated for the objects of the CloudConfigApp, the ServiceAccount is found in
+// the namespace of the CloudConfig verified to own the app
+func getAppUser(k8client client.Client, app *k8v1alpha1.CloudConfigApp) (string, error) {
+	namespace, err := getConfigNamespace(k8client, app)
+	if err != nil {
+		return "", err
+	}
+	return getServiceAccountUser(namespace, &app.Spec.CloudConfigSpec), nil
+}
+
+// getImpersonationArgs returns the kubectl arguments impersonating the user, none if the user is empty
+func getImpersonationArgs(user string) []string {
+	if user == "" {
+		return nil
+	}
+	return []string{"--as=" + user}
+}
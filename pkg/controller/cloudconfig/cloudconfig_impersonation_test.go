@@ -0,0 +1,65 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestGetAppUser(t *testing.T) {
+	k8client := newFakeClient(t)
+	app := &k8v1alpha1.CloudConfigApp{ObjectMeta: metav1.ObjectMeta{Name: "test-alpha", Namespace: "team-dev"}}
+	user, err := getAppUser(k8client, app)
+	assert.NoError(t, err)
+	assert.Empty(t, user, "apps without a ServiceAccount should be applied as the operator")
+
+	app.Spec.ServiceAccountName = "deployer"
+	user, err = getAppUser(k8client, app)
+	assert.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:team-dev:deployer", user)
+	assert.Equal(t, []string{"--as=system:serviceaccount:team-dev:deployer"}, getImpersonationArgs(user))
+	assert.Empty(t, getImpersonationArgs(""))
+}
+
+func TestGetAppUserOfEnvironment(t *testing.T) {
+	c, env, app := newTeamCloudConfig(t)
+	app.Spec.ServiceAccountName = "deployer"
+	user, err := getAppUser(newFakeClient(t, c, env), app)
+	assert.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:ops:deployer", user,
+		"the ServiceAccount should be found in the namespace of the CloudConfig")
+}
+
+func TestGetAppUserIgnoresForeignNamespaceLabel(t *testing.T) {
+	c, env, app := newTeamCloudConfig(t)
+	app.Spec.ServiceAccountName = "deployer"
+
+	// an app created in the team namespace claiming to belong to a CloudConfig in the 'kube-system' namespace
+	app.OwnerReferences = nil
+	app.Labels[k8v1alpha1.CloudConfigNamespaceLabel] = "kube-system"
+	user, err := getAppUser(newFakeClient(t, c, env), app)
+	assert.NoError(t, err)
+	assert.Equal(t, "system:serviceaccount:team-dev:deployer", user)
+
+	// an app of a CloudConfigEnv created in the team namespace claiming the same
+	forged := env.DeepCopy()
+	forged.Labels[k8v1alpha1.CloudConfigNamespaceLabel] = "kube-system"
+	forged.Name = "cluster-test"
+	app = newCloudConfigApp(forged.Name, forged.Namespace, c.Spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "alpha"}), forged.Labels)
+	app.Spec.ServiceAccountName = "deployer"
+	if err := controllerutil.SetControllerReference(forged, app, newTestScheme(t)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = getAppUser(newFakeClient(t, c, forged), app)
+	assert.EqualError(t, err, "CloudConfigEnv 'cluster-test' in namespace 'team-dev' is not an environment of any CloudConfig")
+}
+
+func TestGetServiceAccountGroups(t *testing.T) {
+	assert.Equal(t, []string{"system:serviceaccounts", "system:serviceaccounts:team", "system:authenticated"},
+		getServiceAccountGroups("system:serviceaccount:team:deployer"))
+	assert.Empty(t, getServiceAccountGroups("jane"), "users should not have ServiceAccount groups")
+	assert.Empty(t, getServiceAccountGroups(""))
+}
@@ -0,0 +1,157 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+)
+
+// allowedKindsEnvVar is the environment variable listing the comma separated kind patterns the operator allows
+// for all CloudConfigs, objects must match the allowed kinds of both the operator and their CloudConfig
+const allowedKindsEnvVar = "ALLOWED_KINDS"
+
+// deniedKindsEnvVar is the environment variable listing the comma separated kind patterns the operator denies
+// for all CloudConfigs in addition to their own denied kinds
+const deniedKindsEnvVar = "DENIED_KINDS"
+
+// kindPolicy decides which objects of an app are applied by their group and kind. An object is allowed if it
+// matches a pattern of each of the allowed lists and no denied pattern.
+type kindPolicy struct {
+	allowed [][]string
+	denied  []string
+}
+
+// newKindPolicy returns the kind policy of the CloudConfig spec combined with the policy of the operator
+func newKindPolicy(spec *k8v1alpha1.CloudConfigSpec) (kindPolicy, error) {
+	p := kindPolicy{}
+	operatorAllowed, err := getOperatorKinds(allowedKindsEnvVar)
+	if err != nil {
+		return p, err
+	}
+	operatorDenied, err := getOperatorKinds(deniedKindsEnvVar)
+	if err != nil {
+		return p, err
+	}
+
+	for _, allowed := range [][]string{operatorAllowed, spec.AllowedKinds} {
+		if len(allowed) > 0 {
+			p.allowed = append(p.allowed, allowed)
+		}
+	}
+	p.denied = append(operatorDenied, spec.DeniedKinds...)
+	return p, nil
+}
+
+// getOperatorKinds returns the kind patterns of the environment variable, invalid patterns are an error as
+// ignoring them could apply denied objects
+func getOperatorKinds(envVar string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(os.Getenv(envVar), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid kind pattern '%s' in %s: %s", pattern, envVar, err.Error())
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// getGroupKind returns the group and kind of the object as matched by the kind patterns, e.g. `apps/Deployment`
+// or `/ConfigMap` for the core group
+func getGroupKind(obj *unstructured.Unstructured) string {
+	return obj.GroupVersionKind().Group + "/" + obj.GetKind()
+}
+
+// matchKind returns true if the group and kind matches the pattern, a pattern without a group matches the kind
+// of any group
+func matchKind(pattern, groupKind string) bool {
+	if !strings.Contains(pattern, "/") {
+		groupKind = groupKind[strings.LastIndex(groupKind, "/")+1:]
+	}
+	matched, _ := path.Match(pattern, groupKind)
+	return matched
+}
+
+// matchAny returns the first pattern matching the group and kind, empty if none matches
+func matchAny(patterns []string, groupKind string) string {
+	for _, pattern := range patterns {
+		if matchKind(pattern, groupKind) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// check returns why the object is rejected, empty if it is allowed
+func (p kindPolicy) check(obj *unstructured.Unstructured) string {
+	groupKind := getGroupKind(obj)
+	if pattern := matchAny(p.denied, groupKind); pattern != "" {
+		return fmt.Sprintf("kind '%s' is denied by '%s'", groupKind, pattern)
+	}
+	for _, allowed := range p.allowed {
+		if matchAny(allowed, groupKind) == "" {
+			return fmt.Sprintf("kind '%s' is not allowed", groupKind)
+		}
+	}
+	return ""
+}
+
+// filter returns the allowed objects of the manifest and describes the rejected objects
+func (p kindPolicy) filter(m manifest) (manifest, []string) {
+	allowed := make(manifest, 0, len(m))
+	var rejected []string
+	for _, obj := range m {
+		if reason := p.check(obj); reason != "" {
+			rejected = append(rejected, fmt.Sprintf("%s '%s': %s", obj.GetKind(), obj.GetName(), reason))
+			continue
+		}
+		allowed = append(allowed, obj)
+	}
+	return allowed, rejected
+}
+
+// filterKinds returns the objects of the manifest allowed by the kind policy of the CloudConfig spec and the
+// operator together with the rejected objects
+func filterKinds(spec *k8v1alpha1.CloudConfigSpec, m manifest) (manifest, []string, error) {
+	p, err := newKindPolicy(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed, rejected := p.filter(m)
+	return allowed, rejected, nil
+}
+
+// recordRejected records a warning event for the rejected objects unless the same objects were rejected by the
+// previous synchronization
+func recordRejected(recorder record.EventRecorder, obj runtime.Object, previous, rejected []string) {
+	if len(rejected) > 0 && !reflect.DeepEqual(previous, rejected) {
+		recorder.Event(obj, corev1.EventTypeWarning, "Rejected", "Objects not applied: "+strings.Join(rejected, "; "))
+	}
+}
+
+// validateKinds validates the allowed and denied kind patterns of the spec
+func validateKinds(spec *k8v1alpha1.CloudConfigSpec) field.ErrorList {
+	validationErrors := field.ErrorList{}
+	validatePatterns := func(name string, patterns []string) {
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				validationErrors = append(validationErrors, field.Invalid(field.NewPath(name).Index(i), pattern,
+					"must be a `<group>/<kind>` glob pattern"))
+			}
+		}
+	}
+	validatePatterns("allowedKinds", spec.AllowedKinds)
+	validatePatterns("deniedKinds", spec.DeniedKinds)
+	return validationErrors
+}
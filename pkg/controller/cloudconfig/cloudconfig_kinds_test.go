@@ -0,0 +1,105 @@
+package cloudconfig
+
+import (
+	"os"
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKindsManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: alpha
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: cluster-admin-alpha
+`
+
+func TestMatchKind(t *testing.T) {
+	assert.True(t, matchKind("apps/*", "apps/Deployment"))
+	assert.True(t, matchKind("/ConfigMap", "/ConfigMap"))
+	assert.False(t, matchKind("/ConfigMap", "example.com/ConfigMap"), "an empty group should only match the core group")
+	assert.True(t, matchKind("ConfigMap", "example.com/ConfigMap"), "a pattern without a group should match any group")
+	assert.True(t, matchKind("*.k8s.io/*Role*", "rbac.authorization.k8s.io/ClusterRoleBinding"))
+	assert.False(t, matchKind("apps/*", "/Service"))
+}
+
+func TestFilterKinds(t *testing.T) {
+	m, _ := parseManifest([]byte(testKindsManifest))
+	spec := &k8v1alpha1.CloudConfigSpec{}
+	allowed, rejected, err := filterKinds(spec, m)
+	assert.NoError(t, err)
+	assert.Len(t, allowed, 4, "all kinds should be allowed by default")
+	assert.Empty(t, rejected)
+
+	spec.AllowedKinds = []string{"apps/*", "/ConfigMap", "/Secret"}
+	spec.DeniedKinds = []string{"Secret"}
+	allowed, rejected, err = filterKinds(spec, m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Deployment", "ConfigMap"}, []string{allowed[0].GetKind(), allowed[1].GetKind()})
+	assert.Equal(t, []string{
+		"Secret 'credentials': kind '/Secret' is denied by 'Secret'",
+		"ClusterRoleBinding 'cluster-admin-alpha': kind 'rbac.authorization.k8s.io/ClusterRoleBinding' is not allowed",
+	}, rejected)
+
+	os.Setenv(allowedKindsEnvVar, "apps/*, /Secret")
+	os.Setenv(deniedKindsEnvVar, "rbac.authorization.k8s.io/*")
+	defer os.Unsetenv(allowedKindsEnvVar)
+	defer os.Unsetenv(deniedKindsEnvVar)
+	spec = &k8v1alpha1.CloudConfigSpec{AllowedKinds: []string{"*"}}
+	allowed, rejected, err = filterKinds(spec, m)
+	assert.NoError(t, err)
+	assert.Len(t, allowed, 2, "objects should be allowed by both the operator and the CloudConfig")
+	assert.Equal(t, []string{
+		"ConfigMap 'alpha': kind '/ConfigMap' is not allowed",
+		"ClusterRoleBinding 'cluster-admin-alpha': kind 'rbac.authorization.k8s.io/ClusterRoleBinding' is denied by 'rbac.authorization.k8s.io/*'",
+	}, rejected)
+
+	os.Setenv(deniedKindsEnvVar, "[")
+	_, _, err = filterKinds(spec, m)
+	assert.EqualError(t, err, "invalid kind pattern '[' in DENIED_KINDS: syntax error in pattern")
+}
+
+func TestFilterKindsList(t *testing.T) {
+	m, _ := parseManifest([]byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: alpha
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: cluster-admin-alpha
+`))
+	spec := &k8v1alpha1.CloudConfigSpec{DeniedKinds: []string{"rbac.authorization.k8s.io/*"}}
+	allowed, rejected, err := filterKinds(spec, m)
+	assert.NoError(t, err)
+	assert.Len(t, allowed, 1)
+	assert.Equal(t, []string{
+		"ClusterRoleBinding 'cluster-admin-alpha': kind 'rbac.authorization.k8s.io/ClusterRoleBinding' is denied by 'rbac.authorization.k8s.io/*'",
+	}, rejected, "the items of lists should be checked")
+}
+
+func TestValidateKinds(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{AllowedKinds: []string{"apps/*"}, DeniedKinds: []string{"/Secret", "[", ""}}
+	errs := validateKinds(spec)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "deniedKinds[1]", errs[0].Field)
+	assert.Equal(t, "deniedKinds[2]", errs[1].Field)
+}
@@ -0,0 +1,170 @@
+package cloudconfig
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8yaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// manifest is the list of Kubernetes objects defined by the spec files of an app
+type manifest []*unstructured.Unstructured
+
+// parseManifest parses the YAML documents of an app spec into Kubernetes objects, empty documents are ignored
+func parseManifest(spec []byte) (manifest, error) {
+	m := make(manifest, 0, 10)
+	reader := k8yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(spec)))
+	for i := 0; ; i++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		j, err := k8yaml.ToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d is not valid YAML: %s", i, err.Error())
+		}
+		if bytes.Equal(bytes.TrimSpace(j), []byte("null")) {
+			continue
+		}
+
+		obj := make(map[string]interface{})
+		if err := json.Unmarshal(j, &obj); err != nil {
+			return nil, fmt.Errorf("document %d is not a Kubernetes object: %s", i, err.Error())
+		}
+		if m, err = m.appendObject(&unstructured.Unstructured{Object: obj}); err != nil {
+			return nil, fmt.Errorf("document %d is not a Kubernetes object: %s", i, err.Error())
+		}
+	}
+}
+
+// appendObject appends the object to the manifest. The items of lists, e.g. `kind: List`, are appended instead of
+// the list so that the allowed kinds and policies are checked for each item.
+func (m manifest) appendObject(obj *unstructured.Unstructured) (manifest, error) {
+	items, found := getListItems(obj.Object)
+	if !found {
+		return append(m, obj), nil
+	}
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("item %d of the %s is not an object", i, obj.GetKind())
+		}
+		var err error
+		if m, err = m.appendObject(&unstructured.Unstructured{Object: fields}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// getListItems returns the items of a list, i.e. an object of a `*List` kind with items
+func getListItems(obj map[string]interface{}) ([]interface{}, bool) {
+	kind, _ := obj["kind"].(string)
+	items, ok := obj["items"].([]interface{})
+	return items, ok && strings.HasSuffix(kind, "List")
+}
+
+// setLabel sets the label on all objects of the manifest
+func (m manifest) setLabel(key, value string) {
+	for _, obj := range m {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels[key] = value
+		obj.SetLabels(labels)
+	}
+}
+
+// setOwnerReference adds the owner reference to all objects of the manifest that are namespaced in
+// the owner's namespace. Cluster scoped objects and objects of unknown kinds are left unchanged.
+func (m manifest) setOwnerReference(owner metav1.OwnerReference, namespace string, mapper meta.RESTMapper) {
+	for _, obj := range m {
+		if obj.GetNamespace() != "" && obj.GetNamespace() != namespace {
+			continue
+		}
+		if !isNamespaced(mapper, obj) || hasOwnerReference(obj, owner) {
+			continue
+		}
+		obj.SetOwnerReferences(append(obj.GetOwnerReferences(), owner))
+	}
+}
+
+// hasUnownedObjects returns true if any object of the manifest is cluster scoped or of an unknown kind, i.e. if
+// not all objects are garbage collected with the owner of the namespaced objects
+func (m manifest) hasUnownedObjects(mapper meta.RESTMapper) bool {
+	for _, obj := range m {
+		if !isNamespaced(mapper, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOwnerReference(obj *unstructured.Unstructured, owner metav1.OwnerReference) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// getKinds returns the sorted resource types of the objects of the manifest in the `kind.version.group`
+// format accepted by kubectl
+func (m manifest) getKinds() []string {
+	unique := make(map[string]bool, len(m))
+	kinds := make([]string, 0, len(m))
+	for _, obj := range m {
+		gvk := obj.GroupVersionKind()
+		kind := strings.ToLower(gvk.Kind)
+		if gvk.Group != "" {
+			kind = strings.Join([]string{kind, gvk.Version, gvk.Group}, ".")
+		}
+		if !unique[kind] {
+			unique[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// toYAML returns the manifest as a YAML stream of documents
+func (m manifest) toYAML() ([]byte, error) {
+	spec := make([]byte, 0, 1024)
+	for _, obj := range m {
+		doc, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		spec = appendYAMLDoc(obj.GetName(), spec, doc)
+	}
+	return spec, nil
+}
+
+// isNamespaced returns true if the object's kind is known to be namespaced by the RESTMapper
+func isNamespaced(mapper meta.RESTMapper, obj *unstructured.Unstructured) bool {
+	if mapper == nil {
+		return false
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+}
@@ -0,0 +1,131 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testManifest = `
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+# empty document
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+  labels:
+    app: alpha
+`
+
+func TestParseManifest(t *testing.T) {
+	m, err := parseManifest([]byte(testManifest))
+	assert.NoError(t, err)
+	assert.Len(t, m, 2, "empty documents should be ignored")
+	assert.Equal(t, "Namespace", m[0].GetKind())
+	assert.Equal(t, "alpha", m[1].GetName())
+
+	m, err = parseManifest([]byte("kind: [ Deployment"))
+	assert.Error(t, err, "invalid YAML should result in an error")
+	assert.Nil(t, m)
+}
+
+const testListManifest = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: alpha
+- apiVersion: v1
+  kind: ServiceList
+  items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: alpha
+`
+
+func TestParseManifestList(t *testing.T) {
+	m, err := parseManifest([]byte(testListManifest))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap", "Service"}, []string{m[0].GetKind(), m[1].GetKind()},
+		"the items of lists should replace the lists")
+	assert.Len(t, m, 2)
+
+	_, err = parseManifest([]byte("apiVersion: v1\nkind: List\nitems: [ alpha ]\n"))
+	assert.EqualError(t, err, "document 0 is not a Kubernetes object: item 0 of the List is not an object")
+}
+
+func TestManifestSetLabel(t *testing.T) {
+	m, _ := parseManifest([]byte(testManifest))
+	m.setLabel("k8s.jabberwocky.se/cloudconfigapp", "test-alpha")
+	assert.Equal(t, map[string]string{"k8s.jabberwocky.se/cloudconfigapp": "test-alpha"}, m[0].GetLabels())
+	assert.Equal(t, map[string]string{
+		"app":                               "alpha",
+		"k8s.jabberwocky.se/cloudconfigapp": "test-alpha",
+	}, m[1].GetLabels())
+}
+
+func TestManifestSetOwnerReference(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	owner := metav1.OwnerReference{Kind: "CloudConfigApp", Name: "test-alpha", UID: "1234"}
+	m, _ := parseManifest([]byte(testManifest))
+	m.setOwnerReference(owner, "test", mapper)
+	m.setOwnerReference(owner, "test", mapper)
+
+	assert.Empty(t, m[0].GetOwnerReferences(), "cluster scoped objects should not be owned")
+	assert.Equal(t, []metav1.OwnerReference{owner}, m[1].GetOwnerReferences(), "namespaced objects should be owned once")
+
+	m, _ = parseManifest([]byte(testManifest))
+	m[1].SetNamespace("other")
+	m.setOwnerReference(owner, "test", mapper)
+	assert.Empty(t, m[1].GetOwnerReferences(), "objects in other namespaces should not be owned")
+}
+
+func TestManifestHasUnownedObjects(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	m, _ := parseManifest([]byte(testManifest))
+	assert.True(t, m.hasUnownedObjects(mapper), "objects of unknown kinds should not be owned")
+	assert.False(t, m[1:].hasUnownedObjects(mapper))
+
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	assert.True(t, m.hasUnownedObjects(mapper), "cluster scoped objects should not be owned")
+}
+
+func TestManifestGetKinds(t *testing.T) {
+	m, _ := parseManifest([]byte(testManifest + "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: beta\n"))
+	assert.Equal(t, []string{"deployment.v1.apps", "namespace"}, m.getKinds())
+}
+
+func TestManifestToYAML(t *testing.T) {
+	m, _ := parseManifest([]byte(testManifest))
+	spec, err := m.toYAML()
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: alpha
+  name: alpha
+`, string(spec))
+}
@@ -0,0 +1,126 @@
+package cloudconfig
+
+import (
+	"sort"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// podTemplatePaths are the paths of the pod templates of workloads and of the job templates of CronJobs
+var podTemplatePaths = [][]string{
+	{"spec", "template"},
+	{"spec", "jobTemplate", "spec", "template"},
+}
+
+// setMetadata sets the labels and annotations on all objects of the manifest and on the pod templates of their
+// workloads, existing labels and annotations with the same keys are replaced
+func (m manifest) setMetadata(labels, annotations map[string]string) {
+	for _, obj := range m {
+		setStringMap(obj.Object, labels, "metadata", "labels")
+		setStringMap(obj.Object, annotations, "metadata", "annotations")
+		for _, path := range podTemplatePaths {
+			template, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+			if t, ok := template.(map[string]interface{}); found && ok {
+				setStringMap(t, labels, "metadata", "labels")
+				setStringMap(t, annotations, "metadata", "annotations")
+			}
+		}
+	}
+}
+
+// setAnnotations sets the annotations on all objects of the manifest but not on their pod templates, e.g. for
+// annotations that would restart the pods of workloads whenever they change
+func (m manifest) setAnnotations(annotations map[string]string) {
+	for _, obj := range m {
+		setStringMap(obj.Object, annotations, "metadata", "annotations")
+	}
+}
+
+// setStringMap merges the values into the string map of the object at the path, the map is created if it does
+// not exist
+func setStringMap(obj map[string]interface{}, values map[string]string, fields ...string) {
+	if len(values) == 0 {
+		return
+	}
+	existing, _, _ := unstructured.NestedFieldNoCopy(obj, fields...)
+	merged, ok := existing.(map[string]interface{})
+	if !ok {
+		merged = make(map[string]interface{}, len(values))
+	}
+	for key, value := range values {
+		merged[key] = value
+	}
+	unstructured.SetNestedField(obj, merged, fields...)
+}
+
+// newObjectLabels returns the common labels of the spec together with the labels identifying the objects of the
+// app, the identifying labels take precedence
+func newObjectLabels(spec *k8v1alpha1.CloudConfigSpec, identity map[string]string) map[string]string {
+	labels := make(map[string]string, len(spec.CommonLabels)+len(identity))
+	for key, value := range spec.CommonLabels {
+		labels[key] = value
+	}
+	for key, value := range identity {
+		labels[key] = value
+	}
+	return labels
+}
+
+// getAppIdentity returns the labels identifying the app, CloudConfig or ClusterCloudConfig and environment of the
+// objects of the CloudConfigApp
+func getAppIdentity(app *k8v1alpha1.CloudConfigApp, spec *k8v1alpha1.CloudConfigSpec) map[string]string {
+	identity := map[string]string{k8v1alpha1.AppLabel: spec.AppName}
+	for _, key := range []string{k8v1alpha1.CloudConfigLabel, k8v1alpha1.ClusterCloudConfigLabel, k8v1alpha1.EnvLabel} {
+		if value := app.Labels[key]; value != "" {
+			identity[key] = value
+		}
+	}
+	return identity
+}
+
+// getConfigAnnotations returns the annotations of the label and version of the configuration of the objects of
+// an app, empty values are skipped. The annotations are not set on pod templates as the configuration changes
+// independently of the pods.
+func getConfigAnnotations(label, version string) map[string]string {
+	annotations := make(map[string]string, 2)
+	if label != "" {
+		annotations[k8v1alpha1.ConfigLabelAnnotation] = label
+	}
+	if version != "" {
+		annotations[k8v1alpha1.ConfigVersionAnnotation] = version
+	}
+	return annotations
+}
+
+// validateMetadata validates the common labels and annotations of the spec
+func validateMetadata(spec *k8v1alpha1.CloudConfigSpec) field.ErrorList {
+	validationErrors := field.ErrorList{}
+	for _, key := range getSortedKeys(spec.CommonLabels) {
+		path := field.NewPath("commonLabels").Key(key)
+		for _, msg := range validation.IsQualifiedName(key) {
+			validationErrors = append(validationErrors, field.Invalid(path, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(spec.CommonLabels[key]) {
+			validationErrors = append(validationErrors, field.Invalid(path, spec.CommonLabels[key], msg))
+		}
+	}
+	for _, key := range getSortedKeys(spec.CommonAnnotations) {
+		for _, msg := range validation.IsQualifiedName(key) {
+			validationErrors = append(validationErrors, field.Invalid(field.NewPath("commonAnnotations").Key(key), key, msg))
+		}
+	}
+	return validationErrors
+}
+
+// getSortedKeys returns the sorted keys of the map
+func getSortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetMetadata(t *testing.T) {
+	m, _ := parseManifest([]byte(testPolicyManifest))
+	m.setMetadata(map[string]string{"sys": "shop"}, map[string]string{"team": "alpha@example.com"})
+	m.setAnnotations(getConfigAnnotations("release", "0123456789ab"))
+
+	for _, obj := range m {
+		assert.Equal(t, "shop", obj.GetLabels()["sys"], obj.GetKind())
+		assert.Equal(t, map[string]string{
+			"team":                             "alpha@example.com",
+			k8v1alpha1.ConfigLabelAnnotation:   "release",
+			k8v1alpha1.ConfigVersionAnnotation: "0123456789ab",
+		}, obj.GetAnnotations(), obj.GetKind())
+	}
+
+	labels, _, _ := unstructured.NestedStringMap(m[0].Object, "spec", "template", "metadata", "labels")
+	assert.Equal(t, map[string]string{"sys": "shop"}, labels)
+	annotations, _, _ := unstructured.NestedStringMap(m[1].Object, "spec", "jobTemplate", "spec", "template", "metadata", "annotations")
+	assert.Equal(t, map[string]string{"team": "alpha@example.com"}, annotations,
+		"the configuration annotations should not be set on pod templates")
+	_, found, _ := unstructured.NestedMap(m[2].Object, "spec")
+	assert.False(t, found, "objects without pod templates should not get one")
+}
+
+func TestNewObjectLabels(t *testing.T) {
+	app := &k8v1alpha1.CloudConfigApp{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+		k8v1alpha1.CloudConfigLabel: "test",
+		k8v1alpha1.EnvLabel:         "dev",
+	}}}
+	spec := &k8v1alpha1.CloudConfigSpec{AppName: "alpha", CommonLabels: map[string]string{
+		"sys":               "shop",
+		k8v1alpha1.AppLabel: "beta",
+	}}
+
+	assert.Equal(t, map[string]string{
+		"sys":                       "shop",
+		k8v1alpha1.AppLabel:         "alpha",
+		k8v1alpha1.CloudConfigLabel: "test",
+		k8v1alpha1.EnvLabel:         "dev",
+	}, newObjectLabels(spec, getAppIdentity(app, spec)), "identifying labels should take precedence")
+
+	app.Labels = map[string]string{k8v1alpha1.ClusterCloudConfigLabel: "platform", k8v1alpha1.EnvLabel: "dev"}
+	assert.Equal(t, map[string]string{
+		k8v1alpha1.AppLabel:                "alpha",
+		k8v1alpha1.ClusterCloudConfigLabel: "platform",
+		k8v1alpha1.EnvLabel:                "dev",
+	}, getAppIdentity(app, spec))
+}
+
+func TestValidateMetadata(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{
+		CommonLabels:      map[string]string{"sys": "shop", "owner": "alpha@example.com"},
+		CommonAnnotations: map[string]string{"example.com/team": "alpha@example.com", "bad key": ""},
+	}
+	errs := validateMetadata(spec)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "commonLabels[owner]", errs[0].Field)
+	assert.Equal(t, "commonAnnotations[bad key]", errs[1].Field)
+}
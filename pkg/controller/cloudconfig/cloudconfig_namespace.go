@@ -0,0 +1,140 @@
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getTargetNamespace returns the namespace where the apps of the spec are applied, defaults to the namespace
+func getTargetNamespace(namespace string, spec *k8v1alpha1.CloudConfigSpec) string {
+	target := spec.TargetNamespace
+	fallBackIfEmpty(&target, namespace)
+	return target
+}
+
+// ensureTargetNamespace creates the target namespace if it does not exist and the spec requests it. Unless the
+// target is the source namespace, the target namespace must allow CloudConfigs of the source namespace to manage
+// it. An empty source namespace, i.e. a cluster scoped CloudConfig, is allowed to manage all namespaces. The
+// namespace is only created or updated if the impersonated user, if any, is allowed to do so.
+func ensureTargetNamespace(k8client client.Client, source, target, user string, spec *k8v1alpha1.CloudConfigSpec) error {
+	if target == "" || target == source {
+		return nil
+	}
+
+	ns := &corev1.Namespace{}
+	err := k8client.Get(context.TODO(), types.NamespacedName{Name: target}, ns)
+	if err != nil {
+		if !k8errors.IsNotFound(err) {
+			return err
+		}
+		if spec.CreateNamespace == nil {
+			return fmt.Errorf("target namespace '%s' does not exist", target)
+		}
+		if err := authorizeNamespace(k8client, user, "create", target); err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("Creating target namespace '%s'", target), "Namespace", source)
+		return k8client.Create(context.TODO(), newNamespace(source, target, spec.CreateNamespace))
+	}
+
+	if source != "" && !isNamespaceAllowed(ns, source) {
+		return fmt.Errorf("CloudConfigs in namespace '%s' are not allowed to manage namespace '%s', see the '%s' annotation",
+			source, target, k8v1alpha1.AllowedNamespacesAnnotation)
+	}
+
+	if spec.CreateNamespace != nil && mergeNamespaceMetadata(ns, spec.CreateNamespace) {
+		if err := authorizeNamespace(k8client, user, "update", target); err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("Updating target namespace '%s'", target), "Namespace", source)
+		return k8client.Update(context.TODO(), ns)
+	}
+	return nil
+}
+
+// authorizeNamespace verifies with a SubjectAccessReview that the impersonated user is allowed to create or update
+// the target namespace as the operator manages namespaces with its own permissions. Without a user the operator
+// is always authorized.
+func authorizeNamespace(k8client client.Client, user, verb, target string) error {
+	if user == "" {
+		return nil
+	}
+	review := newNamespaceAccessReview(user, verb, target)
+	if err := k8client.Create(context.TODO(), review); err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("'%s' is not allowed to %s namespace '%s'", user, verb, target)
+	}
+	return nil
+}
+
+// newNamespaceAccessReview returns a SubjectAccessReview of the verb on the target namespace for the user and the
+// groups of the user as impersonated by kubectl
+func newNamespaceAccessReview(user, verb, target string) *authorizationv1.SubjectAccessReview {
+	return &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user,
+		Groups: getServiceAccountGroups(user),
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Verb:     verb,
+			Resource: "namespaces",
+			Name:     target,
+		},
+	}}
+}
+
+// newNamespace returns the target namespace with the labels and annotations of the spec. CloudConfigs of the
+// source namespace are allowed to manage the namespace.
+func newNamespace(source, target string, spec *k8v1alpha1.NamespaceSpec) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: target}}
+	mergeNamespaceMetadata(ns, spec)
+	if source != "" && !isNamespaceAllowed(ns, source) {
+		ns.Annotations[k8v1alpha1.AllowedNamespacesAnnotation] = source
+	}
+	return ns
+}
+
+// mergeNamespaceMetadata sets the labels and annotations of the spec on the namespace, returns true if the
+// namespace was changed
+func mergeNamespaceMetadata(ns *corev1.Namespace, spec *k8v1alpha1.NamespaceSpec) bool {
+	if ns.Labels == nil {
+		ns.Labels = make(map[string]string, len(spec.Labels))
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = make(map[string]string, len(spec.Annotations)+1)
+	}
+	labelsChanged := mergeStringMap(ns.Labels, spec.Labels)
+	annotationsChanged := mergeStringMap(ns.Annotations, spec.Annotations)
+	return labelsChanged || annotationsChanged
+}
+
+func mergeStringMap(dst, src map[string]string) bool {
+	changed := false
+	for k, v := range src {
+		if existing, ok := dst[k]; !ok || existing != v {
+			dst[k] = v
+			changed = true
+		}
+	}
+	return changed
+}
+
+// isNamespaceAllowed returns true if the namespace allows CloudConfigs of the source namespace to manage it
+func isNamespaceAllowed(ns *corev1.Namespace, source string) bool {
+	for _, allowed := range strings.Split(ns.Annotations[k8v1alpha1.AllowedNamespacesAnnotation], ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || allowed == source {
+			return true
+		}
+	}
+	return false
+}
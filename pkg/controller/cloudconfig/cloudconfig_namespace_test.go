@@ -0,0 +1,74 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetTargetNamespace(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{}
+	assert.Equal(t, "ops", getTargetNamespace("ops", spec), "the target should default to the namespace")
+
+	spec.TargetNamespace = "apps"
+	assert.Equal(t, "apps", getTargetNamespace("ops", spec))
+}
+
+func TestNewNamespace(t *testing.T) {
+	spec := &k8v1alpha1.NamespaceSpec{
+		Labels:      map[string]string{"team": "alpha"},
+		Annotations: map[string]string{"owner": "alpha@example.com"},
+	}
+
+	ns := newNamespace("ops", "apps", spec)
+	assert.Equal(t, "apps", ns.Name)
+	assert.Equal(t, map[string]string{"team": "alpha"}, ns.Labels)
+	assert.Equal(t, map[string]string{
+		"owner":                                "alpha@example.com",
+		k8v1alpha1.AllowedNamespacesAnnotation: "ops",
+	}, ns.Annotations, "the source namespace should be allowed to manage the created namespace")
+
+	spec.Annotations[k8v1alpha1.AllowedNamespacesAnnotation] = "*"
+	ns = newNamespace("ops", "apps", spec)
+	assert.Equal(t, "*", ns.Annotations[k8v1alpha1.AllowedNamespacesAnnotation])
+}
+
+func TestMergeNamespaceMetadata(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "apps",
+		Labels: map[string]string{"team": "alpha", "tier": "backend"},
+	}}
+
+	spec := &k8v1alpha1.NamespaceSpec{Labels: map[string]string{"team": "alpha"}}
+	assert.False(t, mergeNamespaceMetadata(ns, spec), "unchanged metadata should not be reported as changed")
+
+	spec.Annotations = map[string]string{"owner": "alpha@example.com"}
+	assert.True(t, mergeNamespaceMetadata(ns, spec))
+	assert.Equal(t, map[string]string{"team": "alpha", "tier": "backend"}, ns.Labels, "existing labels should be kept")
+	assert.Equal(t, map[string]string{"owner": "alpha@example.com"}, ns.Annotations)
+}
+
+func TestIsNamespaceAllowed(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}}
+	assert.False(t, isNamespaceAllowed(ns, "ops"), "namespaces without annotation should not be allowed")
+
+	ns.Annotations = map[string]string{k8v1alpha1.AllowedNamespacesAnnotation: "ops, platform"}
+	assert.True(t, isNamespaceAllowed(ns, "ops"))
+	assert.True(t, isNamespaceAllowed(ns, "platform"))
+	assert.False(t, isNamespaceAllowed(ns, "dev"))
+
+	ns.Annotations[k8v1alpha1.AllowedNamespacesAnnotation] = "*"
+	assert.True(t, isNamespaceAllowed(ns, "dev"))
+}
+
+func TestNewNamespaceAccessReview(t *testing.T) {
+	review := newNamespaceAccessReview("system:serviceaccount:team:deployer", "create", "apps")
+	assert.Equal(t, "system:serviceaccount:team:deployer", review.Spec.User)
+	assert.Equal(t, []string{"system:serviceaccounts", "system:serviceaccounts:team", "system:authenticated"}, review.Spec.Groups)
+	assert.Equal(t, &authorizationv1.ResourceAttributes{Verb: "create", Resource: "namespaces", Name: "apps"},
+		review.Spec.ResourceAttributes)
+}
@@ -0,0 +1,106 @@
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getConfigNamespace returns the namespace of the CloudConfig of a CloudConfigEnv or CloudConfigApp, where the
+// credentials, trust store secrets and ServiceAccount of the CloudConfig are found; the namespace of a CloudConfig
+// itself and the namespace of the operator for a ClusterCloudConfig and its apps. The CloudConfig is identified by
+// the controller reference of the object or by the CloudConfig declaring the environment of a CloudConfigEnv in
+// another namespace, never by the labels of the object which can be set by anyone allowed to create it.
+func getConfigNamespace(k8client client.Client, obj metav1.Object) (string, error) {
+	switch o := obj.(type) {
+	case *k8v1alpha1.ClusterCloudConfig:
+		return getOperatorNamespace(), nil
+	case *k8v1alpha1.CloudConfigEnv:
+		return getEnvConfigNamespace(k8client, o)
+	case *k8v1alpha1.CloudConfigApp:
+		return getAppConfigNamespace(k8client, o)
+	}
+	return obj.GetNamespace(), nil
+}
+
+// getEnvConfigNamespace returns the namespace of the CloudConfig declaring the environment of the CloudConfigEnv
+func getEnvConfigNamespace(k8client client.Client, env *k8v1alpha1.CloudConfigEnv) (string, error) {
+	if owner := metav1.GetControllerOf(env); owner != nil && owner.Kind == "CloudConfig" {
+		// owner references cannot cross namespaces
+		return env.Namespace, nil
+	}
+	c, err := getEnvCloudConfig(k8client, env)
+	if err != nil {
+		return "", err
+	}
+	return c.Namespace, nil
+}
+
+// getEnvCloudConfig returns the CloudConfig declaring the environment of the CloudConfigEnv, an error if no or
+// more than one CloudConfig declares it
+func getEnvCloudConfig(k8client client.Client, env *k8v1alpha1.CloudConfigEnv) (*k8v1alpha1.CloudConfig, error) {
+	list := &k8v1alpha1.CloudConfigList{}
+	if err := k8client.List(context.TODO(), &client.ListOptions{}, list); err != nil {
+		return nil, err
+	}
+	var found *k8v1alpha1.CloudConfig
+	for i := range list.Items {
+		c := &list.Items[i]
+		if !declaresEnv(c, env) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("CloudConfigEnv '%s' in namespace '%s' is declared by both CloudConfig '%s/%s' and '%s/%s'",
+				env.Name, env.Namespace, found.Namespace, found.Name, c.Namespace, c.Name)
+		}
+		found = c
+	}
+	if found == nil {
+		return nil, fmt.Errorf("CloudConfigEnv '%s' in namespace '%s' is not an environment of any CloudConfig",
+			env.Name, env.Namespace)
+	}
+	return found, nil
+}
+
+// declaresEnv returns true if the CloudConfigEnv is the CloudConfigEnv of one of the environments of the CloudConfig
+func declaresEnv(c *k8v1alpha1.CloudConfig, env *k8v1alpha1.CloudConfigEnv) bool {
+	for key := range c.Spec.Environments {
+		declared := newCloudConfigEnv(c, key)
+		if declared.Name == env.Name && declared.Namespace == env.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// getAppConfigNamespace returns the namespace of the CloudConfig of the CloudConfigApp, identified by the controller
+// reference of the app; the namespace of the app if it has no controller
+func getAppConfigNamespace(k8client client.Client, app *k8v1alpha1.CloudConfigApp) (string, error) {
+	owner := metav1.GetControllerOf(app)
+	if owner == nil {
+		return app.Namespace, nil
+	}
+	switch owner.Kind {
+	case "ClusterCloudConfig":
+		if app.Namespace != getOperatorNamespace() {
+			return "", fmt.Errorf("CloudConfigApp '%s' of ClusterCloudConfig '%s' is not in the operator namespace '%s'",
+				app.Name, owner.Name, getOperatorNamespace())
+		}
+		return app.Namespace, nil
+	case "CloudConfigEnv":
+		env := &k8v1alpha1.CloudConfigEnv{}
+		name := types.NamespacedName{Name: owner.Name, Namespace: app.Namespace}
+		if err := k8client.Get(context.TODO(), name, env); err != nil {
+			return "", err
+		}
+		if env.UID != owner.UID {
+			return "", fmt.Errorf("CloudConfigEnv '%s' owning CloudConfigApp '%s' has been replaced", owner.Name, app.Name)
+		}
+		return getEnvConfigNamespace(k8client, env)
+	}
+	return app.Namespace, nil
+}
@@ -0,0 +1,113 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis"
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// newTestScheme returns a scheme of the operator's API types
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newFakeClient returns a fake client of the operator's API types holding the objects
+func newFakeClient(t *testing.T, objs ...runtime.Object) client.Client {
+	return fake.NewFakeClientWithScheme(newTestScheme(t), objs...)
+}
+
+// newTeamCloudConfig returns a CloudConfig in namespace 'ops' with a 'dev' environment in namespace 'team-dev',
+// its CloudConfigEnv and an app of the environment
+func newTeamCloudConfig(t *testing.T) (*k8v1alpha1.CloudConfig, *k8v1alpha1.CloudConfigEnv, *k8v1alpha1.CloudConfigApp) {
+	c := &k8v1alpha1.CloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "ops"},
+		Spec: k8v1alpha1.CloudConfigSpec{
+			Environments: map[string]k8v1alpha1.EnvironmentSpec{"dev": {Namespace: "team-dev"}},
+		},
+	}
+	env := newCloudConfigEnv(c, "dev")
+	env.UID = "env-uid"
+	app := newCloudConfigApp(env.Name, env.Namespace, c.Spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "alpha"}), env.Labels)
+	if err := controllerutil.SetControllerReference(env, app, newTestScheme(t)); err != nil {
+		t.Fatal(err)
+	}
+	return c, env, app
+}
+
+func TestGetConfigNamespace(t *testing.T) {
+	c, env, app := newTeamCloudConfig(t)
+	k8client := newFakeClient(t, c, env)
+
+	namespace, err := getConfigNamespace(k8client, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", namespace)
+
+	namespace, err = getConfigNamespace(k8client, env)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", namespace, "the secrets of an environment should be found with its CloudConfig")
+
+	assert.Equal(t, "team-dev", app.Namespace)
+	namespace, err = getConfigNamespace(k8client, app)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", namespace, "the secrets of an app should be found with its CloudConfig")
+
+	cluster := &k8v1alpha1.ClusterCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	namespace, err = getConfigNamespace(k8client, cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, getOperatorNamespace(), namespace)
+}
+
+func TestGetConfigNamespaceIgnoresLabels(t *testing.T) {
+	c, env, app := newTeamCloudConfig(t)
+
+	// a CloudConfigEnv created by a tenant claiming to belong to a CloudConfig of another namespace
+	forged := newCloudConfigEnv(c, "dev")
+	forged.Name = "cluster-test"
+	_, err := getConfigNamespace(newFakeClient(t, c, forged), forged)
+	assert.EqualError(t, err, "CloudConfigEnv 'cluster-test' in namespace 'team-dev' is not an environment of any CloudConfig")
+
+	// an app without a controller is an app of its own namespace whatever its labels
+	orphan := app.DeepCopy()
+	orphan.OwnerReferences = nil
+	namespace, err := getConfigNamespace(newFakeClient(t, c, env), orphan)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-dev", namespace)
+
+	// an app of a CloudConfigEnv that has been replaced
+	replaced := env.DeepCopy()
+	replaced.UID = "other-uid"
+	_, err = getConfigNamespace(newFakeClient(t, c, replaced), app)
+	assert.EqualError(t, err, "CloudConfigEnv 'cluster-dev' owning CloudConfigApp 'cluster-dev-alpha' has been replaced")
+
+	// an app in another namespace than the operator claiming to belong to a ClusterCloudConfig
+	cluster := app.DeepCopy()
+	cluster.OwnerReferences[0].Kind = "ClusterCloudConfig"
+	_, err = getConfigNamespace(newFakeClient(t, c, env), cluster)
+	assert.Error(t, err)
+}
+
+func TestGetEnvCloudConfig(t *testing.T) {
+	c, env, _ := newTeamCloudConfig(t)
+	other := c.DeepCopy()
+	other.Namespace = "other"
+
+	found, err := getEnvCloudConfig(newFakeClient(t, c), env)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", found.Namespace)
+
+	_, err = getEnvCloudConfig(newFakeClient(t, c, other), env)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is declared by both CloudConfig")
+	}
+}
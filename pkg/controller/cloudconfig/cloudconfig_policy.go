@@ -0,0 +1,268 @@
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Keys of the policy ConfigMap, rules of missing keys are not checked
+const (
+	// policyEnforcementKey is the enforcement mode of the policy, `Block` (default) or `Warn`
+	policyEnforcementKey = "enforcement"
+	// policyDenyPrivilegedKey denies privileged containers if `true`
+	policyDenyPrivilegedKey = "denyPrivileged"
+	// policyDenyHostPathKey denies hostPath volumes if `true`
+	policyDenyHostPathKey = "denyHostPath"
+	// policyRequiredLimitsKey lists the comma separated resources every container must have a limit for, e.g.
+	// `cpu,memory`
+	policyRequiredLimitsKey = "requiredLimits"
+	// policyAllowedRegistriesKey lists the comma separated registries, optionally with a repository path, images
+	// must be pulled from, e.g. `docker.io,registry.example.com/team`
+	policyAllowedRegistriesKey = "allowedRegistries"
+	// policyDenyLatestTagKey denies images with the `latest` tag or without a tag or digest if `true`
+	policyDenyLatestTagKey = "denyLatestTag"
+)
+
+// policyEnforcement is the enforcement mode of a policy
+type policyEnforcement string
+
+const (
+	// policyBlock fails the synchronization of apps with policy violations
+	policyBlock policyEnforcement = "Block"
+	// policyWarn applies apps with policy violations and reports the violations
+	policyWarn policyEnforcement = "Warn"
+)
+
+// defaultRegistry is the registry of images without a registry
+const defaultRegistry = "docker.io"
+
+// workloadPolicy holds the rules of a policy ConfigMap checked for the pods of the workloads of an app
+type workloadPolicy struct {
+	name              string
+	enforcement       policyEnforcement
+	denyPrivileged    bool
+	denyHostPath      bool
+	requiredLimits    []string
+	allowedRegistries []string
+	denyLatestTag     bool
+}
+
+// getPolicy returns the policy of the ConfigMap named by the spec in the namespace, nil if the spec has no policy
+func getPolicy(k8client client.Client, namespace string, spec *k8v1alpha1.CloudConfigSpec) (*workloadPolicy, error) {
+	if spec.Policy == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := k8client.Get(context.TODO(), types.NamespacedName{Name: spec.Policy, Namespace: namespace}, cm); err != nil {
+		return nil, fmt.Errorf("could not get the policy ConfigMap '%s': %s", spec.Policy, err.Error())
+	}
+	return parsePolicy(cm)
+}
+
+// parsePolicy parses the rules of a policy ConfigMap
+func parsePolicy(cm *corev1.ConfigMap) (*workloadPolicy, error) {
+	p := &workloadPolicy{name: cm.Name, enforcement: policyBlock}
+	if value, found := cm.Data[policyEnforcementKey]; found {
+		p.enforcement = policyEnforcement(value)
+		if p.enforcement != policyBlock && p.enforcement != policyWarn {
+			return nil, fmt.Errorf("invalid %s '%s' of policy '%s', must be %s or %s", policyEnforcementKey, value,
+				cm.Name, policyBlock, policyWarn)
+		}
+	}
+
+	for _, rule := range []struct {
+		key     string
+		enabled *bool
+	}{
+		{policyDenyPrivilegedKey, &p.denyPrivileged},
+		{policyDenyHostPathKey, &p.denyHostPath},
+		{policyDenyLatestTagKey, &p.denyLatestTag},
+	} {
+		value, found := cm.Data[rule.key]
+		if !found {
+			continue
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s' of policy '%s', must be true or false", rule.key, value, cm.Name)
+		}
+		*rule.enabled = enabled
+	}
+	p.requiredLimits = splitList(cm.Data[policyRequiredLimitsKey])
+	p.allowedRegistries = splitList(cm.Data[policyAllowedRegistriesKey])
+	return p, nil
+}
+
+// splitList returns the trimmed non-empty entries of the comma separated list
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// check returns the violations of the policy by the pods of the workloads of the manifest, each naming the
+// object and the container or volume violating the policy
+func (p *workloadPolicy) check(m manifest) []string {
+	var violations []string
+	for _, obj := range m {
+		podSpec := getPodSpec(obj)
+		if podSpec == nil {
+			continue
+		}
+		for _, violation := range p.checkPodSpec(podSpec) {
+			violations = append(violations, fmt.Sprintf("%s '%s': %s", obj.GetKind(), obj.GetName(), violation))
+		}
+	}
+	return violations
+}
+
+// checkPodSpec returns the violations of the policy by the containers and volumes of the pod spec
+func (p *workloadPolicy) checkPodSpec(podSpec map[string]interface{}) []string {
+	var violations []string
+	for _, container := range getContainers(podSpec) {
+		name, _ := container["name"].(string)
+		if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); p.denyPrivileged && privileged {
+			violations = append(violations, fmt.Sprintf("container '%s' is privileged", name))
+		}
+		limits, _, _ := unstructured.NestedMap(container, "resources", "limits")
+		for _, resource := range p.requiredLimits {
+			if _, found := limits[resource]; !found {
+				violations = append(violations, fmt.Sprintf("container '%s' has no %s limit", name, resource))
+			}
+		}
+		image, _ := container["image"].(string)
+		if len(p.allowedRegistries) > 0 && !isAllowedImage(image, p.allowedRegistries) {
+			violations = append(violations, fmt.Sprintf("container '%s' image '%s' is not from an allowed registry", name, image))
+		}
+		if p.denyLatestTag && hasLatestTag(image) {
+			violations = append(violations, fmt.Sprintf("container '%s' image '%s' uses the latest tag", name, image))
+		}
+	}
+
+	volumes, _, _ := unstructured.NestedSlice(podSpec, "volumes")
+	for _, v := range volumes {
+		volume, _ := v.(map[string]interface{})
+		if _, found := volume["hostPath"]; p.denyHostPath && found {
+			violations = append(violations, fmt.Sprintf("volume '%s' is a hostPath volume", volume["name"]))
+		}
+	}
+	return violations
+}
+
+// getPodSpec returns the pod spec of a Pod, of the pod template of a workload or of the job template of a
+// CronJob, nil if the object has no pod spec. The pod spec is not copied.
+func getPodSpec(obj *unstructured.Unstructured) map[string]interface{} {
+	paths := make([][]string, 0, len(podTemplatePaths)+1)
+	for _, path := range podTemplatePaths {
+		paths = append(paths, append(append([]string{}, path...), "spec"))
+	}
+	if obj.GetKind() == "Pod" {
+		paths = append(paths, []string{"spec"})
+	}
+	for _, path := range paths {
+		value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+		if podSpec, ok := value.(map[string]interface{}); found && ok {
+			return podSpec
+		}
+	}
+	return nil
+}
+
+// getContainers returns the init containers and containers of the pod spec, the containers are not copied
+func getContainers(podSpec map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := podSpec[field].([]interface{})
+		for _, c := range list {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
+
+// getImageRegistry returns the registry of the image, `docker.io` if the image does not name a registry
+func getImageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return defaultRegistry
+	}
+	if host := image[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+		return host
+	}
+	return defaultRegistry
+}
+
+// isAllowedImage returns true if the image is pulled from any of the registries, a registry with a repository
+// path allows the images below the path
+func isAllowedImage(image string, registries []string) bool {
+	registry := getImageRegistry(image)
+	if !strings.HasPrefix(image, registry+"/") {
+		image = registry + "/" + image
+	}
+	for _, allowed := range registries {
+		if registry == allowed || strings.HasPrefix(image, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLatestTag returns true if the image has the `latest` tag or neither a tag nor a digest
+func hasLatestTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i < 0 || name[i+1:] == "latest"
+}
+
+// checkPolicy returns the violations of the policy of the spec by the objects of the manifest, the policy
+// ConfigMap is read from the namespace. The error lists the violations of a blocking policy.
+func checkPolicy(
+	k8client client.Client,
+	namespace string,
+	spec *k8v1alpha1.CloudConfigSpec,
+	m manifest) ([]string, error) {
+
+	p, err := getPolicy(k8client, namespace, spec)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	violations := p.check(m)
+	return violations, p.enforce(spec.AppName, violations)
+}
+
+// enforce returns an error listing the violations of a blocking policy, violations of a warning policy are only
+// reported
+func (p *workloadPolicy) enforce(app string, violations []string) error {
+	if p.enforcement == policyBlock && len(violations) > 0 {
+		return fmt.Errorf("objects of app '%s' violate policy '%s': %s", app, p.name, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// recordViolations records a warning event for the policy violations unless the same violations were found by the
+// previous synchronization
+func recordViolations(recorder record.EventRecorder, obj runtime.Object, previous, violations []string) {
+	if len(violations) > 0 && !reflect.DeepEqual(previous, violations) {
+		recorder.Event(obj, corev1.EventTypeWarning, "PolicyViolation", "Policy violations: "+strings.Join(violations, "; "))
+	}
+}
@@ -0,0 +1,154 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testPolicyManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: registry.example.com/team/init:1.0
+        resources:
+          limits: { cpu: 100m, memory: 64Mi }
+      containers:
+      - name: alpha
+        image: nginx
+        securityContext:
+          privileged: true
+        resources:
+          limits: { memory: 128Mi }
+      volumes:
+      - name: config
+        configMap: { name: alpha }
+      - name: docker
+        hostPath: { path: /var/run/docker.sock }
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: registry.example.com/other/cleanup:latest
+            resources:
+              limits: { cpu: 100m, memory: 64Mi }
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: alpha
+`
+
+func newTestPolicy(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "restricted"}, Data: data}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := parsePolicy(newTestPolicy(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, &workloadPolicy{name: "restricted", enforcement: policyBlock}, p, "policies should block by default")
+
+	p, err = parsePolicy(newTestPolicy(map[string]string{
+		policyEnforcementKey:       "Warn",
+		policyDenyPrivilegedKey:    "true",
+		policyDenyLatestTagKey:     "false",
+		policyRequiredLimitsKey:    "cpu, memory,",
+		policyAllowedRegistriesKey: "registry.example.com/team",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, &workloadPolicy{
+		name:              "restricted",
+		enforcement:       policyWarn,
+		denyPrivileged:    true,
+		requiredLimits:    []string{"cpu", "memory"},
+		allowedRegistries: []string{"registry.example.com/team"},
+	}, p)
+
+	_, err = parsePolicy(newTestPolicy(map[string]string{policyEnforcementKey: "Audit"}))
+	assert.EqualError(t, err, "invalid enforcement 'Audit' of policy 'restricted', must be Block or Warn")
+	_, err = parsePolicy(newTestPolicy(map[string]string{policyDenyHostPathKey: "yes"}))
+	assert.EqualError(t, err, "invalid denyHostPath 'yes' of policy 'restricted', must be true or false")
+}
+
+func TestCheckPolicy(t *testing.T) {
+	m, _ := parseManifest([]byte(testPolicyManifest))
+	p, _ := parsePolicy(newTestPolicy(map[string]string{
+		policyDenyPrivilegedKey:    "true",
+		policyDenyHostPathKey:      "true",
+		policyDenyLatestTagKey:     "true",
+		policyRequiredLimitsKey:    "cpu,memory",
+		policyAllowedRegistriesKey: "registry.example.com/team",
+	}))
+
+	violations := p.check(m)
+	assert.Equal(t, []string{
+		"Deployment 'alpha': container 'alpha' is privileged",
+		"Deployment 'alpha': container 'alpha' has no cpu limit",
+		"Deployment 'alpha': container 'alpha' image 'nginx' is not from an allowed registry",
+		"Deployment 'alpha': container 'alpha' image 'nginx' uses the latest tag",
+		"Deployment 'alpha': volume 'docker' is a hostPath volume",
+		"CronJob 'cleanup': container 'cleanup' image 'registry.example.com/other/cleanup:latest' is not from an allowed registry",
+		"CronJob 'cleanup': container 'cleanup' image 'registry.example.com/other/cleanup:latest' uses the latest tag",
+	}, violations)
+	assert.EqualError(t, p.enforce("alpha", violations[:1]),
+		"objects of app 'alpha' violate policy 'restricted': Deployment 'alpha': container 'alpha' is privileged")
+
+	p.enforcement = policyWarn
+	assert.NoError(t, p.enforce("alpha", violations), "violations of a warning policy should not fail the app")
+	assert.Empty(t, (&workloadPolicy{}).check(m), "an empty policy should not have violations")
+}
+
+func TestCheckPolicyList(t *testing.T) {
+	m, _ := parseManifest([]byte(`apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: alpha
+  spec:
+    template:
+      spec:
+        containers:
+        - name: alpha
+          image: nginx
+          securityContext:
+            privileged: true
+`))
+	p, _ := parsePolicy(newTestPolicy(map[string]string{policyDenyPrivilegedKey: "true"}))
+	assert.Equal(t, []string{"Deployment 'alpha': container 'alpha' is privileged"}, p.check(m),
+		"the items of lists should be checked")
+}
+
+func TestImages(t *testing.T) {
+	assert.Equal(t, "docker.io", getImageRegistry("nginx:1.17"))
+	assert.Equal(t, "docker.io", getImageRegistry("library/nginx"))
+	assert.Equal(t, "localhost", getImageRegistry("localhost/nginx"))
+	assert.Equal(t, "registry.example.com:5000", getImageRegistry("registry.example.com:5000/nginx"))
+
+	assert.True(t, isAllowedImage("nginx", []string{"docker.io"}))
+	assert.True(t, isAllowedImage("library/nginx", []string{"docker.io/library"}))
+	assert.True(t, isAllowedImage("registry.example.com/team/alpha:1.0", []string{"registry.example.com/team/"}))
+	assert.False(t, isAllowedImage("registry.example.com/teams/alpha:1.0", []string{"registry.example.com/team"}))
+
+	assert.True(t, hasLatestTag("nginx"))
+	assert.True(t, hasLatestTag("registry.example.com:5000/nginx"))
+	assert.True(t, hasLatestTag("nginx:latest"))
+	assert.False(t, hasLatestTag("registry.example.com:5000/nginx:1.17"))
+	assert.False(t, hasLatestTag("nginx@sha256:2f1cd90e00fe2c991e18272bb35d6a8258eeb27785d121aa4cc1ae4235167cfd"))
+}
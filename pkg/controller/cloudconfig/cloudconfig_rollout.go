@@ -0,0 +1,70 @@
+package cloudconfig
+
+import (
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultProgressDeadline is the time the objects of a revision have to become healthy by default
+const defaultProgressDeadline = 10 * time.Minute
+
+// rolloutCheckInterval is the time between checks of a progressing rollout
+const rolloutCheckInterval = 10 * time.Second
+
+// getProgressDeadline returns the time the objects of a revision have to become healthy
+func getProgressDeadline(spec *k8v1alpha1.CloudConfigSpec) time.Duration {
+	if spec.Rollback != nil && spec.Rollback.ProgressDeadline != nil && spec.Rollback.ProgressDeadline.Duration > 0 {
+		return spec.Rollback.ProgressDeadline.Duration
+	}
+	return defaultProgressDeadline
+}
+
+// isProgressing returns true if the rollout of the last applied revision is progressing
+func isProgressing(status *k8v1alpha1.CloudConfigAppStatus) bool {
+	return status.Rollout != nil && status.Rollout.Phase == k8v1alpha1.RolloutProgressing
+}
+
+// isWaiting returns true if the rollout of the last applied revision is progressing or the app is not yet healthy
+func isWaiting(status *k8v1alpha1.CloudConfigAppStatus) bool {
+	return isProgressing(status) || status.Health == k8v1alpha1.HealthProgressing
+}
+
+// isHeldBack returns true if the synchronization of the app is held back by a sync wave or by its dependencies
+func isHeldBack(status *k8v1alpha1.CloudConfigAppStatus) bool {
+	return status.Wave != nil || status.HeldBack != ""
+}
+
+// isRolledBack returns true if the revision was rolled back after its rollout failed
+func isRolledBack(status *k8v1alpha1.CloudConfigAppStatus, revision string) bool {
+	return status.Rollout != nil &&
+		status.Rollout.Phase == k8v1alpha1.RolloutRolledBack &&
+		status.Rollout.Revision == revision
+}
+
+// newRolloutStatus returns the status of a rollout of the revision started now
+func newRolloutStatus(revision string) *k8v1alpha1.RolloutStatus {
+	return &k8v1alpha1.RolloutStatus{
+		Revision: revision,
+		Phase:    k8v1alpha1.RolloutProgressing,
+		Started:  metav1.Now(),
+	}
+}
+
+// getInt64 returns the integer field of the object or the default value if the field is not set. Both int64
+// and float64 values are accepted as objects parsed from JSON hold float64 numbers.
+func getInt64(obj *unstructured.Unstructured, defaultValue int64, fields ...string) int64 {
+	value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	if !found {
+		return defaultValue
+	}
+	switch v := value.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return defaultValue
+}
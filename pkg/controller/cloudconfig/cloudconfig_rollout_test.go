@@ -0,0 +1,40 @@
+package cloudconfig
+
+import (
+	"testing"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetProgressDeadline(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{}
+	assert.Equal(t, defaultProgressDeadline, getProgressDeadline(spec))
+
+	spec.Rollback = &k8v1alpha1.RollbackSpec{}
+	assert.Equal(t, defaultProgressDeadline, getProgressDeadline(spec))
+
+	spec.Rollback.ProgressDeadline = &metav1.Duration{Duration: 5 * time.Minute}
+	assert.Equal(t, 5*time.Minute, getProgressDeadline(spec))
+}
+
+func TestRolloutPhases(t *testing.T) {
+	status := &k8v1alpha1.CloudConfigAppStatus{}
+	assert.False(t, isProgressing(status))
+	assert.False(t, isRolledBack(status, "0123456789ab"))
+
+	status.Rollout = newRolloutStatus("0123456789ab")
+	assert.True(t, isProgressing(status))
+	assert.False(t, isRolledBack(status, "0123456789ab"))
+
+	status.Rollout.Phase = k8v1alpha1.RolloutRolledBack
+	assert.False(t, isProgressing(status))
+	assert.True(t, isRolledBack(status, "0123456789ab"))
+	assert.False(t, isRolledBack(status, "ba9876543210"), "new revisions should be applied after a rollback")
+	assert.False(t, isWaiting(status))
+
+	status.Health = k8v1alpha1.HealthProgressing
+	assert.True(t, isWaiting(status), "apps should be checked until they are healthy")
+}
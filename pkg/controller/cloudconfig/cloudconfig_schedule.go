@@ -0,0 +1,159 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/chrsoo/cloud-config-operator/pkg/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// getNextSync returns the time of the next synchronization after the synchronization started at start, given by
+// the schedule or the period of the spec. The result is the zero time for one-off synchronizations, i.e. if the
+// spec defines neither a schedule nor a period.
+func getNextSync(spec *k8v1alpha1.CloudConfigSpec, start time.Time) (time.Time, error) {
+	if spec.Schedule != "" {
+		schedule, err := cron.Parse(spec.Schedule)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return schedule.Next(time.Now()), nil
+	}
+
+	if spec.Period > 0 {
+		next, onTime := spec.GetDurationUntilNextCycle(start)
+		if !onTime {
+			log.Info("Skipping one or more cycles as synchronization took too long, consider prolonging the period!")
+		}
+		return time.Now().Add(next), nil
+	}
+	return time.Time{}, nil
+}
+
+// requeueAt returns the result requeuing the request at the given time, the request is not requeued for the zero
+// time
+func requeueAt(next time.Time) reconcile.Result {
+	if next.IsZero() {
+		return reconcile.Result{}
+	}
+	return reconcile.Result{Requeue: true, RequeueAfter: time.Until(next)}
+}
+
+// newTime returns the metav1.Time of the time or nil for the zero time
+func newTime(t time.Time) *metav1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	mt := metav1.NewTime(t)
+	return &mt
+}
+
+// checkSyncWindows returns true if the sync windows allow apps to be applied at the given time, i.e. if no deny
+// window is active and, if there are allow windows, an allow window is active. If apps may not be applied the
+// time when the windows change is returned, i.e. the end of the active deny windows or the start of the next
+// allow window.
+func checkSyncWindows(windows []k8v1alpha1.SyncWindow, now time.Time) (bool, time.Time, error) {
+	var allowWindows, allowActive, denyActive bool
+	var nextAllow, denyEnd time.Time
+	for _, window := range windows {
+		schedule, err := parseSyncWindow(window)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+
+		end, active := getSyncWindowEnd(schedule, window.Duration.Duration, now)
+		switch window.Kind {
+		case k8v1alpha1.SyncWindowAllow:
+			allowWindows = true
+			if active {
+				allowActive = true
+			} else if next := schedule.Next(now); !next.IsZero() && (nextAllow.IsZero() || next.Before(nextAllow)) {
+				nextAllow = next
+			}
+		case k8v1alpha1.SyncWindowDeny:
+			if active {
+				denyActive = true
+				if end.After(denyEnd) {
+					denyEnd = end
+				}
+			}
+		}
+	}
+
+	switch {
+	case denyActive:
+		return false, denyEnd, nil
+	case allowWindows && !allowActive:
+		return false, nextAllow, nil
+	}
+	return true, time.Time{}, nil
+}
+
+// parseSyncWindow parses the schedule of the sync window in the window's time zone
+func parseSyncWindow(window k8v1alpha1.SyncWindow) (*cron.Schedule, error) {
+	loc, err := time.LoadLocation(window.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	return cron.ParseInLocation(window.Schedule, loc)
+}
+
+// getSyncWindowEnd returns the end of the window of the schedule and duration that is active at the given time,
+// if any. If several windows overlap the end of the last window is returned.
+func getSyncWindowEnd(schedule *cron.Schedule, duration time.Duration, now time.Time) (time.Time, bool) {
+	var end time.Time
+	for start := schedule.Next(now.Add(-duration)); !start.IsZero() && !start.After(now); start = schedule.Next(start) {
+		end = start.Add(duration)
+	}
+	return end, !end.IsZero()
+}
+
+// forceSyncPrefix is the prefix of sync annotation values that override the sync windows
+const forceSyncPrefix = "force"
+
+// getSyncRequest returns the value of the sync annotation of the object if it differs from the last handled
+// request, and whether the request overrides the sync windows
+func getSyncRequest(obj metav1.Object, lastRequest string) (string, bool) {
+	request := obj.GetAnnotations()[k8v1alpha1.SyncAnnotation]
+	if request == "" || request == lastRequest {
+		return "", false
+	}
+	return request, strings.HasPrefix(request, forceSyncPrefix)
+}
+
+// validateSchedule validates the schedule, period and sync windows of the spec
+func validateSchedule(spec *k8v1alpha1.CloudConfigSpec) field.ErrorList {
+	validationErrors := field.ErrorList{}
+	if spec.Schedule != "" {
+		path := field.NewPath("schedule")
+		if _, err := cron.Parse(spec.Schedule); err != nil {
+			validationErrors = append(validationErrors, field.Invalid(path, spec.Schedule, err.Error()))
+		}
+		if spec.Period > 0 {
+			validationErrors = append(validationErrors, field.Forbidden(path, "schedule cannot be combined with period"))
+		}
+	}
+
+	for i, window := range spec.SyncWindows {
+		path := field.NewPath("syncWindows").Index(i)
+		if window.Kind != k8v1alpha1.SyncWindowAllow && window.Kind != k8v1alpha1.SyncWindowDeny {
+			validationErrors = append(validationErrors, field.NotSupported(path.Child("kind"), window.Kind,
+				[]string{string(k8v1alpha1.SyncWindowAllow), string(k8v1alpha1.SyncWindowDeny)}))
+		}
+		if window.Duration.Duration <= 0 {
+			validationErrors = append(validationErrors, field.Invalid(path.Child("duration"), window.Duration.String(),
+				"duration must be positive"))
+		}
+		if _, err := time.LoadLocation(window.TimeZone); err != nil {
+			validationErrors = append(validationErrors, field.Invalid(path.Child("timeZone"), window.TimeZone,
+				fmt.Sprintf("unknown time zone: %s", err.Error())))
+		} else if _, err := parseSyncWindow(window); err != nil {
+			validationErrors = append(validationErrors, field.Invalid(path.Child("schedule"), window.Schedule, err.Error()))
+		}
+	}
+	return validationErrors
+}
@@ -0,0 +1,136 @@
+package cloudconfig
+
+import (
+	"testing"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newSyncWindow(kind k8v1alpha1.SyncWindowKind, schedule string, duration time.Duration) k8v1alpha1.SyncWindow {
+	return k8v1alpha1.SyncWindow{Kind: kind, Schedule: schedule, Duration: metav1.Duration{Duration: duration}}
+}
+
+func TestGetNextSync(t *testing.T) {
+	next, err := getNextSync(&k8v1alpha1.CloudConfigSpec{}, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, next.IsZero(), "one-off synchronizations should not be rescheduled")
+
+	next, err = getNextSync(&k8v1alpha1.CloudConfigSpec{Period: 60}, time.Now())
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), next, time.Second)
+
+	next, err = getNextSync(&k8v1alpha1.CloudConfigSpec{Schedule: "* * * * *"}, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, next.After(time.Now()))
+	assert.Equal(t, 0, next.Second())
+
+	_, err = getNextSync(&k8v1alpha1.CloudConfigSpec{Schedule: "every minute"}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestCheckSyncWindows(t *testing.T) {
+	// Wednesday
+	now := time.Date(2019, 5, 15, 18, 30, 0, 0, time.UTC)
+	businessHours := newSyncWindow(k8v1alpha1.SyncWindowAllow, "0 8 * * MON-FRI", 9*time.Hour)
+	evening := newSyncWindow(k8v1alpha1.SyncWindowDeny, "0 18 * * *", 2*time.Hour)
+
+	allowed, _, err := checkSyncWindows(nil, now)
+	assert.NoError(t, err)
+	assert.True(t, allowed, "synchronization should be allowed without windows")
+
+	allowed, change, err := checkSyncWindows([]k8v1alpha1.SyncWindow{businessHours}, now)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "synchronization should be denied outside of the allow windows")
+	assert.Equal(t, time.Date(2019, 5, 16, 8, 0, 0, 0, time.UTC), change)
+
+	allowed, _, err = checkSyncWindows([]k8v1alpha1.SyncWindow{businessHours}, now.Add(-2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, change, err = checkSyncWindows([]k8v1alpha1.SyncWindow{evening}, now)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "synchronization should be denied in the deny windows")
+	assert.Equal(t, time.Date(2019, 5, 15, 20, 0, 0, 0, time.UTC), change)
+
+	allowed, _, err = checkSyncWindows([]k8v1alpha1.SyncWindow{evening}, now.Add(90*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, allowed, "the deny window should end after its duration")
+
+	longHours := newSyncWindow(k8v1alpha1.SyncWindowAllow, "0 8 * * MON-FRI", 12*time.Hour)
+	allowed, change, err = checkSyncWindows([]k8v1alpha1.SyncWindow{longHours, evening}, now)
+	assert.NoError(t, err)
+	assert.False(t, allowed, "deny windows should take precedence over allow windows")
+	assert.Equal(t, time.Date(2019, 5, 15, 20, 0, 0, 0, time.UTC), change)
+}
+
+func TestCheckSyncWindowsTimeZone(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	window := newSyncWindow(k8v1alpha1.SyncWindowAllow, "0 8 * * *", 9*time.Hour)
+	window.TimeZone = "America/New_York"
+
+	// 10:00 in New York
+	allowed, _, err := checkSyncWindows([]k8v1alpha1.SyncWindow{window}, time.Date(2019, 5, 15, 14, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// 06:00 in New York
+	allowed, _, err = checkSyncWindows([]k8v1alpha1.SyncWindow{window}, time.Date(2019, 5, 15, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestGetSyncRequest(t *testing.T) {
+	obj := &metav1.ObjectMeta{}
+	request, forced := getSyncRequest(obj, "")
+	assert.Empty(t, request)
+	assert.False(t, forced)
+
+	obj.Annotations = map[string]string{k8v1alpha1.SyncAnnotation: "1557939600"}
+	request, forced = getSyncRequest(obj, "")
+	assert.Equal(t, "1557939600", request)
+	assert.False(t, forced)
+
+	request, _ = getSyncRequest(obj, "1557939600")
+	assert.Empty(t, request, "handled requests should be ignored")
+
+	obj.Annotations[k8v1alpha1.SyncAnnotation] = "force-1557939600"
+	request, forced = getSyncRequest(obj, "1557939600")
+	assert.Equal(t, "force-1557939600", request)
+	assert.True(t, forced)
+}
+
+func TestValidateSchedule(t *testing.T) {
+	spec := &k8v1alpha1.CloudConfigSpec{
+		Schedule: "0 8 * * MON-FRI",
+		SyncWindows: []k8v1alpha1.SyncWindow{
+			newSyncWindow(k8v1alpha1.SyncWindowDeny, "0 17 * * FRI", 63*time.Hour),
+		},
+	}
+	assert.Empty(t, validateSchedule(spec))
+
+	spec.Period = 10
+	assert.Len(t, validateSchedule(spec), 1, "schedule and period should be exclusive")
+
+	spec = &k8v1alpha1.CloudConfigSpec{
+		Schedule: "0 8 * *",
+		SyncWindows: []k8v1alpha1.SyncWindow{
+			{Kind: "maybe", Schedule: "0 8 * * *", Duration: metav1.Duration{Duration: time.Hour}},
+			{Kind: k8v1alpha1.SyncWindowAllow, Schedule: "0 8 * * *"},
+			{Kind: k8v1alpha1.SyncWindowAllow, Schedule: "0 8 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Mars/Olympus"},
+			{Kind: k8v1alpha1.SyncWindowAllow, Schedule: "0 25 * * *", Duration: metav1.Duration{Duration: time.Hour}},
+		},
+	}
+	errs := validateSchedule(spec)
+	assert.Len(t, errs, 5)
+	assert.Equal(t, "schedule", errs[0].Field)
+	assert.Equal(t, "syncWindows[0].kind", errs[1].Field)
+	assert.Equal(t, "syncWindows[1].duration", errs[2].Field)
+	assert.Equal(t, "syncWindows[2].timeZone", errs[3].Field)
+	assert.Equal(t, "syncWindows[3].schedule", errs[4].Field)
+}
@@ -0,0 +1,290 @@
+package cloudconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// openAPISchemaEnvVar is the environment variable naming an OpenAPI v2 schema file used for validating the
+// objects of the apps instead of the schema of the API server, e.g. in air gapped clusters
+const openAPISchemaEnvVar = "OPENAPI_SCHEMA"
+
+// schemaCacheTTL is the time the OpenAPI schema of the API server is cached
+const schemaCacheTTL = 10 * time.Minute
+
+// schemaProperty is an OpenAPI v2 schema of a definition or a property of a definition
+type schemaProperty struct {
+	Ref                   string                     `json:"$ref,omitempty"`
+	Type                  string                     `json:"type,omitempty"`
+	Format                string                     `json:"format,omitempty"`
+	Properties            map[string]*schemaProperty `json:"properties,omitempty"`
+	Items                 *schemaProperty            `json:"items,omitempty"`
+	AdditionalProperties  *additionalProperties      `json:"additionalProperties,omitempty"`
+	PreserveUnknownFields bool                       `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	GroupVersionKinds     []schema.GroupVersionKind  `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// additionalProperties is either a boolean allowing any additional properties or the schema of their values
+type additionalProperties struct {
+	allowed bool
+	schema  *schemaProperty
+}
+
+// UnmarshalJSON unmarshals a boolean or a schema
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+// openAPISchema holds the definitions of an OpenAPI v2 schema indexed by the group, version and kind they define
+type openAPISchema struct {
+	definitions map[string]*schemaProperty
+	kinds       map[schema.GroupVersionKind]*schemaProperty
+}
+
+// parseOpenAPISchema parses an OpenAPI v2 schema as served by the API server at `/openapi/v2`
+func parseOpenAPISchema(data []byte) (*openAPISchema, error) {
+	doc := struct {
+		Definitions map[string]*schemaProperty `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse the OpenAPI schema: %s", err.Error())
+	}
+
+	s := &openAPISchema{definitions: doc.Definitions, kinds: make(map[schema.GroupVersionKind]*schemaProperty)}
+	for _, definition := range doc.Definitions {
+		for _, gvk := range definition.GroupVersionKinds {
+			s.kinds[gvk] = definition
+		}
+	}
+	return s, nil
+}
+
+// schemaCache caches the OpenAPI schema used for validating the objects of the apps
+var schemaCache = struct {
+	sync.Mutex
+	schema *openAPISchema
+	loaded time.Time
+}{}
+
+// getOpenAPISchema returns the OpenAPI schema of the file named by the OPENAPI_SCHEMA environment variable or
+// the cached schema of the API server, the schema of the API server is retrieved again when the cache expires
+func getOpenAPISchema() (*openAPISchema, error) {
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+	file := os.Getenv(openAPISchemaEnvVar)
+	if schemaCache.schema != nil && (file != "" || time.Since(schemaCache.loaded) < schemaCacheTTL) {
+		return schemaCache.schema, nil
+	}
+
+	var data []byte
+	var err error
+	if file != "" {
+		data, err = ioutil.ReadFile(file)
+	} else {
+		data, err = getServerSchema()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseOpenAPISchema(data)
+	if err != nil {
+		return nil, err
+	}
+	schemaCache.schema, schemaCache.loaded = s, time.Now()
+	return s, nil
+}
+
+// getServerSchema retrieves the OpenAPI v2 schema of the API server
+func getServerSchema() ([]byte, error) {
+	cmd := execCommand("kubectl", "get", "--raw", "/openapi/v2")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve the OpenAPI schema: %s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// validateSchema validates the objects of the manifest against the OpenAPI schema of the API server. Objects of
+// kinds not found in the schema are not validated. The objects are applied without validation if the schema
+// cannot be retrieved.
+func validateSchema(app string, m manifest) error {
+	s, err := getOpenAPISchema()
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Could not validate the objects of app '%s'", app))
+		return nil
+	}
+	if problems := s.validateManifest(m); len(problems) > 0 {
+		return fmt.Errorf("objects of app '%s' do not match the OpenAPI schema: %s", app, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateManifest returns the problems of the objects of the manifest, each naming the object and field
+func (s *openAPISchema) validateManifest(m manifest) []string {
+	var problems []string
+	for _, obj := range m {
+		definition, found := s.kinds[obj.GroupVersionKind()]
+		if !found {
+			continue
+		}
+		for _, problem := range s.validate(obj.Object, definition, "") {
+			problems = append(problems, fmt.Sprintf("%s '%s': %s", obj.GetKind(), obj.GetName(), problem))
+		}
+	}
+	return problems
+}
+
+// validate returns the problems of the value of the field at the path, e.g. unknown fields and wrong types
+func (s *openAPISchema) validate(value interface{}, property *schemaProperty, path string) []string {
+	if property.Ref != "" {
+		name := strings.TrimPrefix(property.Ref, "#/definitions/")
+		if isIntOrString(name) {
+			return checkType(value, path, "string", "integer", "number")
+		}
+		definition, found := s.definitions[name]
+		if !found {
+			return nil
+		}
+		property = definition
+	}
+	if value == nil || property.PreserveUnknownFields {
+		return nil
+	}
+	if property.Format == "int-or-string" {
+		return checkType(value, path, "string", "integer", "number")
+	}
+
+	switch property.Type {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return checkType(value, path, "array")
+		}
+		if property.Items == nil {
+			return nil
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, s.validate(item, property.Items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "string", "integer", "number", "boolean":
+		return checkType(value, path, property.Type)
+	case "object", "":
+		if property.Type == "" && len(property.Properties) == 0 {
+			return nil
+		}
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return checkType(value, path, "object")
+		}
+		return s.validateFields(fields, property, path)
+	}
+	return nil
+}
+
+// validateFields returns the problems of the fields of an object
+func (s *openAPISchema) validateFields(fields map[string]interface{}, property *schemaProperty, path string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	additional := property.AdditionalProperties
+	for _, name := range names {
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		switch field, found := property.Properties[name]; {
+		case found:
+			problems = append(problems, s.validate(fields[name], field, fieldPath)...)
+		case additional != nil && additional.schema != nil:
+			problems = append(problems, s.validate(fields[name], additional.schema, fieldPath)...)
+		case additional != nil && additional.allowed, len(property.Properties) == 0:
+			// any field is allowed
+		default:
+			problems = append(problems, fmt.Sprintf("unknown field '%s'", fieldPath))
+		}
+	}
+	return problems
+}
+
+// isIntOrString returns true if the definition accepts both strings and numbers
+func isIntOrString(definition string) bool {
+	return strings.HasSuffix(definition, ".util.intstr.IntOrString") || strings.HasSuffix(definition, ".api.resource.Quantity")
+}
+
+// checkType returns a problem if the value is not of any of the types
+func checkType(value interface{}, path string, types ...string) []string {
+	actual := getValueType(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("field '%s' must be %s, found %s", path, strings.Join(types, " or "), actual)}
+}
+
+// getValueType returns the OpenAPI type of the value parsed from JSON
+func getValueType(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// ValidateSpec validates the rendered spec files of an app offline against an OpenAPI v2 schema file, e.g. in
+// CI pipelines, or against the schema of the Kubernetes API types the operator is built with if the schema file
+// is nil. The documents are validated as by the operator before they are applied; the result lists the problems
+// found.
+func ValidateSpec(schemaFile, spec []byte) ([]string, error) {
+	s := getBuiltinSchema()
+	if schemaFile != nil {
+		var err error
+		if s, err = parseOpenAPISchema(schemaFile); err != nil {
+			return nil, err
+		}
+	}
+	problems := validateSpecFile(spec)
+	if len(problems) > 0 {
+		return problems, nil
+	}
+	m, err := parseManifest(unescapePlaceholders(spec))
+	if err != nil {
+		return nil, err
+	}
+	return s.validateManifest(m), nil
+}
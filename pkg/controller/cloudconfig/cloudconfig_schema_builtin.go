@@ -0,0 +1,117 @@
+package cloudconfig
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8scheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// builtinSchema is the OpenAPI schema of the Kubernetes API types the operator is built with
+var builtinSchema struct {
+	sync.Once
+	schema *openAPISchema
+}
+
+// getBuiltinSchema returns the OpenAPI schema of the Kubernetes API types the operator is built with, used for
+// validating spec files offline without a schema file. Custom resources are not part of the schema.
+func getBuiltinSchema() *openAPISchema {
+	builtinSchema.Do(func() {
+		builtinSchema.schema = newTypeSchema(k8scheme.Scheme)
+	})
+	return builtinSchema.schema
+}
+
+// newTypeSchema returns an OpenAPI schema of the external types of the scheme derived from their Go types and
+// JSON tags
+func newTypeSchema(s *runtime.Scheme) *openAPISchema {
+	types := &openAPISchema{
+		definitions: make(map[string]*schemaProperty),
+		kinds:       make(map[schema.GroupVersionKind]*schemaProperty),
+	}
+	for gvk, t := range s.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal {
+			continue
+		}
+		types.kinds[gvk] = types.definitions[types.define(t)]
+	}
+	return types
+}
+
+// define adds the definition of the named struct type to the schema, if not already defined, and returns its
+// name, e.g. `k8s.io.api.apps.v1.Deployment`
+func (s *openAPISchema) define(t reflect.Type) string {
+	name := strings.Replace(t.PkgPath(), "/", ".", -1) + "." + t.Name()
+	if _, found := s.definitions[name]; found {
+		return name
+	}
+	definition := &schemaProperty{Type: "object", Properties: make(map[string]*schemaProperty)}
+	// add the definition before its fields to support recursive types
+	s.definitions[name] = definition
+	s.addFields(definition, t)
+	return name
+}
+
+// addFields adds the JSON fields of the struct type to the properties of the definition, the fields of inlined
+// structs included
+func (s *openAPISchema) addFields(definition *schemaProperty, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch {
+		case name == "-" || f.PkgPath != "":
+			continue
+		case name == "" && f.Anonymous:
+			s.addFields(definition, f.Type)
+		case name != "":
+			definition.Properties[name] = s.getProperty(f.Type)
+		}
+	}
+}
+
+// jsonMarshaler is the type of values with a custom JSON representation
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// getProperty returns the schema of a field of the type
+func (s *openAPISchema) getProperty(t reflect.Type) *schemaProperty {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := strings.Replace(t.PkgPath(), "/", ".", -1) + "." + t.Name()
+	if isIntOrString(name) {
+		return &schemaProperty{Ref: "#/definitions/" + name}
+	}
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+		// e.g. times and raw extensions, any value is accepted
+		return &schemaProperty{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return &schemaProperty{Ref: "#/definitions/" + s.define(t)}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schemaProperty{Type: "string", Format: "byte"}
+		}
+		return &schemaProperty{Type: "array", Items: s.getProperty(t.Elem())}
+	case reflect.Map:
+		return &schemaProperty{Type: "object", AdditionalProperties: &additionalProperties{
+			allowed: true,
+			schema:  s.getProperty(t.Elem()),
+		}}
+	case reflect.String:
+		return &schemaProperty{Type: "string"}
+	case reflect.Bool:
+		return &schemaProperty{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schemaProperty{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schemaProperty{Type: "number"}
+	}
+	return &schemaProperty{}
+}
@@ -0,0 +1,147 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testOpenAPISchema = `{
+  "swagger": "2.0",
+  "definitions": {
+    "io.k8s.api.apps.v1.Deployment": {
+      "type": "object",
+      "properties": {
+        "apiVersion": { "type": "string" },
+        "kind": { "type": "string" },
+        "metadata": { "$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta" },
+        "spec": { "$ref": "#/definitions/io.k8s.api.apps.v1.DeploymentSpec" }
+      },
+      "x-kubernetes-group-version-kind": [ { "group": "apps", "kind": "Deployment", "version": "v1" } ]
+    },
+    "io.k8s.api.apps.v1.DeploymentSpec": {
+      "type": "object",
+      "properties": {
+        "replicas": { "type": "integer", "format": "int32" },
+        "paused": { "type": "boolean" },
+        "template": {
+          "type": "object",
+          "properties": {
+            "spec": {
+              "type": "object",
+              "properties": {
+                "containers": { "type": "array", "items": { "$ref": "#/definitions/io.k8s.api.core.v1.Container" } }
+              }
+            }
+          }
+        }
+      }
+    },
+    "io.k8s.api.core.v1.Container": {
+      "type": "object",
+      "properties": {
+        "name": { "type": "string" },
+        "image": { "type": "string" },
+        "resources": {
+          "type": "object",
+          "properties": {
+            "limits": { "type": "object", "additionalProperties": { "$ref": "#/definitions/io.k8s.apimachinery.pkg.api.resource.Quantity" } }
+          }
+        },
+        "ports": {
+          "type": "array",
+          "items": { "type": "object", "properties": { "containerPort": { "type": "integer" }, "targetPort": { "format": "int-or-string", "type": "string" } } }
+        }
+      }
+    },
+    "io.k8s.apimachinery.pkg.api.resource.Quantity": { "type": "string" },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": { "type": "string" },
+        "labels": { "type": "object", "additionalProperties": { "type": "string" } },
+        "annotations": { "type": "object", "additionalProperties": true }
+      }
+    }
+  }
+}`
+
+const testSchemaDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+  labels:
+    app: alpha
+    replicas: 3
+spec:
+  replicas: "3"
+  pause: true
+  template:
+    spec:
+      containers:
+      - name: alpha
+        image: nginx
+        resources:
+          limits: { cpu: 1, memory: 128Mi }
+        ports:
+        - { containerPort: 8080, targetPort: http }
+        - { containerPort: 8.5, targetPort: 8080, protocol: TCP }
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: unknown
+spec:
+  anything: goes
+`
+
+func TestValidateManifest(t *testing.T) {
+	s, err := parseOpenAPISchema([]byte(testOpenAPISchema))
+	assert.NoError(t, err)
+	m, _ := parseManifest([]byte(testSchemaDeployment))
+
+	assert.Equal(t, []string{
+		"Deployment 'alpha': field 'metadata.labels.replicas' must be string, found integer",
+		"Deployment 'alpha': unknown field 'spec.pause'",
+		"Deployment 'alpha': field 'spec.replicas' must be integer, found string",
+		"Deployment 'alpha': field 'spec.template.spec.containers[0].ports[1].containerPort' must be integer, found number",
+		"Deployment 'alpha': unknown field 'spec.template.spec.containers[0].ports[1].protocol'",
+	}, s.validateManifest(m), "objects of kinds not in the schema should not be validated")
+
+	_, err = parseOpenAPISchema([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestValidateSpec(t *testing.T) {
+	problems, err := ValidateSpec([]byte(testOpenAPISchema), []byte(testWaveManifest))
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	problems, err = ValidateSpec([]byte(testOpenAPISchema), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: alpha\nspec:\n  replica: 1\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Deployment 'alpha': unknown field 'spec.replica'"}, problems)
+
+	problems, err = ValidateSpec([]byte(testOpenAPISchema), []byte("kind: Deployment\nmetadata:\n  name: ${app}\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"document 1 (line 1): unresolved placeholder '${app}' at line 3",
+		"document 1 (line 1): missing apiVersion",
+	}, problems, "spec file problems should be reported before schema validation")
+}
+
+func TestGetBuiltinSchema(t *testing.T) {
+	m, _ := parseManifest([]byte(testSchemaDeployment))
+	assert.Equal(t, []string{
+		"Deployment 'alpha': field 'metadata.labels.replicas' must be string, found integer",
+		"Deployment 'alpha': unknown field 'spec.pause'",
+		"Deployment 'alpha': field 'spec.replicas' must be integer, found string",
+		"Deployment 'alpha': unknown field 'spec.template.spec.containers[0].ports[0].targetPort'",
+		"Deployment 'alpha': field 'spec.template.spec.containers[0].ports[1].containerPort' must be integer, found number",
+		"Deployment 'alpha': unknown field 'spec.template.spec.containers[0].ports[1].targetPort'",
+	}, getBuiltinSchema().validateManifest(m), "quantities should accept numbers and strings")
+
+	problems, err := ValidateSpec(nil, []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: alpha\nspec:\n  replica: 1\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Deployment 'alpha': unknown field 'spec.replica'"}, problems,
+		"the builtin schema should be used without a schema file")
+}
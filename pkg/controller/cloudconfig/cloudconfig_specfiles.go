@@ -0,0 +1,152 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+)
+
+// optionalPrefix marks spec files that may be missing, cf. Spring Boot's `optional:` config imports
+const optionalPrefix = "optional:"
+
+// specFile is a spec file name or pattern found in the SpecFiles of a CloudConfig
+type specFile struct {
+	name     string
+	optional bool
+}
+
+func parseSpecFile(entry string) specFile {
+	entry = strings.TrimSpace(entry)
+	if strings.HasPrefix(entry, optionalPrefix) {
+		return specFile{name: strings.TrimPrefix(entry, optionalPrefix), optional: true}
+	}
+	return specFile{name: entry}
+}
+
+func (f specFile) isPattern() bool {
+	return strings.ContainsAny(f.name, "*?[")
+}
+
+// hasSpecFilePatterns returns true if one or more of the spec file entries is a glob pattern
+func hasSpecFilePatterns(entries []string) bool {
+	for _, entry := range entries {
+		if parseSpecFile(entry).isPattern() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSpecFiles resolves the spec file entries into a list of spec files. Plain file names are
+// kept in the order listed whereas the files matching a pattern are listed in alphabetical order.
+// Files resolved more than once are only retained the first time they occur.
+func resolveSpecFiles(entries []string, manifest []string) ([]specFile, error) {
+	files := make([]specFile, 0, len(entries))
+	resolved := make(map[string]bool, len(entries))
+
+	add := func(f specFile) {
+		if !resolved[f.name] {
+			resolved[f.name] = true
+			files = append(files, f)
+		}
+	}
+
+	for _, entry := range entries {
+		f := parseSpecFile(entry)
+		if f.name == "" {
+			continue
+		}
+		if !f.isPattern() {
+			add(f)
+			continue
+		}
+
+		matches, err := matchSpecFiles(f.name, manifest)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && !f.optional {
+			return nil, fmt.Errorf("spec file pattern '%s' does not match any file in the spec manifest %v", f.name, manifest)
+		}
+		for _, name := range matches {
+			add(specFile{name: name, optional: f.optional})
+		}
+	}
+
+	return files, nil
+}
+
+// matchSpecFiles returns the manifest files matching the pattern in alphabetical order
+func matchSpecFiles(pattern string, manifest []string) ([]string, error) {
+	matches := make([]string, 0, len(manifest))
+	for _, name := range manifest {
+		match, err := path.Match(pattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec file pattern '%s': %s", pattern, err.Error())
+		}
+		if match {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// getAppSpec retrieves, validates and concatenates all spec files for the app. All problems found in the spec
+// files are reported in a single error naming the missing properties of unresolved placeholders, if any. Escaped
+// `$\{...}` placeholders are applied as `${...}`.
+func getAppSpec(client *CloudConfigClient, spec *k8v1alpha1.CloudConfigSpec, app string) ([]byte, error) {
+	entries := spec.GetSpecFiles()
+
+	var manifest []string
+	if hasSpecFilePatterns(entries) {
+		var err error
+		manifest, err = client.getStringList(spec.SpecManifest, app, spec.Label, spec.Profile...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := resolveSpecFiles(entries, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve spec files for app '%s': %s", app, err.Error())
+	}
+
+	appSpec := make([]byte, 0, 1024)
+	var problems, missing []string
+	unique := make(map[string]bool)
+	for _, f := range files {
+		file, err := client.GetConfigFile(f.name, app, spec.Label, spec.Profile...)
+		if err != nil {
+			if f.optional && IsNotFound(err) {
+				log.Info(fmt.Sprintf("Skipping optional spec file '%s' not found for app '%s'", f.name, app))
+				continue
+			}
+			return nil, err
+		}
+		// validate each file before concatenating so that problems are reported for the file where they occur
+		for _, problem := range validateSpecFile(file) {
+			problems = append(problems, fmt.Sprintf("spec file '%s' %s", f.name, problem))
+		}
+		for _, name := range getMissingProperties(file) {
+			if !unique[name] {
+				unique[name] = true
+				missing = append(missing, name)
+			}
+		}
+		appSpec = appendYAMLDoc(app, appSpec, unescapePlaceholders(file))
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("unresolved placeholders in spec files for app '%s', missing properties %s: %s",
+			app, strings.Join(missing, ", "), strings.Join(problems, "; "))
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid spec files for app '%s': %s", app, strings.Join(problems, "; "))
+	}
+	return appSpec, nil
+}
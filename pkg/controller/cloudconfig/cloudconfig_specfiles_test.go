@@ -0,0 +1,112 @@
+package cloudconfig
+
+import (
+	"strings"
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
+)
+
+func TestParseSpecFile(t *testing.T) {
+	assert.Equal(t, specFile{name: "deployment.yaml"}, parseSpecFile("deployment.yaml"))
+	assert.Equal(t, specFile{name: "hpa.yaml", optional: true}, parseSpecFile("optional:hpa.yaml"))
+	assert.True(t, parseSpecFile("optional:*.yaml").isPattern())
+	assert.False(t, parseSpecFile("service.yaml").isPattern())
+}
+
+func TestResolveSpecFiles(t *testing.T) {
+	manifest := []string{"service.yaml", "ingress.yaml", "deployment.yaml", "README.md"}
+
+	files, err := resolveSpecFiles([]string{"deployment.yaml", "*.yaml"}, manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []specFile{
+		{name: "deployment.yaml"},
+		{name: "ingress.yaml"},
+		{name: "service.yaml"},
+	}, files, "plain files should come first and pattern matches in alphabetical order")
+
+	files, err = resolveSpecFiles([]string{"*.json"}, manifest)
+	assert.Error(t, err, "required patterns must match at least one file")
+	assert.Nil(t, files)
+
+	files, err = resolveSpecFiles([]string{"deployment.yaml", "optional:*.json"}, manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []specFile{{name: "deployment.yaml"}}, files)
+
+	files, err = resolveSpecFiles([]string{"[.yaml"}, manifest)
+	assert.Error(t, err, "invalid patterns should be reported")
+	assert.Nil(t, files)
+}
+
+const testDeployment = "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app"
+const testService = "apiVersion: v1\nkind: Service\nmetadata:\n  name: app"
+
+func TestGetAppSpec(t *testing.T) {
+	mockHTTPClientFactory()
+	defer restoreDefaultHTTPClientFactory()
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"label/app-p1.json", httpmock.NewStringResponder(
+			200, `{"kubernetes": {"files": [ "service.yaml", "deployment.yaml" ]}}`))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/deployment.yaml", httpmock.NewStringResponder(200, testDeployment))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/service.yaml", httpmock.NewStringResponder(200, testService))
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/hpa.yaml", httpmock.NewStringResponder(404, ""))
+
+	client, _ := New(TestBaseURL)
+	spec := &k8v1alpha1.CloudConfigSpec{
+		Label:        "label",
+		Profile:      []string{"p1"},
+		SpecFiles:    []string{"*.yaml", "optional:hpa.yaml"},
+		SpecManifest: "kubernetes.files",
+	}
+
+	appSpec, err := getAppSpec(client, spec, "app")
+	assert.NoError(t, err)
+	assert.Equal(t, "---\n"+testDeployment+"\n---\n"+testService+"\n", string(appSpec))
+
+	spec.SpecFiles = []string{"deployment.yaml", "hpa.yaml"}
+	appSpec, err = getAppSpec(client, spec, "app")
+	assert.Error(t, err, "missing spec files that are not optional should result in an error")
+	assert.Nil(t, appSpec)
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/service.yaml", httpmock.NewStringResponder(200, "kind: Service"))
+	spec.SpecFiles = []string{"deployment.yaml", "service.yaml"}
+	appSpec, err = getAppSpec(client, spec, "app")
+	assert.EqualError(t, err, "invalid spec files for app 'app': spec file 'service.yaml' document 1 (line 1): missing apiVersion, metadata.name")
+	assert.Nil(t, appSpec)
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/service.yaml", httpmock.NewStringResponder(
+			200, testService+"\n  labels:\n    version: ${app.version}\n    home: $\\{HOME}"))
+	appSpec, err = getAppSpec(client, spec, "app")
+	assert.EqualError(t, err, "unresolved placeholders in spec files for app 'app', missing properties app.version: "+
+		"spec file 'service.yaml' document 1 (line 1): unresolved placeholder '${app.version}' at line 6")
+	assert.Nil(t, appSpec)
+
+	// the config server resolves the placeholders of defined properties but leaves escaped placeholders as is
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"app/p1/label/service.yaml", httpmock.NewStringResponder(
+			200, resolvePlaceholders(testService+"\n  labels:\n    version: ${app.version}\n    home: \"$\\{HOME}\"",
+				map[string]string{"app.version": "1.0", "HOME": "/root"})))
+	appSpec, err = getAppSpec(client, spec, "app")
+	assert.NoError(t, err)
+	assert.Contains(t, string(appSpec), "version: 1.0\n")
+	assert.Contains(t, string(appSpec), "home: \"${HOME}\"\n",
+		"escaped placeholders should be applied unescaped, also if the property is defined")
+}
+
+// resolvePlaceholders resolves the `${...}` placeholders of the defined properties as the config server does
+func resolvePlaceholders(text string, properties map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if value, ok := properties[getPropertyName(placeholder)]; ok && strings.HasPrefix(placeholder, "${") {
+			return value
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,163 @@
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// suspendedBySpec is the reason of a suspension by the suspend field of the spec
+	suspendedBySpec = "SuspendedBySpec"
+	// suspendedByAnnotation is the reason of a suspension by the suspend annotation
+	suspendedByAnnotation = "SuspendedByAnnotation"
+	// suspendedByOwner is the reason of a suspension by a suspended owner
+	suspendedByOwner = "SuspendedByOwner"
+)
+
+// getSuspendReason returns the reason why synchronization of the object is suspended, or an empty string if
+// the object is not suspended. The suspend annotation takes precedence over the spec so that objects can be
+// suspended in an emergency without changing their spec.
+func getSuspendReason(obj metav1.Object, suspend bool) string {
+	annotations := obj.GetAnnotations()
+	switch {
+	case annotations[k8v1alpha1.SuspendAnnotation] == "true":
+		return suspendedByAnnotation
+	case suspend:
+		return suspendedBySpec
+	case annotations[k8v1alpha1.SuspendedByAnnotation] != "":
+		return suspendedByOwner
+	}
+	return ""
+}
+
+// controlAnnotationsChanged returns true if the suspend, sync or approve annotations of the objects differ
+func controlAnnotationsChanged(old, new metav1.Object) bool {
+	for _, a := range []string{
+		k8v1alpha1.SuspendAnnotation,
+		k8v1alpha1.SuspendedByAnnotation,
+		k8v1alpha1.SyncAnnotation,
+		k8v1alpha1.ApproveAnnotation,
+	} {
+		if old.GetAnnotations()[a] != new.GetAnnotations()[a] {
+			return true
+		}
+	}
+	return false
+}
+
+// recordSuspension sets the Suspended condition of the CloudConfig or ClusterCloudConfig and records a Suspended or
+// Resumed event if the status of the condition changed
+func recordSuspension(recorder record.EventRecorder, obj runtime.Object, conditions *[]k8v1alpha1.Condition, reason string) {
+	if !setSuspendedCondition(conditions, reason) {
+		return
+	}
+	if reason != "" {
+		recorder.Event(obj, corev1.EventTypeNormal, "Suspended", "Synchronization suspended, reason: "+reason)
+	} else {
+		recorder.Event(obj, corev1.EventTypeNormal, "Resumed", "Synchronization resumed")
+	}
+}
+
+// setSuspendedCondition sets the Suspended condition to True with the reason if the CloudConfig is suspended or to
+// False if it was previously suspended. The result is true if the status of the condition changed.
+func setSuspendedCondition(conditions *[]k8v1alpha1.Condition, reason string) bool {
+	previous := k8v1alpha1.IsConditionTrue(*conditions, k8v1alpha1.ConditionSuspended)
+	if reason != "" {
+		k8v1alpha1.SetCondition(conditions, k8v1alpha1.Condition{
+			Type:    k8v1alpha1.ConditionSuspended,
+			Status:  corev1.ConditionTrue,
+			Reason:  reason,
+			Message: "Synchronization is suspended",
+		})
+		return !previous
+	}
+
+	if k8v1alpha1.GetCondition(*conditions, k8v1alpha1.ConditionSuspended) == nil {
+		return false
+	}
+	k8v1alpha1.SetCondition(conditions, k8v1alpha1.Condition{
+		Type:   k8v1alpha1.ConditionSuspended,
+		Status: corev1.ConditionFalse,
+		Reason: "Resumed",
+	})
+	return previous
+}
+
+// getSuspendedBy returns the value of the suspended-by annotation identifying the owner
+func getSuspendedBy(kind string, owner metav1.Object) string {
+	if owner.GetNamespace() == "" {
+		return kind + "/" + owner.GetName()
+	}
+	return kind + "/" + owner.GetNamespace() + "/" + owner.GetName()
+}
+
+// setAnnotation sets the annotation of the object or removes it if the value is empty. The object is only updated
+// if the annotation changes.
+func setAnnotation(k8client client.Client, obj appOwner, key, value string) error {
+	annotations := obj.GetAnnotations()
+	if annotations[key] == value {
+		return nil
+	}
+
+	if value == "" {
+		delete(annotations, key)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string, 1)
+		}
+		annotations[key] = value
+	}
+	obj.SetAnnotations(annotations)
+
+	log.Info(fmt.Sprintf("Setting annotation '%s' of '%s' to '%s'", key, obj.GetName(), value), "Namespace", obj.GetNamespace())
+	if err := k8client.Update(context.TODO(), obj); err != nil && !k8errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// annotateApps sets the annotation of the CloudConfigApps controlled by the owner or removes it if the value is
+// empty
+func annotateApps(k8client client.Client, owner metav1.Object, labels map[string]string, key, value string) error {
+	apps := &k8v1alpha1.CloudConfigAppList{}
+	opts := client.InNamespace(owner.GetNamespace()).MatchingLabels(labels)
+	if err := k8client.List(context.TODO(), opts, apps); err != nil {
+		return err
+	}
+
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if !metav1.IsControlledBy(app, owner) {
+			continue
+		}
+		if err := setAnnotation(k8client, app, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// annotateEnvs sets the annotation of the CloudConfigEnvs of the CloudConfig in all namespaces or removes it if the
+// value is empty
+func (r *ReconcileCloudConfig) annotateEnvs(c *k8v1alpha1.CloudConfig, key, value string) error {
+	envs := &k8v1alpha1.CloudConfigEnvList{}
+	opts := (&client.ListOptions{}).MatchingLabels(newCloudConfigLabels(c))
+	if err := r.client.List(context.TODO(), opts, envs); err != nil {
+		return err
+	}
+
+	for i := range envs.Items {
+		if err := setAnnotation(r.client, &envs.Items[i], key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
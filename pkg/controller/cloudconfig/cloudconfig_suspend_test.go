@@ -0,0 +1,70 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSuspendReason(t *testing.T) {
+	obj := &metav1.ObjectMeta{Name: "test"}
+	assert.Empty(t, getSuspendReason(obj, false))
+	assert.Equal(t, suspendedBySpec, getSuspendReason(obj, true))
+
+	obj.Annotations = map[string]string{k8v1alpha1.SuspendAnnotation: "false"}
+	assert.Empty(t, getSuspendReason(obj, false), "only 'true' should suspend")
+
+	obj.Annotations[k8v1alpha1.SuspendedByAnnotation] = "CloudConfig/default/test"
+	assert.Equal(t, suspendedByOwner, getSuspendReason(obj, false))
+	assert.Equal(t, suspendedBySpec, getSuspendReason(obj, true))
+
+	obj.Annotations[k8v1alpha1.SuspendAnnotation] = "true"
+	assert.Equal(t, suspendedByAnnotation, getSuspendReason(obj, true))
+}
+
+func TestControlAnnotationsChanged(t *testing.T) {
+	old := &metav1.ObjectMeta{Annotations: map[string]string{"other": "a"}}
+	new := &metav1.ObjectMeta{Annotations: map[string]string{"other": "b"}}
+	assert.False(t, controlAnnotationsChanged(old, new))
+
+	new.Annotations[k8v1alpha1.SuspendAnnotation] = "true"
+	assert.True(t, controlAnnotationsChanged(old, new))
+
+	old.Annotations[k8v1alpha1.SuspendAnnotation] = "true"
+	old.Annotations[k8v1alpha1.SuspendedByAnnotation] = "CloudConfig/default/test"
+	assert.True(t, controlAnnotationsChanged(old, new))
+
+	new.Annotations[k8v1alpha1.SuspendedByAnnotation] = "CloudConfig/default/test"
+	new.Annotations[k8v1alpha1.SyncAnnotation] = "1"
+	assert.True(t, controlAnnotationsChanged(old, new))
+
+	old.Annotations[k8v1alpha1.SyncAnnotation] = "1"
+	new.Annotations[k8v1alpha1.ApproveAnnotation] = "0123456789ab"
+	assert.True(t, controlAnnotationsChanged(old, new))
+}
+
+func TestSetSuspendedCondition(t *testing.T) {
+	status := k8v1alpha1.CloudConfigStatus{}
+	assert.False(t, setSuspendedCondition(&status.Conditions, ""))
+	assert.Empty(t, status.Conditions, "the condition should not be added to CloudConfigs that were never suspended")
+
+	assert.True(t, setSuspendedCondition(&status.Conditions, suspendedByAnnotation))
+	suspended := k8v1alpha1.GetCondition(status.Conditions, k8v1alpha1.ConditionSuspended)
+	assert.Equal(t, corev1.ConditionTrue, suspended.Status)
+	assert.Equal(t, suspendedByAnnotation, suspended.Reason)
+
+	assert.False(t, setSuspendedCondition(&status.Conditions, suspendedBySpec), "the condition status did not change")
+	assert.Equal(t, suspendedBySpec, k8v1alpha1.GetCondition(status.Conditions, k8v1alpha1.ConditionSuspended).Reason)
+
+	assert.True(t, setSuspendedCondition(&status.Conditions, ""))
+	assert.False(t, k8v1alpha1.IsConditionTrue(status.Conditions, k8v1alpha1.ConditionSuspended))
+	assert.False(t, setSuspendedCondition(&status.Conditions, ""))
+}
+
+func TestGetSuspendedBy(t *testing.T) {
+	assert.Equal(t, "CloudConfig/default/test", getSuspendedBy("CloudConfig", &metav1.ObjectMeta{Name: "test", Namespace: "default"}))
+	assert.Equal(t, "ClusterCloudConfig/test", getSuspendedBy("ClusterCloudConfig", &metav1.ObjectMeta{Name: "test"}))
+}
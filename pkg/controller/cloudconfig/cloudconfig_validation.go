@@ -0,0 +1,191 @@
+package cloudconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// placeholderPattern matches the `${...}` placeholders of spec files left unresolved by the config server and
+// their `$\{...}` escapes
+var placeholderPattern = regexp.MustCompile(`\$\\?\{([^}]*)\}`)
+
+// escapedPlaceholderPrefix escapes a placeholder that is not resolved by the config server but applied as `${...}`,
+// e.g. a shell variable. The config server leaves it as is, also if a property of the same name is defined.
+const escapedPlaceholderPrefix = `$\{`
+
+// findPlaceholders returns the unresolved placeholders of the line, escaped placeholders are ignored
+func findPlaceholders(line string) []string {
+	var placeholders []string
+	for _, match := range placeholderPattern.FindAllString(line, -1) {
+		if !strings.HasPrefix(match, escapedPlaceholderPrefix) {
+			placeholders = append(placeholders, match)
+		}
+	}
+	return placeholders
+}
+
+// getPropertyName returns the name of the property of the placeholder without its default value, e.g.
+// `container.image` for `${container.image:nginx}`
+func getPropertyName(placeholder string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(placeholder, "${"), "}")
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+// getMissingProperties returns the sorted names of the properties of the unresolved placeholders of the spec file
+func getMissingProperties(file []byte) []string {
+	unique := make(map[string]bool)
+	var names []string
+	for _, doc := range splitDocuments(file) {
+		for _, line := range doc.lines {
+			if isComment(line) {
+				continue
+			}
+			for _, placeholder := range findPlaceholders(line) {
+				if name := getPropertyName(placeholder); !unique[name] {
+					unique[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unescapePlaceholders replaces the escaped `$\{...}` placeholders of the spec file with `${...}`
+func unescapePlaceholders(file []byte) []byte {
+	return bytes.Replace(file, []byte(escapedPlaceholderPrefix), []byte("${"), -1)
+}
+
+// specDocument is a YAML document of a spec file, numbered from 1 and starting at the given line of the file
+type specDocument struct {
+	index int
+	line  int
+	lines []string
+}
+
+// location returns the document number and the line where it starts
+func (doc specDocument) location() string {
+	return fmt.Sprintf("document %d (line %d)", doc.index, doc.line)
+}
+
+// splitDocuments returns the YAML documents of the spec file, documents without content are skipped. Lines
+// starting with `---` separate documents as for the YAML reader parsing the objects of the app.
+func splitDocuments(file []byte) []specDocument {
+	docs := make([]specDocument, 0, 1)
+	current := specDocument{line: 1}
+	add := func() {
+		if hasContent(current.lines) {
+			current.index = len(docs) + 1
+			docs = append(docs, current)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(file)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "---") {
+			add()
+			current = specDocument{line: n + 1}
+			continue
+		}
+		current.lines = append(current.lines, line)
+	}
+	add()
+	return docs
+}
+
+// hasContent returns true if any of the lines is neither blank nor a comment
+func hasContent(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && !isComment(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isComment returns true if the line is a YAML comment
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// validateSpecFile returns the problems of the documents of the spec file: documents must be valid YAML
+// defining a Kubernetes object, or a list of objects, with an apiVersion, kind and metadata.name and must not
+// contain unresolved placeholders, escaped placeholders are allowed. Each problem names the document and line
+// where it was found.
+func validateSpecFile(file []byte) []string {
+	var problems []string
+	for _, doc := range splitDocuments(file) {
+		problems = append(problems, validateDocument(doc)...)
+	}
+	return problems
+}
+
+// validateDocument returns the problems of a document of a spec file
+func validateDocument(doc specDocument) []string {
+	var problems []string
+	for i, line := range doc.lines {
+		if isComment(line) {
+			continue
+		}
+		for _, placeholder := range findPlaceholders(line) {
+			problems = append(problems, fmt.Sprintf("%s: unresolved placeholder '%s' at line %d", doc.location(), placeholder, doc.line+i))
+		}
+	}
+
+	obj := make(map[string]interface{})
+	if err := yaml.Unmarshal(unescapePlaceholders([]byte(strings.Join(doc.lines, "\n"))), &obj); err != nil {
+		return append(problems, fmt.Sprintf("%s: not a valid Kubernetes object: %s", doc.location(), err.Error()))
+	}
+	return append(problems, validateObject(obj, doc.location())...)
+}
+
+// validateObject returns the missing fields of the object at the location, the items of lists are validated
+// instead of the list
+func validateObject(obj map[string]interface{}, location string) []string {
+	items, found := getListItems(obj)
+	if !found {
+		if missing := getMissingFields(obj); len(missing) > 0 {
+			return []string{fmt.Sprintf("%s: missing %s", location, strings.Join(missing, ", "))}
+		}
+		return nil
+	}
+
+	var problems []string
+	for i, item := range items {
+		itemLocation := fmt.Sprintf("%s item %d", location, i)
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: not a valid Kubernetes object", itemLocation))
+			continue
+		}
+		problems = append(problems, validateObject(fields, itemLocation)...)
+	}
+	return problems
+}
+
+// getMissingFields returns the missing apiVersion, kind and metadata.name of the object
+func getMissingFields(obj map[string]interface{}) []string {
+	var missing []string
+	for _, field := range []string{"apiVersion", "kind"} {
+		if value, _ := obj[field].(string); value == "" {
+			missing = append(missing, field)
+		}
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if name, _ := metadata["name"].(string); name == "" {
+		missing = append(missing, "metadata.name")
+	}
+	return missing
+}
@@ -0,0 +1,74 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testInvalidSpecFile = `# leading comment
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+spec:
+  template:
+    spec:
+      containers:
+      - name: alpha
+        # image: ${commented.out}
+        image: ${container.image}
+---
+---
+kind: Service
+metadata:
+  labels:
+    app: ${app}-${env}
+---
+apiVersion: v1
+kind: ConfigMap
+data: [ invalid
+`
+
+func TestSplitDocuments(t *testing.T) {
+	docs := splitDocuments([]byte(testInvalidSpecFile))
+	assert.Len(t, docs, 3, "documents without content should be skipped")
+	assert.Equal(t, []int{1, 2, 3}, []int{docs[0].index, docs[1].index, docs[2].index})
+	assert.Equal(t, []int{3, 16, 21}, []int{docs[0].line, docs[1].line, docs[2].line})
+	assert.Equal(t, "apiVersion: apps/v1", docs[0].lines[0])
+
+	assert.Empty(t, splitDocuments([]byte("---\n# nothing here\n\n")))
+}
+
+func TestValidateSpecFile(t *testing.T) {
+	assert.Empty(t, validateSpecFile([]byte(testWaveManifest)), "valid objects should not have problems")
+
+	assert.Equal(t, []string{
+		"document 1 (line 3): unresolved placeholder '${container.image}' at line 13",
+		"document 2 (line 16): unresolved placeholder '${app}' at line 19",
+		"document 2 (line 16): unresolved placeholder '${env}' at line 19",
+		"document 2 (line 16): missing apiVersion, metadata.name",
+		"document 3 (line 21): not a valid Kubernetes object: error converting YAML to JSON: yaml: line 3: did not find expected ',' or ']'",
+	}, validateSpecFile([]byte(testInvalidSpecFile)))
+}
+
+func TestValidateSpecFileList(t *testing.T) {
+	assert.Empty(t, validateSpecFile([]byte(testListManifest)), "lists should not require a name")
+	assert.Equal(t, []string{
+		"document 1 (line 1) item 1: missing metadata.name",
+		"document 1 (line 1) item 2: not a valid Kubernetes object",
+	}, validateSpecFile([]byte("apiVersion: v1\nkind: List\nitems:\n- { apiVersion: v1, kind: Secret, metadata: { name: alpha } }\n- { apiVersion: v1, kind: Secret }\n- beta\n")))
+}
+
+func TestGetMissingProperties(t *testing.T) {
+	assert.Equal(t, []string{"app", "container.image", "env"}, getMissingProperties([]byte(testInvalidSpecFile)),
+		"commented placeholders should be ignored")
+
+	file := []byte(`args: [ sh, -c, "echo $\{HOME} $(date) ${greeting:hello} ${ name }" ]`)
+	assert.Equal(t, []string{"greeting", "name"}, getMissingProperties(file), "escaped placeholders should be ignored")
+	assert.Equal(t, `args: [ sh, -c, "echo ${HOME} $(date) ${greeting:hello} ${ name }" ]`, string(unescapePlaceholders(file)))
+
+	file = []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: echo\nargs: [ sh, -c, \"echo $\\{HOME}\" ]")
+	assert.Empty(t, validateSpecFile(file), "escaped placeholders should be valid in double quoted strings")
+}
@@ -0,0 +1,153 @@
+package cloudconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// kindOrder lists the kinds in the order they are applied within a sync wave: namespaces and policies, CRDs,
+// RBAC, configuration and storage, services and finally workloads. Objects of other kinds, e.g. custom resources,
+// are applied last.
+var kindOrder = []string{
+	"Namespace",
+	"ResourceQuota",
+	"LimitRange",
+	"PriorityClass",
+	"PodSecurityPolicy",
+	"NetworkPolicy",
+	"CustomResourceDefinition",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"Service",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"StatefulSet",
+	"DaemonSet",
+	"Job",
+	"CronJob",
+	"HorizontalPodAutoscaler",
+	"PodDisruptionBudget",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+var kindRanks = func() map[string]int {
+	ranks := make(map[string]int, len(kindOrder))
+	for i, kind := range kindOrder {
+		ranks[kind] = i
+	}
+	return ranks
+}()
+
+// getKindRank returns the position of the kind in the apply order, unknown kinds are applied last
+func getKindRank(kind string) int {
+	if rank, found := kindRanks[kind]; found {
+		return rank
+	}
+	return len(kindOrder)
+}
+
+// getSyncWave returns the sync wave of the object given by its sync wave annotation, 0 if it is not annotated
+func getSyncWave(obj *unstructured.Unstructured) (int, error) {
+	value, found := obj.GetAnnotations()[k8v1alpha1.SyncWaveAnnotation]
+	if !found {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sync wave '%s' of %s '%s'", value, obj.GetKind(), obj.GetName())
+	}
+	return wave, nil
+}
+
+// sortByWave sorts the objects of the manifest by sync wave and by kind within each wave. Objects of the same
+// wave and kind keep their order in the spec files.
+func (m manifest) sortByWave() error {
+	waves := make(map[*unstructured.Unstructured]int, len(m))
+	for _, obj := range m {
+		wave, err := getSyncWave(obj)
+		if err != nil {
+			return err
+		}
+		waves[obj] = wave
+	}
+	sort.SliceStable(m, func(i, j int) bool {
+		if waves[m[i]] != waves[m[j]] {
+			return waves[m[i]] < waves[m[j]]
+		}
+		return getKindRank(m[i].GetKind()) < getKindRank(m[j].GetKind())
+	})
+	return nil
+}
+
+// syncWave is the wave number and objects of a sync wave
+type syncWave struct {
+	wave    int
+	objects manifest
+}
+
+// getWaves returns the sync waves of a manifest sorted by wave
+func (m manifest) getWaves() []syncWave {
+	waves := make([]syncWave, 0, 1)
+	for _, obj := range m {
+		wave, _ := getSyncWave(obj)
+		if len(waves) == 0 || waves[len(waves)-1].wave != wave {
+			waves = append(waves, syncWave{wave: wave})
+		}
+		waves[len(waves)-1].objects = append(waves[len(waves)-1].objects, obj)
+	}
+	return waves
+}
+
+// getWaveNumbers returns the numbers of the sync waves
+func getWaveNumbers(waves []syncWave) []int {
+	numbers := make([]int, len(waves))
+	for i, wave := range waves {
+		numbers[i] = wave.wave
+	}
+	return numbers
+}
+
+// getSiblingWaveMessage returns a message naming the first sibling app that has objects in waves before the wave
+// that are not yet healthy, empty if the wave may be applied. A sibling waiting for a later wave has healthy
+// objects in all waves before it, other siblings with objects in earlier waves must be healthy. Siblings held
+// back by their dependencies are ignored as they may depend on the app.
+func getSiblingWaveMessage(siblings []k8v1alpha1.CloudConfigApp, wave int) string {
+	for _, sibling := range siblings {
+		status := &sibling.Status
+		if len(status.Waves) == 0 || status.Waves[0] >= wave || status.HeldBack != "" {
+			continue
+		}
+		if status.Wave != nil {
+			if status.Wave.Wave < wave {
+				return fmt.Sprintf("app '%s' is waiting for sync wave %d", sibling.Name, status.Wave.Wave)
+			}
+			continue
+		}
+		if health := getAssessedHealth(status.Health); health != k8v1alpha1.HealthHealthy {
+			return fmt.Sprintf("app '%s' is %s", sibling.Name, health)
+		}
+	}
+	return ""
+}
+
+// newSyncWaveStatus returns the status of the wave of the revision waiting for the reason given by the message
+func newSyncWaveStatus(revision string, wave int, message string) *k8v1alpha1.SyncWaveStatus {
+	return &k8v1alpha1.SyncWaveStatus{Revision: revision, Wave: wave, Message: message}
+}
@@ -0,0 +1,127 @@
+package cloudconfig
+
+import (
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWaveManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: alpha
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    k8s.jabberwocky.se/sync-wave: "-1"
+---
+apiVersion: example.com/v1
+kind: Epsilon
+metadata:
+  name: epsilon
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: beta
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: alpha
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: alpha
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gamma
+  annotations:
+    k8s.jabberwocky.se/sync-wave: "1"
+`
+
+func getNames(m manifest) []string {
+	names := make([]string, len(m))
+	for i, obj := range m {
+		names[i] = obj.GetKind() + "/" + obj.GetName()
+	}
+	return names
+}
+
+func TestSortByWave(t *testing.T) {
+	m, err := parseManifest([]byte(testWaveManifest))
+	assert.NoError(t, err)
+	assert.NoError(t, m.sortByWave())
+	assert.Equal(t, []string{
+		"Job/migrate",
+		"Namespace/test",
+		"ServiceAccount/alpha",
+		"ConfigMap/beta",
+		"ConfigMap/alpha",
+		"Deployment/alpha",
+		"Epsilon/epsilon",
+		"Deployment/gamma",
+	}, getNames(m), "objects should be ordered by wave and kind keeping the order of objects of the same kind")
+
+	waves := m.getWaves()
+	assert.Equal(t, []int{-1, 0, 1}, getWaveNumbers(waves))
+	assert.Len(t, waves[1].objects, 6)
+
+	m, err = parseManifest([]byte(`
+kind: ConfigMap
+metadata:
+  name: alpha
+  annotations:
+    k8s.jabberwocky.se/sync-wave: first
+`))
+	assert.NoError(t, err)
+	assert.EqualError(t, m.sortByWave(), "invalid sync wave 'first' of ConfigMap 'alpha'")
+}
+
+func TestGetKindRank(t *testing.T) {
+	assert.True(t, getKindRank("Namespace") < getKindRank("CustomResourceDefinition"))
+	assert.True(t, getKindRank("CustomResourceDefinition") < getKindRank("RoleBinding"))
+	assert.True(t, getKindRank("RoleBinding") < getKindRank("Secret"))
+	assert.True(t, getKindRank("ConfigMap") < getKindRank("Deployment"))
+	assert.Equal(t, len(kindOrder), getKindRank("Epsilon"), "unknown kinds should be applied last")
+}
+
+func TestGetSiblingWaveMessage(t *testing.T) {
+	newApp := func(name string, health k8v1alpha1.HealthStatus, wave *k8v1alpha1.SyncWaveStatus, waves ...int) k8v1alpha1.CloudConfigApp {
+		app := k8v1alpha1.CloudConfigApp{}
+		app.Name = name
+		app.Status.Health = health
+		app.Status.Wave = wave
+		app.Status.Waves = waves
+		return app
+	}
+
+	siblings := []k8v1alpha1.CloudConfigApp{
+		newApp("alpha", k8v1alpha1.HealthDegraded, nil, 0),
+		newApp("beta", k8v1alpha1.HealthProgressing, newSyncWaveStatus("0123456789ab", 2, "")),
+		newApp("gamma", k8v1alpha1.HealthHealthy, nil),
+	}
+	assert.Empty(t, getSiblingWaveMessage(siblings, 0), "siblings without earlier waves should not block a wave")
+	assert.Equal(t, "app 'alpha' is Degraded", getSiblingWaveMessage(siblings, 1))
+
+	siblings[0] = newApp("alpha", k8v1alpha1.HealthProgressing, newSyncWaveStatus("0123456789ab", 1, ""), -1, 0, 1)
+	assert.Empty(t, getSiblingWaveMessage(siblings, 1), "siblings waiting for the same wave should not block it")
+	assert.Equal(t, "app 'alpha' is waiting for sync wave 1", getSiblingWaveMessage(siblings, 2))
+
+	siblings[0] = newApp("alpha", k8v1alpha1.HealthProgressing, nil, -1, 0)
+	siblings[0].Status.HeldBack = "app 'gamma' is Progressing"
+	assert.Empty(t, getSiblingWaveMessage(siblings, 1), "siblings held back by their dependencies should not block a wave")
+}
@@ -0,0 +1,889 @@
+package cloudconfig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// AddCloudConfigApp creates a new CloudConfigApp Controller and adds it to the Manager. The Manager will set fields
+// on the Controller and Start it when the Manager is Started.
+func AddCloudConfigApp(mgr manager.Manager) error {
+	return addCloudConfigApp(mgr, newCloudConfigAppReconciler(mgr))
+}
+
+// newCloudConfigAppReconciler returns a new reconcile.Reconciler
+func newCloudConfigAppReconciler(mgr manager.Manager) reconcile.Reconciler {
+	return &ReconcileCloudConfigApp{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		mapper:   mgr.GetRESTMapper(),
+		recorder: mgr.GetRecorder("cloudconfigapp-controller"),
+	}
+}
+
+// addCloudConfigApp adds a new Controller to mgr with r as the reconcile.Reconciler
+func addCloudConfigApp(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("cloudconfigapp-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource CloudConfigApp
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigApp{}}, &handler.EnqueueRequestForObject{}, specChanged)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+var _ reconcile.Reconciler = &ReconcileCloudConfigApp{}
+
+// ReconcileCloudConfigApp reconciles a CloudConfigApp object
+type ReconcileCloudConfigApp struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	mapper   meta.RESTMapper
+	recorder record.EventRecorder
+}
+
+// Reconcile retrieves the spec files of a CloudConfigApp and applies them to the CloudConfigApp's namespace.
+// All objects of the app are labelled with the CloudConfigApp name so that objects removed from the spec files
+// are pruned and namespaced objects are owned by the CloudConfigApp so that they are garbage collected when the
+// CloudConfigApp is deleted.
+func (r *ReconcileCloudConfigApp) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	start := time.Now()
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling CloudConfigApp")
+
+	// Fetch the CloudConfigApp instance
+	app := &k8v1alpha1.CloudConfigApp{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, app)
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			// Owned objects are automatically garbage collected
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	if app.DeletionTimestamp != nil {
+		// Delete the objects that are not garbage collected as they are in another namespace
+		return reconcile.Result{}, r.finalize(app)
+	}
+
+	if reason := getSuspendReason(app, app.Spec.Suspend); reason != "" {
+		reqLogger.Info("CloudConfigApp is suspended; no synchronization", "Reason", reason)
+		return reconcile.Result{}, nil
+	}
+
+	spec := getEffectiveSpec(&app.Spec.CloudConfigSpec, app.Name)
+	if err = validate(spec); err != nil {
+		log.Error(err, "Validation failed")
+		if err.Error() != app.Status.Error {
+			r.recorder.Event(app, corev1.EventTypeWarning, "ValidationFailed", err.Error())
+		}
+		app.Status.Error = err.Error()
+		if err := r.client.Status().Update(context.TODO(), app); err != nil {
+			reqLogger.Error(err, "Could not update the CloudConfigApp status")
+		}
+		// Return and don't requeue, the app is reconciled again when its spec changes
+		return reconcile.Result{}, nil
+	}
+
+	syncRequest, forced := getSyncRequest(app, app.Status.LastSyncRequest)
+	if isWaiting(&app.Status) && !isHeldBack(&app.Status) {
+		// check the health and rollout of the last applied revision until it is healthy or fails before
+		// synchronizing again
+		if err := r.checkHealth(app, spec); err != nil {
+			reqLogger.Error(err, "Could not check the health")
+		}
+		if syncRequest == "" && (isWaiting(&app.Status) || app.Status.NextSync != nil && app.Status.NextSync.After(start)) {
+			if err := r.client.Status().Update(context.TODO(), app); err != nil {
+				reqLogger.Error(err, "Could not update the CloudConfigApp status")
+			}
+			next := start.Add(rolloutCheckInterval)
+			if !isWaiting(&app.Status) {
+				next = app.Status.NextSync.Time
+			}
+			reqLogger.Info(fmt.Sprintf("Reconciled CloudConfigApp; app %s, rescheduling at %v", app.Status.Health, next))
+			return requeueAt(next), nil
+		}
+	}
+
+	allowed, windowChange, err := checkSyncWindows(spec.SyncWindows, start)
+	if err != nil {
+		reqLogger.Error(err, "Could not check the sync windows")
+	}
+
+	// dry runs do not change the cluster and are not restricted by the sync windows
+	dryRun := isDryRun(spec, syncRequest)
+	permitted := allowed || forced || dryRun
+
+	if permitted {
+		applied, err := r.syncApp(app, spec, dryRun)
+		switch {
+		case err != nil:
+			reqLogger.Error(err, "Synchronization failed")
+			app.Status.Error = err.Error()
+		case applied:
+			reqLogger.Info(fmt.Sprintf("Synchronized app '%s' in %v", spec.AppName, time.Since(start)))
+			now := metav1.Now()
+			app.Status.LastSync = &now
+			app.Status.Error = ""
+		default:
+			app.Status.Error = ""
+		}
+		if syncRequest != "" {
+			app.Status.LastSyncRequest = syncRequest
+		}
+	} else {
+		reqLogger.Info(fmt.Sprintf("Sync windows do not allow synchronization until %v", windowChange))
+	}
+
+	next, err := getNextSync(spec, start)
+	if err != nil {
+		reqLogger.Error(err, "Could not schedule the next synchronization")
+	}
+	// retry a synchronization denied by the sync windows when the windows change
+	if !permitted && !windowChange.IsZero() && (next.IsZero() || windowChange.Before(next)) {
+		next = windowChange
+	}
+	app.Status.NextSync = newTime(next)
+	// check the health and rollout of an applied revision shortly
+	if check := start.Add(rolloutCheckInterval); isWaiting(&app.Status) && (next.IsZero() || check.Before(next)) {
+		next = check
+	}
+
+	if err := r.client.Status().Update(context.TODO(), app); err != nil {
+		reqLogger.Error(err, "Could not update the CloudConfigApp status")
+	}
+
+	if next.IsZero() {
+		reqLogger.Info("Reconciled CloudConfigApp; no rescheduling")
+		return reconcile.Result{}, nil
+	}
+	reqLogger.Info(fmt.Sprintf("Reconciled CloudConfigApp; rescheduling at %v", next))
+	return requeueAt(next), nil
+}
+
+// syncApp retrieves and renders the app's spec files and applies them to the target namespace. Dry runs and
+// revisions waiting for approval only report the diff against the live objects, the result is true if the
+// objects were applied.
+func (r *ReconcileCloudConfigApp) syncApp(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec,
+	dryRun bool) (bool, error) {
+
+	target := getAppTargetNamespace(app, spec)
+	m, rendered, entry, err := r.render(app, spec, target)
+	if err != nil {
+		return false, err
+	}
+
+	if dryRun {
+		log.Info(fmt.Sprintf("Dry run of app '%s'", spec.AppName))
+		return false, r.reportDiff(app, target, m)
+	}
+
+	// pinned revisions are applied regardless of earlier rollbacks and approvals
+	revision := entry.Revision
+	if !entry.Pinned && isRolledBack(&app.Status, revision) {
+		log.Info(fmt.Sprintf("Revision '%s' of app '%s' was rolled back; waiting for a new revision", revision, spec.AppName))
+		return false, nil
+	}
+	if !entry.Pinned && isManual(spec) && !isApproved(app, revision, app.Status.Revision) {
+		log.Info(fmt.Sprintf("Revision '%s' of app '%s' is waiting for approval", revision, spec.AppName))
+		app.Status.PendingRevision = revision
+		return false, r.reportDiff(app, target, m)
+	}
+
+	siblings, err := r.getSiblings(app)
+	if err != nil {
+		return false, err
+	}
+	if r.holdBack(app, siblings) {
+		return false, nil
+	}
+
+	user, err := getAppUser(r.client, app)
+	if err != nil {
+		return false, err
+	}
+	if err := ensureTargetNamespace(r.client, getSourceNamespace(app), target, user, spec); err != nil {
+		return false, err
+	}
+
+	// owner references cannot cross namespaces nor be set on cluster scoped objects, objects in other namespaces
+	// and cluster scoped objects are deleted by the finalizer
+	if (target != app.Namespace || m.hasUnownedObjects(r.mapper)) && !hasFinalizer(app, pruneFinalizer) {
+		if err := r.addFinalizer(app, pruneFinalizer); err != nil {
+			return false, err
+		}
+	}
+
+	if waiting, err := r.applyWaves(app, siblings, target, revision, user, m); waiting || err != nil {
+		return false, err
+	}
+	if len(m) == 0 {
+		log.Info(fmt.Sprintf("No objects found for app '%s'", spec.AppName))
+	} else {
+		if err := apply(target, getAppSelector(app), user, &rendered); err != nil {
+			return false, err
+		}
+		app.Status.TargetNamespace = target
+		app.Status.Kinds = m.getKinds()
+	}
+	if app.Status.Rollout == nil || app.Status.Rollout.Revision != revision {
+		if err := r.startRollout(app, spec, entry, rendered); err != nil {
+			return true, err
+		}
+	} else {
+		health, message, err := assessHealth(target, user, m)
+		if err != nil {
+			return true, err
+		}
+		r.setHealth(app, health, message)
+	}
+	app.Status.Revision = revision
+	app.Status.PendingRevision = ""
+	return true, nil
+}
+
+// applyWaves applies all sync waves of the manifest but the last in order without pruning, each wave waits for
+// the objects of the previous waves of the app and of its sibling apps to become healthy. The result is true if a
+// wave is waiting; the last wave is applied by the caller together with the pruning of removed objects.
+func (r *ReconcileCloudConfigApp) applyWaves(
+	app *k8v1alpha1.CloudConfigApp,
+	siblings []k8v1alpha1.CloudConfigApp,
+	target, revision, user string,
+	m manifest) (bool, error) {
+
+	waves := m.getWaves()
+	app.Status.Waves = getWaveNumbers(waves)
+	for i, wave := range waves {
+		if message := getSiblingWaveMessage(siblings, wave.wave); message != "" {
+			r.waitForWave(app, target, revision, wave.wave, m, message)
+			return true, nil
+		}
+		if i == len(waves)-1 {
+			break
+		}
+
+		rendered, err := wave.objects.toYAML()
+		if err != nil {
+			return false, err
+		}
+		if err := apply(target, "", user, &rendered); err != nil {
+			return false, err
+		}
+		health, message, err := assessHealth(target, user, wave.objects)
+		if err != nil {
+			return false, err
+		}
+		if health != k8v1alpha1.HealthHealthy {
+			r.waitForWave(app, target, revision, waves[i+1].wave, m, fmt.Sprintf("sync wave %d is %s: %s", wave.wave, health, message))
+			return true, nil
+		}
+	}
+	app.Status.Wave = nil
+	return false, nil
+}
+
+// waitForWave records that the sync wave of the revision is waiting for the reason given by the message. The kinds
+// of the manifest are recorded so that the objects of the applied waves are deleted with the app.
+func (r *ReconcileCloudConfigApp) waitForWave(
+	app *k8v1alpha1.CloudConfigApp,
+	target, revision string,
+	wave int,
+	m manifest,
+	message string) {
+
+	log.Info(fmt.Sprintf("Sync wave %d of app '%s' is waiting: %s", wave, app.Name, message))
+	app.Status.TargetNamespace = target
+	app.Status.Kinds = mergeKinds(app.Status.Kinds, m.getKinds())
+	app.Status.Wave = newSyncWaveStatus(revision, wave, message)
+	r.setHealth(app, k8v1alpha1.HealthProgressing, fmt.Sprintf("Sync wave %d is waiting: %s", wave, message))
+}
+
+// holdBack holds back the synchronization of the app while any of the apps it depends on is not healthy, the
+// result is true if the app is held back
+func (r *ReconcileCloudConfigApp) holdBack(app *k8v1alpha1.CloudConfigApp, siblings []k8v1alpha1.CloudConfigApp) bool {
+	message := getDependencyMessage(siblings, app.Spec.Dependencies)
+	app.Status.HeldBack = message
+	if message == "" {
+		return false
+	}
+	log.Info(fmt.Sprintf("App '%s' is held back by its dependencies: %s", app.Name, message))
+	r.setHealth(app, k8v1alpha1.HealthProgressing, "Held back by its dependencies: "+message)
+	return true
+}
+
+// getSiblings returns the other CloudConfigApps of the CloudConfig, CloudConfigEnv or ClusterCloudConfig of the app
+func (r *ReconcileCloudConfigApp) getSiblings(app *k8v1alpha1.CloudConfigApp) ([]k8v1alpha1.CloudConfigApp, error) {
+	if app.Labels[k8v1alpha1.CloudConfigLabel] == "" && !isClusterApp(app) {
+		return nil, nil
+	}
+
+	selector := make(map[string]string, 3)
+	for _, key := range []string{
+		k8v1alpha1.CloudConfigLabel,
+		k8v1alpha1.CloudConfigNamespaceLabel,
+		k8v1alpha1.ClusterCloudConfigLabel,
+		k8v1alpha1.EnvLabel,
+	} {
+		if value, found := app.Labels[key]; found {
+			selector[key] = value
+		}
+	}
+	apps := &k8v1alpha1.CloudConfigAppList{}
+	opts := client.InNamespace(app.Namespace).MatchingLabels(selector)
+	if err := r.client.List(context.TODO(), opts, apps); err != nil {
+		return nil, err
+	}
+
+	siblings := make([]k8v1alpha1.CloudConfigApp, 0, len(apps.Items))
+	for _, sibling := range apps.Items {
+		if sibling.Name != app.Name {
+			siblings = append(siblings, sibling)
+		}
+	}
+	return siblings, nil
+}
+
+// startRollout adds an applied revision to the revision history of the app, keeps its rendered spec files in
+// the history ConfigMap of the app and starts tracking the rollout of the revision
+func (r *ReconcileCloudConfigApp) startRollout(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec,
+	entry k8v1alpha1.RevisionHistory,
+	rendered []byte) error {
+
+	history, err := getHistory(r.client, app.Name, app.Namespace)
+	if err != nil {
+		return err
+	}
+	if err := setRevision(history, entry.Revision, rendered); err != nil {
+		return err
+	}
+
+	entry.Applied = metav1.Now()
+	entry.Outcome = k8v1alpha1.RolloutProgressing
+	app.Status.History = addRevisionHistory(app.Status.History, entry, getRevisionHistoryLimit(spec))
+	pruneHistory(history, append(getRevisions(app.Status.History), app.Status.LastGoodRevision)...)
+
+	if err := controllerutil.SetControllerReference(app, history, r.scheme); err != nil {
+		return err
+	}
+	if err := createOrUpdateConfigMap(r.client, history); err != nil {
+		return err
+	}
+	app.Status.Rollout = newRolloutStatus(entry.Revision)
+	r.setHealth(app, k8v1alpha1.HealthProgressing, fmt.Sprintf("Revision '%s' applied", entry.Revision))
+	return nil
+}
+
+// checkHealth assesses the health of the live objects of the revision last applied to the app, the rollout of the
+// revision is checked while it is progressing
+func (r *ReconcileCloudConfigApp) checkHealth(app *k8v1alpha1.CloudConfigApp, spec *k8v1alpha1.CloudConfigSpec) error {
+	if isProgressing(&app.Status) {
+		return r.checkRollout(app, spec)
+	}
+
+	history, err := getHistory(r.client, app.Name, app.Namespace)
+	if err != nil {
+		return err
+	}
+	m, found, err := getRevisionManifest(history, app.Status.Revision)
+	if err != nil {
+		return err
+	}
+	if !found {
+		r.setHealth(app, k8v1alpha1.HealthDegraded, fmt.Sprintf("revision '%s' not found in the history", app.Status.Revision))
+		return nil
+	}
+
+	user, err := getAppUser(r.client, app)
+	if err != nil {
+		return err
+	}
+	health, message, err := assessHealth(app.Status.TargetNamespace, user, m)
+	if err != nil {
+		return err
+	}
+	r.setHealth(app, health, message)
+	return nil
+}
+
+// setHealth sets the health of the app and records an event when the app becomes healthy or degraded
+func (r *ReconcileCloudConfigApp) setHealth(app *k8v1alpha1.CloudConfigApp, health k8v1alpha1.HealthStatus, message string) {
+	if health != app.Status.Health {
+		switch health {
+		case k8v1alpha1.HealthHealthy:
+			r.recorder.Event(app, corev1.EventTypeNormal, string(health), "All objects are healthy")
+		case k8v1alpha1.HealthDegraded:
+			r.recorder.Event(app, corev1.EventTypeWarning, string(health), message)
+		}
+	}
+	app.Status.Health = health
+	app.Status.HealthMessage = message
+}
+
+// checkRollout checks if the objects of the revision being rolled out are healthy. The revision becomes the last
+// good revision if they are. If they are not healthy within the progress deadline the rollout fails and, if
+// rollbacks are enabled, the last good revision is applied again.
+func (r *ReconcileCloudConfigApp) checkRollout(app *k8v1alpha1.CloudConfigApp, spec *k8v1alpha1.CloudConfigSpec) error {
+	rollout := app.Status.Rollout
+	history, err := getHistory(r.client, app.Name, app.Namespace)
+	if err != nil {
+		return err
+	}
+	m, found, err := getRevisionManifest(history, rollout.Revision)
+	if err != nil {
+		return err
+	}
+	if !found {
+		rollout.Phase = k8v1alpha1.RolloutFailed
+		rollout.Message = fmt.Sprintf("revision '%s' not found in the history", rollout.Revision)
+		setRevisionOutcome(app.Status.History, rollout.Revision, rollout.Phase)
+		r.setHealth(app, k8v1alpha1.HealthDegraded, rollout.Message)
+		return nil
+	}
+
+	user, err := getAppUser(r.client, app)
+	if err != nil {
+		return err
+	}
+	health, message, err := assessHealth(app.Status.TargetNamespace, user, m)
+	if err != nil {
+		return err
+	}
+
+	if health == k8v1alpha1.HealthHealthy {
+		log.Info(fmt.Sprintf("Rollout of revision '%s' of app '%s' succeeded", rollout.Revision, app.Name))
+		rollout.Phase = k8v1alpha1.RolloutSucceeded
+		rollout.Message = ""
+		setRevisionOutcome(app.Status.History, rollout.Revision, rollout.Phase)
+		app.Status.LastGoodRevision = rollout.Revision
+		history.Data[lastGoodRevisionKey] = rollout.Revision
+		pruneHistory(history, append(getRevisions(app.Status.History), rollout.Revision)...)
+		r.recorder.Event(app, corev1.EventTypeNormal, "RolloutSucceeded", "Revision "+rollout.Revision+" is healthy")
+		r.setHealth(app, k8v1alpha1.HealthHealthy, "")
+		return createOrUpdateConfigMap(r.client, history)
+	}
+
+	rollout.Message = message
+	if time.Since(rollout.Started.Time) < getProgressDeadline(spec) {
+		r.setHealth(app, health, message)
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Rollout of revision '%s' of app '%s' failed: %s", rollout.Revision, app.Name, message))
+	rollout.Phase = k8v1alpha1.RolloutFailed
+	setRevisionOutcome(app.Status.History, rollout.Revision, rollout.Phase)
+	r.recorder.Event(app, corev1.EventTypeWarning, "RolloutFailed", "Revision "+rollout.Revision+" failed: "+message)
+	r.setHealth(app, k8v1alpha1.HealthDegraded, message)
+	if spec.Rollback == nil || app.Status.LastGoodRevision == "" || app.Status.LastGoodRevision == rollout.Revision {
+		return nil
+	}
+	return r.rollback(app, history)
+}
+
+// rollback applies the last good revision of the app kept in the history ConfigMap
+func (r *ReconcileCloudConfigApp) rollback(app *k8v1alpha1.CloudConfigApp, history *corev1.ConfigMap) error {
+	revision := app.Status.LastGoodRevision
+	rendered, found, err := getRevisionSpec(history, revision)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("last good revision '%s' not found in the history", revision)
+	}
+
+	m, err := parseManifest(rendered)
+	if err != nil {
+		return err
+	}
+	user, err := getAppUser(r.client, app)
+	if err != nil {
+		return err
+	}
+	if err := apply(app.Status.TargetNamespace, getAppSelector(app), user, &rendered); err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("Rolled back app '%s' to revision '%s'", app.Name, revision))
+	rollout := app.Status.Rollout
+	rollout.Phase = k8v1alpha1.RolloutRolledBack
+	setRevisionOutcome(app.Status.History, rollout.Revision, rollout.Phase)
+	rollout.Message = fmt.Sprintf("rolled back to revision '%s': %s", revision, rollout.Message)
+	app.Status.Revision = revision
+	app.Status.Kinds = m.getKinds()
+	app.Status.Wave = nil
+	r.recorder.Event(app, corev1.EventTypeWarning, "RolledBack", "Rolled back to revision "+revision)
+	r.setHealth(app, k8v1alpha1.HealthProgressing, fmt.Sprintf("Rolled back to revision '%s'", revision))
+	return nil
+}
+
+// reportDiff stores the diff of the manifest against the live objects of the app in the dry run ConfigMap of the
+// CloudConfigApp and summarizes it in the status, the objects of the app are not changed
+func (r *ReconcileCloudConfigApp) reportDiff(app *k8v1alpha1.CloudConfigApp, target string, m manifest) error {
+	// objects of kinds removed from the spec files are pruned as well
+	user, err := getAppUser(r.client, app)
+	if err != nil {
+		return err
+	}
+	diffs, err := diffManifest(target, getAppSelector(app), user, m, mergeKinds(m.getKinds(), app.Status.Kinds))
+	if err != nil {
+		return err
+	}
+
+	cm, err := newDryRunConfigMap(app.Name, app.Namespace, diffs)
+	if err != nil {
+		return err
+	}
+	if err := controllerutil.SetControllerReference(app, cm, r.scheme); err != nil {
+		return err
+	}
+	if err := createOrUpdateConfigMap(r.client, cm); err != nil {
+		return err
+	}
+	app.Status.DryRun = newDryRunStatus(diffs, cm.Name)
+	return nil
+}
+
+// render returns the objects of the app rendered for the target namespace together with the revision history
+// entry describing them. The spec files of a pinned revision are taken from the history ConfigMap of the app,
+// otherwise they are retrieved from the config server.
+func (r *ReconcileCloudConfigApp) render(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec,
+	target string) (manifest, []byte, k8v1alpha1.RevisionHistory, error) {
+
+	if spec.PinnedRevision != "" {
+		return r.renderPinned(app, spec)
+	}
+
+	entry := k8v1alpha1.RevisionHistory{Label: spec.Label, Profile: append([]string(nil), spec.Profile...)}
+	configNamespace, err := getConfigNamespace(r.client, app)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+	client, err := createClient(r.client, configNamespace, spec)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+
+	file, err := getAppSpec(client, spec, spec.AppName)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+
+	// the version is informational, synchronization continues if the server does not provide it
+	if entry.Version, err = client.GetVersion(spec.AppName, spec.Label, spec.Profile...); err != nil {
+		log.Error(err, fmt.Sprintf("Could not get the config server version of app '%s'", spec.AppName))
+	}
+
+	m, err := parseManifest(file)
+	if err != nil {
+		return nil, nil, entry, fmt.Errorf("could not parse the spec of app '%s': %s", spec.AppName, err.Error())
+	}
+	if err := validateSchema(spec.AppName, m); err != nil {
+		return nil, nil, entry, err
+	}
+	app.Status.OverriddenImages = m.overrideImages(spec.Images)
+	if m, err = r.enforceKinds(app, spec, m); err != nil {
+		return nil, nil, entry, err
+	}
+	if err := r.enforcePolicy(app, spec, m); err != nil {
+		return nil, nil, entry, err
+	}
+	if err := m.sortByWave(); err != nil {
+		return nil, nil, entry, err
+	}
+
+	m.setLabel(k8v1alpha1.CloudConfigAppLabel, app.Name)
+	m.setMetadata(newObjectLabels(spec, getAppIdentity(app, spec)), spec.CommonAnnotations)
+	m.setAnnotations(getConfigAnnotations(spec.Label, ""))
+	if target == app.Namespace {
+		m.setOwnerReference(newOwnerReference(app), app.Namespace, r.mapper)
+	}
+
+	rendered, err := m.toYAML()
+	if err != nil {
+		return nil, nil, entry, err
+	}
+	entry.Revision = getRevision(rendered)
+
+	// the version changes with every commit of the config repository and is not part of the revision
+	if entry.Version != "" {
+		m.setAnnotations(getConfigAnnotations("", entry.Version))
+		if rendered, err = m.toYAML(); err != nil {
+			return nil, nil, entry, err
+		}
+	}
+	return m, rendered, entry, nil
+}
+
+// renderPinned returns the objects of the pinned revision of the app kept in its history ConfigMap, objects whose
+// kinds are no longer allowed are not applied and the objects are checked against the current policy
+func (r *ReconcileCloudConfigApp) renderPinned(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec) (manifest, []byte, k8v1alpha1.RevisionHistory, error) {
+
+	pinned := spec.PinnedRevision
+	entry, found := findRevision(app.Status.History, pinned)
+	if !found {
+		return nil, nil, entry, fmt.Errorf("pinned revision '%s' not found in the history of app '%s'", pinned, app.Name)
+	}
+	entry.Pinned = true
+
+	history, err := getHistory(r.client, app.Name, app.Namespace)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+	rendered, found, err := getRevisionSpec(history, entry.Revision)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+	if !found {
+		return nil, nil, entry, fmt.Errorf("spec files of the pinned revision '%s' not found in the history", entry.Revision)
+	}
+
+	// revisions kept before objects were ordered by sync wave are ordered when applied
+	m, err := parseManifest(rendered)
+	if err != nil {
+		return nil, nil, entry, err
+	}
+	// the images of the revision were overridden when it was rendered
+	app.Status.OverriddenImages = nil
+	if m, err = r.enforceKinds(app, spec, m); err != nil {
+		return nil, nil, entry, err
+	}
+	if err := r.enforcePolicy(app, spec, m); err != nil {
+		return nil, nil, entry, err
+	}
+	if err := m.sortByWave(); err != nil {
+		return nil, nil, entry, err
+	}
+	rendered, err = m.toYAML()
+	return m, rendered, entry, err
+}
+
+// enforceKinds returns the objects of the manifest allowed by the kind policy and reports the rejected objects in
+// the status of the app
+func (r *ReconcileCloudConfigApp) enforceKinds(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec,
+	m manifest) (manifest, error) {
+
+	allowed, rejected, err := filterKinds(spec, m)
+	if err != nil {
+		return nil, err
+	}
+	recordRejected(r.recorder, app, app.Status.Rejected, rejected)
+	app.Status.Rejected = rejected
+	return allowed, nil
+}
+
+// enforcePolicy checks the objects of the manifest against the policy of the spec and reports the violations in
+// the status of the app, violations of a blocking policy fail the synchronization
+func (r *ReconcileCloudConfigApp) enforcePolicy(
+	app *k8v1alpha1.CloudConfigApp,
+	spec *k8v1alpha1.CloudConfigSpec,
+	m manifest) error {
+
+	violations, err := checkPolicy(r.client, app.Namespace, spec, m)
+	recordViolations(r.recorder, app, app.Status.Violations, violations)
+	app.Status.Violations = violations
+	return err
+}
+
+const pruneFinalizer = "prune.k8s.jabberwocky.se"
+
+// addFinalizer adds the finalizer to the app. A copy of the app is updated as the update returns the stored
+// status of the app, which would overwrite the changes made to its status by the ongoing synchronization, e.g. the
+// overridden images, rejected objects and policy violations.
+func (r *ReconcileCloudConfigApp) addFinalizer(app *k8v1alpha1.CloudConfigApp, finalizer string) error {
+	updated := app.DeepCopy()
+	updated.Finalizers = append(updated.Finalizers, finalizer)
+	if err := r.client.Update(context.TODO(), updated); err != nil {
+		return err
+	}
+	app.Finalizers = updated.Finalizers
+	app.ResourceVersion = updated.ResourceVersion
+	return nil
+}
+
+// finalize deletes the objects of a deleted CloudConfigApp applied to another namespace and its cluster scoped
+// objects and removes the prune finalizer. Namespaces are never deleted with an app as this would delete all
+// objects in them. The objects of an app of a ClusterCloudConfig without target namespace are deleted from all
+// namespaces.
+func (r *ReconcileCloudConfigApp) finalize(app *k8v1alpha1.CloudConfigApp) error {
+	if !hasFinalizer(app, pruneFinalizer) {
+		return nil
+	}
+	kinds := getPrunedKinds(app.Status.Kinds)
+	if (app.Status.TargetNamespace != "" || isClusterApp(app)) && len(kinds) > 0 {
+		user, err := getAppUser(r.client, app)
+		if err != nil {
+			// the owner of the app may have been deleted before the app, the objects are then deleted as the
+			// ServiceAccount of the namespace of the app rather than of an unverified namespace
+			log.Error(err, fmt.Sprintf("Could not verify the owner of app '%s'", app.Name))
+			user = getServiceAccountUser(app.Namespace, &app.Spec.CloudConfigSpec)
+		}
+		if err := deleteObjects(app.Status.TargetNamespace, getAppSelector(app), user, kinds); err != nil {
+			return err
+		}
+	}
+
+	finalizers := make([]string, 0, len(app.Finalizers))
+	for _, f := range app.Finalizers {
+		if f != pruneFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	app.Finalizers = finalizers
+	return r.client.Update(context.TODO(), app)
+}
+
+// getPrunedKinds returns the kinds of the objects deleted with an app, i.e. all kinds but namespaces
+func getPrunedKinds(kinds []string) []string {
+	pruned := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		if kind != "namespace" {
+			pruned = append(pruned, kind)
+		}
+	}
+	return pruned
+}
+
+// getAppSelector returns the label selector of the Kubernetes objects of the CloudConfigApp. The objects of an app of
+// a ClusterCloudConfig are also selected by the ClusterCloudConfig as they may be deleted from all namespaces, where
+// CloudConfigApps of other namespaces may have the same name.
+func getAppSelector(app *k8v1alpha1.CloudConfigApp) string {
+	selector := k8v1alpha1.CloudConfigAppLabel + "=" + app.Name
+	if isClusterApp(app) {
+		selector += "," + k8v1alpha1.ClusterCloudConfigLabel + "=" + app.Labels[k8v1alpha1.ClusterCloudConfigLabel]
+	}
+	return selector
+}
+
+// isClusterApp returns true if the CloudConfigApp is an app of a ClusterCloudConfig. Only the CloudConfigApps in the
+// namespace of the operator belong to ClusterCloudConfigs as these manage all namespaces.
+func isClusterApp(app *k8v1alpha1.CloudConfigApp) bool {
+	return app.Labels[k8v1alpha1.ClusterCloudConfigLabel] != "" && app.Namespace == getOperatorNamespace()
+}
+
+// getAppTargetNamespace returns the namespace where the objects of the app are applied. The objects of an app of a
+// ClusterCloudConfig are applied to its target namespace, if any, or to the namespaces given in their metadata.
+func getAppTargetNamespace(app *k8v1alpha1.CloudConfigApp, spec *k8v1alpha1.CloudConfigSpec) string {
+	if isClusterApp(app) {
+		return spec.TargetNamespace
+	}
+	return getTargetNamespace(app.Namespace, spec)
+}
+
+// getSourceNamespace returns the namespace the target namespace of the app must allow, empty for an app of a
+// ClusterCloudConfig as it is allowed to manage all namespaces
+func getSourceNamespace(app *k8v1alpha1.CloudConfigApp) string {
+	if isClusterApp(app) {
+		return ""
+	}
+	return app.Namespace
+}
+
+// newOwnerReference returns an owner reference to the CloudConfigApp for the app's Kubernetes objects
+func newOwnerReference(app *k8v1alpha1.CloudConfigApp) metav1.OwnerReference {
+	gvk := k8v1alpha1.SchemeGroupVersion.WithKind("CloudConfigApp")
+	return metav1.OwnerReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       app.Name,
+		UID:        app.UID,
+	}
+}
+
+var execCommand = exec.Command
+
+// apply applies the spec to the namespace and prunes all objects matching the selector that are not in the spec,
+// nothing is pruned if the selector is empty. If the namespace is empty namespaced objects are applied to the
+// namespace given in their metadata. The objects are applied and pruned as the user if it is not empty.
+func apply(namespace, selector, user string, spec *[]byte) error {
+	args := make([]string, 0, 7)
+	if namespace != "" {
+		args = append(args, "--namespace="+namespace)
+	}
+	args = append(args, getImpersonationArgs(user)...)
+	args = append(args, "apply")
+	if selector != "" {
+		args = append(args, "--prune", "--selector="+selector)
+	}
+	args = append(args, "-f", "-")
+	cmd := execCommand("kubectl", args...)
+
+	cmd.Stdin = bytes.NewReader(*spec)
+	log.Info(strings.Join(cmd.Args, " "))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Could not apply spec for '%s'", namespace),
+			"command", strings.Join(cmd.Args, " "),
+			"output", string(out))
+		return err
+	}
+
+	log.Info(fmt.Sprintf("Applied spec for '%s'", namespace),
+		"command", strings.Join(cmd.Args, " "),
+		"output", string(out))
+	return nil
+}
+
+// deleteObjects deletes all objects of the kinds matching the selector from the namespace, or from all namespaces if
+// it is empty, as the user if it is not empty
+func deleteObjects(namespace, selector, user string, kinds []string) error {
+	args := make([]string, 0, 7)
+	if namespace != "" {
+		args = append(args, "--namespace="+namespace)
+	}
+	args = append(args, getImpersonationArgs(user)...)
+	args = append(args, "delete", strings.Join(kinds, ","), "--selector="+selector, "--ignore-not-found")
+	if namespace == "" {
+		args = append(args, "--all-namespaces")
+	}
+	cmd := execCommand("kubectl", args...)
+
+	log.Info(strings.Join(cmd.Args, " "))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Error(err, fmt.Sprintf("Could not delete objects in '%s'", namespace),
+			"command", strings.Join(cmd.Args, " "),
+			"output", string(out))
+		return err
+	}
+
+	log.Info(fmt.Sprintf("Deleted objects in '%s'", namespace),
+		"command", strings.Join(cmd.Args, " "),
+		"output", string(out))
+	return nil
+}
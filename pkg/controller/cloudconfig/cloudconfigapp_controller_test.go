@@ -0,0 +1,148 @@
+package cloudconfig
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNewCloudConfigApp(t *testing.T) {
+	c := &k8v1alpha1.CloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "test"},
+	}
+	spec := c.Spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "alpha", Label: "release"})
+
+	app := newCloudConfigApp(c.Name, c.Namespace, spec, newCloudConfigLabels(c))
+	assert.Equal(t, "cluster-alpha", app.Name)
+	assert.Equal(t, "test", app.Namespace)
+	assert.Equal(t, map[string]string{
+		k8v1alpha1.CloudConfigLabel:          "cluster",
+		k8v1alpha1.CloudConfigNamespaceLabel: "test",
+		k8v1alpha1.AppLabel:                  "alpha",
+	}, app.Labels)
+	assert.Equal(t, "alpha", app.Spec.AppName)
+	assert.Equal(t, "release", app.Spec.Label)
+	assert.False(t, app.Spec.Suspend)
+}
+
+func TestClusterApp(t *testing.T) {
+	defer os.Unsetenv(operatorNamespaceEnvVar)
+	os.Setenv(operatorNamespaceEnvVar, "operators")
+
+	spec := &k8v1alpha1.CloudConfigSpec{AppName: "quotas"}
+	c := &k8v1alpha1.ClusterCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+	app := newCloudConfigApp(getClusterAppPrefix(c.Name, ""), "operators", spec, newClusterCloudConfigLabels(c))
+	assert.True(t, isClusterApp(app))
+	assert.Empty(t, getAppTargetNamespace(app, spec), "the objects should be applied to the namespaces in their metadata")
+	assert.Empty(t, getSourceNamespace(app), "apps of ClusterCloudConfigs should manage all namespaces")
+	assert.Equal(t, k8v1alpha1.CloudConfigAppLabel+"=cluster-platform-quotas,"+k8v1alpha1.ClusterCloudConfigLabel+"=platform",
+		getAppSelector(app))
+
+	spec.TargetNamespace = "quotas"
+	assert.Equal(t, "quotas", getAppTargetNamespace(app, spec))
+
+	app.Namespace = "team"
+	assert.False(t, isClusterApp(app), "apps outside of the operator namespace should not belong to ClusterCloudConfigs")
+	assert.Equal(t, "team", getSourceNamespace(app))
+	assert.Equal(t, k8v1alpha1.CloudConfigAppLabel+"=cluster-platform-quotas", getAppSelector(app))
+
+	spec.TargetNamespace = ""
+	assert.Equal(t, "team", getAppTargetNamespace(app, spec))
+}
+
+// recordExecCommand returns an execCommand running fakeExecCommand that records the arguments of each command
+func recordExecCommand(commands *[][]string) func(string, ...string) *exec.Cmd {
+	return func(command string, args ...string) *exec.Cmd {
+		*commands = append(*commands, append([]string{command}, args...))
+		return fakeExecCommand(command, args...)
+	}
+}
+
+func TestApply(t *testing.T) {
+	var commands [][]string
+	execCommand = recordExecCommand(&commands)
+	defer func() { execCommand = exec.Command }()
+
+	spec := []byte("---\nkind: Deployment\n")
+	selector := k8v1alpha1.CloudConfigAppLabel + "=cluster-alpha"
+	assert.NoError(t, apply("test", selector, "", &spec))
+	assert.NoError(t, apply("test", selector, "system:serviceaccount:test:deployer", &spec))
+	assert.NoError(t, apply("", "", "system:serviceaccount:test:deployer", &spec))
+	assert.Equal(t, [][]string{
+		{"kubectl", "--namespace=test", "apply", "--prune", "--selector=" + selector, "-f", "-"},
+		{"kubectl", "--namespace=test", "--as=system:serviceaccount:test:deployer", "apply", "--prune", "--selector=" + selector, "-f", "-"},
+		{"kubectl", "--as=system:serviceaccount:test:deployer", "apply", "-f", "-"},
+	}, commands, "the ServiceAccount should be impersonated when applying and pruning")
+}
+
+func TestDeleteObjects(t *testing.T) {
+	var commands [][]string
+	execCommand = recordExecCommand(&commands)
+	defer func() { execCommand = exec.Command }()
+
+	kinds := []string{"configmap", "deployment.v1.apps"}
+	selector := k8v1alpha1.CloudConfigAppLabel + "=cluster-alpha"
+	assert.NoError(t, deleteObjects("test", selector, "", kinds))
+	assert.NoError(t, deleteObjects("test", selector, "system:serviceaccount:test:deployer", kinds))
+	assert.NoError(t, deleteObjects("", k8v1alpha1.CloudConfigAppLabel+"=cluster-platform-quotas", "", kinds))
+	assert.Equal(t, [][]string{
+		{"kubectl", "--namespace=test", "delete", "configmap,deployment.v1.apps", "--selector=" + selector, "--ignore-not-found"},
+		{"kubectl", "--namespace=test", "--as=system:serviceaccount:test:deployer",
+			"delete", "configmap,deployment.v1.apps", "--selector=" + selector, "--ignore-not-found"},
+		{"kubectl", "delete", "configmap,deployment.v1.apps", "--selector=" + k8v1alpha1.CloudConfigAppLabel + "=cluster-platform-quotas",
+			"--ignore-not-found", "--all-namespaces"},
+	}, commands, "the ServiceAccount should be impersonated when deleting")
+}
+
+func TestGetPrunedKinds(t *testing.T) {
+	assert.Equal(t, []string{"clusterrole.v1.rbac.authorization.k8s.io", "configmap"},
+		getPrunedKinds([]string{"clusterrole.v1.rbac.authorization.k8s.io", "configmap", "namespace"}),
+		"namespaces should not be deleted with the app")
+	assert.Empty(t, getPrunedKinds([]string{"namespace"}))
+}
+
+// statusSubresourceClient updates CloudConfigApps like the API server with the status subresource enabled, the
+// status of the update is ignored and the stored status is returned
+type statusSubresourceClient struct {
+	client.Client
+}
+
+func (c statusSubresourceClient) Update(ctx context.Context, obj runtime.Object) error {
+	app, ok := obj.(*k8v1alpha1.CloudConfigApp)
+	if !ok {
+		return c.Client.Update(ctx, obj)
+	}
+	stored := &k8v1alpha1.CloudConfigApp{}
+	if err := c.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, stored); err != nil {
+		return err
+	}
+	app.Status = stored.Status
+	return c.Client.Update(ctx, app)
+}
+
+func TestAddFinalizer(t *testing.T) {
+	app := &k8v1alpha1.CloudConfigApp{ObjectMeta: metav1.ObjectMeta{Name: "test-alpha", Namespace: "test"}}
+	k8client := statusSubresourceClient{newFakeClient(t, app.DeepCopy())}
+	r := &ReconcileCloudConfigApp{client: k8client}
+
+	app.Status.OverriddenImages = []string{"nginx:1.17"}
+	app.Status.Rejected = []string{"ClusterRole/admin"}
+	app.Status.Violations = []string{"Deployment/alpha: container 'alpha' is privileged"}
+	assert.NoError(t, r.addFinalizer(app, pruneFinalizer))
+	assert.True(t, hasFinalizer(app, pruneFinalizer))
+	assert.Equal(t, []string{"nginx:1.17"}, app.Status.OverriddenImages, "the status of the synchronization should be kept")
+	assert.Equal(t, []string{"ClusterRole/admin"}, app.Status.Rejected)
+	assert.Equal(t, []string{"Deployment/alpha: container 'alpha' is privileged"}, app.Status.Violations)
+
+	stored := &k8v1alpha1.CloudConfigApp{}
+	assert.NoError(t, k8client.Get(context.TODO(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, stored))
+	assert.Equal(t, []string{pruneFinalizer}, stored.Finalizers)
+}
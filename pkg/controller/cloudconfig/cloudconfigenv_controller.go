@@ -1,11 +1,10 @@
 package cloudconfig
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os/exec"
+	"reflect"
 	"sort"
 	"strings"
 	"time"
@@ -15,14 +14,20 @@ import (
 	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -35,7 +40,11 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileCloudConfig{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileCloudConfig{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetRecorder("cloudconfig-controller"),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -47,17 +56,25 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	}
 
 	// Watch for changes to primary resource CloudConfig
-	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfig{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfig{}}, &handler.EnqueueRequestForObject{}, specChanged)
 	if err != nil {
 		return err
 	}
 
-	// TODO(user): Modify this to be the types you create that are owned by the primary resource
-	// Watch for changes to secondary resource Pods and requeue the owner CloudConfig
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
+	// Watch for changes to secondary resource CloudConfigApp and requeue the owner CloudConfig
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigApp{}}, &handler.EnqueueRequestForOwner{
 		IsController: true,
 		OwnerType:    &k8v1alpha1.CloudConfig{},
-	})
+	}, healthChanged)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource CloudConfigEnv and requeue the CloudConfig, which may
+	// be in another namespace than the CloudConfigEnv
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigEnv{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: mapEnvToCloudConfig(mgr.GetClient()),
+	}, healthChanged)
 	if err != nil {
 		return err
 	}
@@ -65,14 +82,77 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+// mapEnvToCloudConfig returns a mapper of a CloudConfigEnv to a request for the CloudConfig declaring its
+// environment. The labels of the CloudConfigEnv are not trusted as anyone allowed to create it can set them.
+func mapEnvToCloudConfig(k8client client.Client) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		env, ok := obj.Object.(*k8v1alpha1.CloudConfigEnv)
+		if !ok {
+			return nil
+		}
+		c, err := getEnvCloudConfig(k8client, env)
+		if err != nil {
+			log.Error(err, "Could not map the CloudConfigEnv to its CloudConfig", "Namespace", env.Namespace, "Name", env.Name)
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: c.Name, Namespace: c.Namespace}}}
+	}
+}
+
+// specChanged filters out updates that do not change the spec or the suspend and sync annotations of an object,
+// e.g. status updates
+var specChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+			controlAnnotationsChanged(e.MetaOld, e.MetaNew)
+	},
+}
+
+// healthChanged filters out updates that do not change the spec or the suspend and sync annotations of an object
+// nor the health or pending revisions of a CloudConfigApp or of the apps of a CloudConfigEnv
+var healthChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return specChanged.UpdateFunc(e) || getObjectHealth(e.ObjectOld) != getObjectHealth(e.ObjectNew) ||
+			getPendingRevisions(e.ObjectOld) != getPendingRevisions(e.ObjectNew)
+	},
+}
+
+// getPendingRevisions returns the revisions of a CloudConfigApp or of the apps of a CloudConfigEnv waiting for
+// approval
+func getPendingRevisions(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *k8v1alpha1.CloudConfigApp:
+		return o.Status.PendingRevision
+	case *k8v1alpha1.CloudConfigEnv:
+		revisions := make([]string, 0, len(o.Status.Apps))
+		for _, app := range o.Status.Apps {
+			revisions = append(revisions, app.PendingRevision)
+		}
+		return strings.Join(revisions, ",")
+	}
+	return ""
+}
+
+// getObjectHealth returns the health of a CloudConfigApp or the worst health of the apps of a CloudConfigEnv
+func getObjectHealth(obj runtime.Object) k8v1alpha1.HealthStatus {
+	switch o := obj.(type) {
+	case *k8v1alpha1.CloudConfigApp:
+		return o.Status.Health
+	case *k8v1alpha1.CloudConfigEnv:
+		return getAppsHealth(o.Status.Apps)
+	}
+	return ""
+}
+
 var _ reconcile.Reconciler = &ReconcileCloudConfig{}
 
 // ReconcileCloudConfig reconciles a CloudConfig object
 type ReconcileCloudConfig struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a CloudConfig object and makes changes based on the
@@ -99,165 +179,716 @@ func (r *ReconcileCloudConfig) Reconcile(request reconcile.Request) (reconcile.R
 		return reconcile.Result{}, err
 	}
 
+	if c.DeletionTimestamp != nil {
+		// Delete the CloudConfigEnvs that may not be garbage collected as they are in other namespaces
+		return reconcile.Result{}, r.finalize(c)
+	}
+
+	if suspended, err := r.suspend(c); suspended || err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// propagate sync requests and approvals to the CloudConfigEnvs and CloudConfigApps
+	for _, key := range []string{k8v1alpha1.SyncAnnotation, k8v1alpha1.ApproveAnnotation} {
+		if value := c.Annotations[key]; value != "" {
+			if err := r.annotateEnvs(c, key, value); err != nil {
+				return reconcile.Result{}, err
+			}
+			if err := annotateApps(r.client, c, newCloudConfigLabels(c), key, value); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	if len(c.Spec.Environments) > 0 && !hasFinalizer(c, environmentsFinalizer) {
+		c.Finalizers = append(c.Finalizers, environmentsFinalizer)
+		if err := r.client.Update(context.TODO(), c); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	instance := c
 	c = getEffectiveConfig(c)
 	if err = validate(&c.Spec); err != nil {
 		log.Error(err, "Validation failed")
+		setReadyCondition(&instance.Status, "ValidationFailed", err)
+		r.updateStatus(instance)
 		// Return and don't requeue
 		return reconcile.Result{}, nil
 	}
 
 	// Reconcile the CloudConfig
-	apps, err := r.reconcileApps(c)
-	if err != nil {
-		reqLogger.Error(err, "Reconciliation failed")
-	} else if len(apps) == 0 {
-		reqLogger.Info(fmt.Sprintf("Apps not found for field '%s' of app '%s'", c.Spec.AppList, c.Spec.AppName))
+	if len(c.Spec.Environments) > 0 {
+		var envs []k8v1alpha1.EnvironmentStatus
+		envs, err = r.reconcileEnvironments(c)
+		if err == nil {
+			// Delete the CloudConfigApps of a CloudConfig that previously did not define any environments
+			err = deleteRemovedApps(r.client, c, newCloudConfigLabels(c), nil)
+		}
+		if err != nil {
+			reqLogger.Error(err, "Reconciliation failed")
+		} else {
+			reqLogger.Info(fmt.Sprintf("Reconciled %d environment(s) in %v", len(envs), time.Since(start)))
+			instance.Status.Environments = envs
+			instance.Status.Apps = nil
+		}
 	} else {
-		reqLogger.Info(fmt.Sprintf("Reconciled %d app(s) %v in %v", len(apps), apps, time.Since(start)))
+		var apps []k8v1alpha1.AppStatus
+		apps, err = reconcileApps(r.client, r.scheme, c, c.Name, c.Namespace, &c.Spec, newCloudConfigLabels(c))
+		if err == nil {
+			// Delete the CloudConfigEnvs of a CloudConfig that no longer defines any environments
+			_, err = r.deleteRemovedEnvs(c, nil)
+		}
+		if err != nil {
+			reqLogger.Error(err, "Reconciliation failed")
+		} else if len(apps) == 0 {
+			reqLogger.Info(fmt.Sprintf("Apps not found for field '%s' of app '%s'", c.Spec.AppList, c.Spec.AppName))
+		} else {
+			reqLogger.Info(fmt.Sprintf("Reconciled %d app(s) %v in %v", len(apps), getAppNames(apps), time.Since(start)))
+		}
+
+		if apps != nil {
+			instance.Status.Apps = apps
+			instance.Status.Environments = nil
+		}
+	}
+
+	next, nextErr := getNextSync(&c.Spec, start)
+	if nextErr != nil {
+		reqLogger.Error(nextErr, "Could not schedule the next reconciliation")
 	}
+	instance.Status.NextSync = newTime(next)
+	setReadyCondition(&instance.Status, "ReconciliationFailed", err)
+	r.updateStatus(instance)
 
-	// check if this is a one-off reconciliation
-	if c.Spec.Period <= 0 {
+	if next.IsZero() {
 		reqLogger.Info("Reconciled CloudConfig; no rescheduling")
 		// Don't reschedule as this is a one-off reconciliation
 		return reconcile.Result{}, nil
 	}
-
-	// reschedule the next reconciliation cycle
-	next, skipped := c.GetDurationUntilNextCycle(start)
-	if skipped {
-		reqLogger.Info("Skipping one or more cycles as reconciliation took too long, consider a prolonging the period!")
-	}
-	reqLogger.Info(fmt.Sprintf("Reconciled CloudConfig; rescheduling in %v", next))
-	return reconcile.Result{Requeue: true, RequeueAfter: next}, nil
+	reqLogger.Info(fmt.Sprintf("Reconciled CloudConfig; rescheduling at %v", next))
+	return requeueAt(next), nil
 }
 
 func getEffectiveConfig(c *k8v1alpha1.CloudConfig) *k8v1alpha1.CloudConfig {
 	eff := c.DeepCopy()
+	eff.Spec = *getEffectiveSpec(&c.Spec, c.ObjectMeta.Name)
+	return eff
+}
+
+// getEffectiveSpec returns a copy of the spec with defaults for properties that are not specified
+func getEffectiveSpec(spec *k8v1alpha1.CloudConfigSpec, name string) *k8v1alpha1.CloudConfigSpec {
+	eff := spec.DeepCopy()
 
 	// merge defaults for properties that are not specified
-	mergo.Merge(&eff.Spec, k8v1alpha1.NewCloudConfigSpec())
+	mergo.Merge(eff, k8v1alpha1.NewCloudConfigSpec())
 	// special rule for `Period` as ints are not merged
-	if eff.Spec.Period == 0 {
-		eff.Spec.Period = c.Spec.Period
+	if eff.Period == 0 {
+		eff.Period = spec.Period
 	}
 
-	fallBackIfEmpty(&eff.Spec.AppName, c.ObjectMeta.Name)
+	fallBackIfEmpty(&eff.AppName, name)
 
 	return eff
 }
 
-func (r *ReconcileCloudConfig) reconcileApps(c *k8v1alpha1.CloudConfig) ([]string, error) {
-	client, err := r.createClient(c)
+// suspend propagates the suspension of the CloudConfig to its CloudConfigEnvs and CloudConfigApps, or resumes
+// them, and records the Suspended condition. The result is true if the CloudConfig is suspended, in which case
+// the status of the last synchronization is kept.
+func (r *ReconcileCloudConfig) suspend(c *k8v1alpha1.CloudConfig) (bool, error) {
+	reason := getSuspendReason(c, c.Spec.Suspend)
+	suspendedBy := ""
+	if reason != "" {
+		suspendedBy = getSuspendedBy("CloudConfig", c)
+	}
+	if err := r.annotateEnvs(c, k8v1alpha1.SuspendedByAnnotation, suspendedBy); err != nil {
+		return false, err
+	}
+	if err := annotateApps(r.client, c, newCloudConfigLabels(c), k8v1alpha1.SuspendedByAnnotation, suspendedBy); err != nil {
+		return false, err
+	}
+
+	recordSuspension(r.recorder, c, &c.Status.Conditions, reason)
+	if reason == "" {
+		return false, nil
+	}
+
+	log.Info("CloudConfig is suspended; no synchronization", "Namespace", c.Namespace, "Name", c.Name, "Reason", reason)
+	r.updateStatus(c)
+	return true, nil
+}
+
+// updateStatus updates the status of the CloudConfig
+func (r *ReconcileCloudConfig) updateStatus(c *k8v1alpha1.CloudConfig) {
+	if err := r.client.Status().Update(context.TODO(), c); err != nil {
+		log.Error(err, "Could not update the CloudConfig status", "Namespace", c.Namespace, "Name", c.Name)
+	}
+}
+
+// setReadyCondition sets the Ready condition of the CloudConfig status from the health of its apps or environments,
+// cf. setReady
+func setReadyCondition(status *k8v1alpha1.CloudConfigStatus, reason string, err error) {
+	health, message := getStatusHealth(status.Apps, status.Environments)
+	setReady(&status.Conditions, &status.LastSync, health, message, reason, err)
+}
+
+// setReady sets the Ready condition to False with the reason if the reconciliation failed. If it succeeded the last
+// sync time is set to now and the Ready condition to True if the apps are healthy or to False with their health as
+// reason.
+func setReady(
+	conditions *[]k8v1alpha1.Condition,
+	lastSync **metav1.Time,
+	health k8v1alpha1.HealthStatus,
+	message, reason string,
+	err error) {
+
+	if err != nil {
+		k8v1alpha1.SetCondition(conditions, k8v1alpha1.Condition{
+			Type:    k8v1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	now := metav1.Now()
+	*lastSync = &now
+	if health != k8v1alpha1.HealthHealthy {
+		k8v1alpha1.SetCondition(conditions, k8v1alpha1.Condition{
+			Type:    k8v1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  string(health),
+			Message: message,
+		})
+		return
+	}
+	k8v1alpha1.SetCondition(conditions, k8v1alpha1.Condition{
+		Type:   k8v1alpha1.ConditionReady,
+		Status: corev1.ConditionTrue,
+		Reason: "Reconciled",
+	})
+}
+
+// appOwner is the CloudConfig, CloudConfigEnv or ClusterCloudConfig owning a CloudConfigApp
+type appOwner interface {
+	metav1.Object
+	runtime.Object
+}
+
+// newCloudConfigLabels returns the labels of the CloudConfigApps and CloudConfigEnvs of a CloudConfig
+func newCloudConfigLabels(c *k8v1alpha1.CloudConfig) map[string]string {
+	return map[string]string{
+		k8v1alpha1.CloudConfigLabel:          c.Name,
+		k8v1alpha1.CloudConfigNamespaceLabel: c.Namespace,
+	}
+}
+
+// reconcileApps resolves the apps of the spec and maintains one CloudConfigApp for each app in the namespace, named
+// after the name and the app. CloudConfigApps of apps that have been removed are deleted.
+func reconcileApps(
+	k8client client.Client,
+	scheme *runtime.Scheme,
+	owner appOwner,
+	name, namespace string,
+	spec *k8v1alpha1.CloudConfigSpec,
+	labels map[string]string) ([]k8v1alpha1.AppStatus, error) {
+
+	configNamespace, err := getConfigNamespace(k8client, owner)
+	if err != nil {
+		return nil, err
+	}
+	client, err := createClient(k8client, configNamespace, spec)
 	if err != nil {
 		return nil, err
 	}
 
-	var apps []string
-	if c.Spec.AppList == "" {
-		// Synchronize a single app
-		apps = []string{c.Spec.AppName}
-	} else {
-		// Synchronize the apps found in the AppList field of the AppName app
-		apps, err = client.getApps(c.Spec.AppList, c.Spec.AppName, c.Spec.Label, c.Spec.Profile...)
-		if err != nil {
-			return nil, err
-		}
-		// Order alphabetically to maintain consistency when applying the CloudConfig
-		sort.Strings(apps)
+	apps, dependencies, err := resolveApps(client, spec)
+	if err != nil {
+		return nil, err
 	}
 
-	// concatenate all app files into one configuration for the entire namespace
-	spec := make([]byte, 0, 1024)
+	// maintain one CloudConfigApp for each app
+	names := make(map[string]bool, len(apps))
+	status := make([]k8v1alpha1.AppStatus, 0, len(apps))
 	for _, app := range apps {
-		file, err := client.GetConfigFile(c.Spec.SpecFile, app, c.Spec.Label, c.Spec.Profile...)
-		if err != nil {
+		child := newCloudConfigApp(name, namespace, app, labels)
+		child.Spec.Dependencies = dependencies[app.AppName]
+		if err := controllerutil.SetControllerReference(owner, child, scheme); err != nil {
 			return nil, err
 		}
-		// TODO ensure that the file is valid YAML before appending it to the spec
-		spec = appendYAMLDoc(app, spec, file)
+		if err := createOrUpdateApp(k8client, owner, child); err != nil {
+			return nil, err
+		}
+		names[child.Name] = true
+		appStatus := k8v1alpha1.NewAppStatus(app)
+		appStatus.Health = getAssessedHealth(child.Status.Health)
+		appStatus.DependsOn = dependencies[app.AppName]
+		appStatus.PendingRevision = child.Status.PendingRevision
+		status = append(status, appStatus)
 	}
 
-	if len(spec) > 0 {
-		err = r.apply(c.Namespace, &spec)
-		if err != nil {
+	if err := deleteRemovedApps(k8client, owner, labels, names); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// newCloudConfigApp returns the CloudConfigApp in the namespace for the effective app spec, named after the name and
+// the app
+func newCloudConfigApp(name, namespace string, spec *k8v1alpha1.CloudConfigSpec, labels map[string]string) *k8v1alpha1.CloudConfigApp {
+	appLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		appLabels[k] = v
+	}
+	appLabels[k8v1alpha1.AppLabel] = spec.AppName
+
+	return &k8v1alpha1.CloudConfigApp{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8v1alpha1.GetCloudConfigAppName(name, spec.AppName),
+			Namespace: namespace,
+			Labels:    appLabels,
+		},
+		Spec: k8v1alpha1.CloudConfigAppSpec{
+			CloudConfigSpec: *spec,
+		},
+	}
+}
+
+// createOrUpdateApp creates the CloudConfigApp if it does not exist or updates its spec if it has
+// changed. The suspend flag of an existing CloudConfigApp is retained and its status is copied to the app.
+// An existing CloudConfigApp not controlled by the owner is never adopted: app names join the names of the
+// owner and the app, so `a-b`/`c` and `a`/`b-c` collide.
+func createOrUpdateApp(k8client client.Client, owner metav1.Object, app *k8v1alpha1.CloudConfigApp) error {
+	existing := &k8v1alpha1.CloudConfigApp{}
+	name := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	if err := k8client.Get(context.TODO(), name, existing); err != nil {
+		if k8errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("Creating CloudConfigApp '%s'", app.Name), "Namespace", app.Namespace)
+			return k8client.Create(context.TODO(), app)
+		}
+		return err
+	}
+	if !metav1.IsControlledBy(existing, owner) {
+		return fmt.Errorf("CloudConfigApp '%s' in namespace '%s' is not controlled by '%s'",
+			app.Name, app.Namespace, owner.GetName())
+	}
+
+	app.Spec.Suspend = existing.Spec.Suspend
+	app.Status = existing.Status
+	if reflect.DeepEqual(existing.Spec, app.Spec) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Updating CloudConfigApp '%s'", app.Name), "Namespace", app.Namespace)
+	existing.Spec = app.Spec
+	return k8client.Update(context.TODO(), existing)
+}
+
+// deleteRemovedApps deletes the CloudConfigApps controlled by the owner that are not among the named
+// apps. Kubernetes objects owned by the deleted apps are garbage collected.
+func deleteRemovedApps(k8client client.Client, owner metav1.Object, labels map[string]string, names map[string]bool) error {
+	apps := &k8v1alpha1.CloudConfigAppList{}
+	opts := client.InNamespace(owner.GetNamespace()).MatchingLabels(labels)
+	if err := k8client.List(context.TODO(), opts, apps); err != nil {
+		return err
+	}
+
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if names[app.Name] || !metav1.IsControlledBy(app, owner) {
+			continue
+		}
+		log.Info(fmt.Sprintf("Deleting CloudConfigApp '%s'", app.Name), "Namespace", app.Namespace)
+		err := k8client.Delete(context.TODO(), app, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+const environmentsFinalizer = "environments.k8s.jabberwocky.se"
+
+// reconcileEnvironments maintains one CloudConfigEnv for each environment of the CloudConfig. CloudConfigEnvs of
+// environments that have been removed are deleted.
+func (r *ReconcileCloudConfig) reconcileEnvironments(c *k8v1alpha1.CloudConfig) ([]k8v1alpha1.EnvironmentStatus, error) {
+	keys := make([]string, 0, len(c.Spec.Environments))
+	for key := range c.Spec.Environments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := make(map[types.NamespacedName]bool, len(keys))
+	status := make([]k8v1alpha1.EnvironmentStatus, 0, len(keys))
+	user := getServiceAccountUser(c.Namespace, &c.Spec)
+	for _, key := range keys {
+		env := newCloudConfigEnv(c, key)
+		if err := ensureTargetNamespace(r.client, c.Namespace, env.Namespace, user, &c.Spec); err != nil {
 			return nil, err
 		}
+		// owner references cannot cross namespaces, CloudConfigEnvs in other namespaces are deleted by the finalizer
+		if env.Namespace == c.Namespace {
+			if err := controllerutil.SetControllerReference(c, env, r.scheme); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.createOrUpdateEnv(env); err != nil {
+			return nil, err
+		}
+		names[types.NamespacedName{Name: env.Name, Namespace: env.Namespace}] = true
+		status = append(status, k8v1alpha1.EnvironmentStatus{
+			Name:           key,
+			CloudConfigEnv: env.Name,
+			Namespace:      env.Namespace,
+			Label:          env.Spec.Label,
+			Profiles:       env.Spec.Profile,
+			Health:         getAppsHealth(env.Status.Apps),
+		})
+	}
+
+	if _, err := r.deleteRemovedEnvs(c, names); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// newCloudConfigEnv returns the CloudConfigEnv of the keyed environment. The CloudConfigEnv is created in
+// the environment's namespace, defaulting to the namespace of the CloudConfig.
+func newCloudConfigEnv(c *k8v1alpha1.CloudConfig, key string) *k8v1alpha1.CloudConfigEnv {
+	env := c.Spec.Environments[key]
+	namespace := env.Namespace
+	fallBackIfEmpty(&namespace, c.Namespace)
+
+	labels := newCloudConfigLabels(c)
+	labels[k8v1alpha1.EnvLabel] = key
+
+	return &k8v1alpha1.CloudConfigEnv{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      k8v1alpha1.GetCloudConfigEnvName(c.Name, key),
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: k8v1alpha1.CloudConfigEnvSpec{
+			CloudConfigSpec: *c.Spec.GetEnvironmentSpec(env),
+		},
+	}
+}
+
+// createOrUpdateEnv creates the CloudConfigEnv if it does not exist or updates its spec if it has changed. The
+// status of an existing CloudConfigEnv is copied to the environment.
+func (r *ReconcileCloudConfig) createOrUpdateEnv(env *k8v1alpha1.CloudConfigEnv) error {
+	existing := &k8v1alpha1.CloudConfigEnv{}
+	name := types.NamespacedName{Name: env.Name, Namespace: env.Namespace}
+	if err := r.client.Get(context.TODO(), name, existing); err != nil {
+		if k8errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("Creating CloudConfigEnv '%s'", env.Name), "Namespace", env.Namespace)
+			return r.client.Create(context.TODO(), env)
+		}
+		return err
+	}
+
+	env.Status = existing.Status
+	if reflect.DeepEqual(existing.Spec, env.Spec) {
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("Updating CloudConfigEnv '%s'", env.Name), "Namespace", env.Namespace)
+	existing.Spec = env.Spec
+	return r.client.Update(context.TODO(), existing)
+}
+
+// deleteRemovedEnvs deletes the CloudConfigEnvs of the CloudConfig in all namespaces that are not among the
+// named environments. All CloudConfigEnvs are deleted if names is nil. The CloudConfigEnvs are deleted in the
+// foreground, i.e. they are kept until their CloudConfigApps have been finalized with the ServiceAccount of the
+// CloudConfig; the result is true if any CloudConfigEnv is being deleted.
+func (r *ReconcileCloudConfig) deleteRemovedEnvs(c *k8v1alpha1.CloudConfig, names map[types.NamespacedName]bool) (bool, error) {
+	envs := &k8v1alpha1.CloudConfigEnvList{}
+	opts := (&client.ListOptions{}).MatchingLabels(newCloudConfigLabels(c))
+	if err := r.client.List(context.TODO(), opts, envs); err != nil {
+		return false, err
+	}
+
+	deleting := false
+	for i := range envs.Items {
+		env := &envs.Items[i]
+		if names[types.NamespacedName{Name: env.Name, Namespace: env.Namespace}] {
+			continue
+		}
+		deleting = true
+		if env.DeletionTimestamp != nil {
+			continue
+		}
+		log.Info(fmt.Sprintf("Deleting CloudConfigEnv '%s'", env.Name), "Namespace", env.Namespace)
+		err := r.client.Delete(context.TODO(), env, client.PropagationPolicy(metav1.DeletePropagationForeground))
+		if err != nil && !k8errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return deleting, nil
+}
+
+// finalize deletes the CloudConfigEnvs of a deleted CloudConfig and removes the environments finalizer once they
+// are gone, so that the CloudConfig still identifies the ServiceAccount of their apps while they are finalized.
+// The CloudConfig is reconciled again when its CloudConfigEnvs are deleted.
+func (r *ReconcileCloudConfig) finalize(c *k8v1alpha1.CloudConfig) error {
+	if !hasFinalizer(c, environmentsFinalizer) {
+		return nil
+	}
+	if deleting, err := r.deleteRemovedEnvs(c, nil); deleting || err != nil {
+		return err
+	}
+
+	finalizers := make([]string, 0, len(c.Finalizers))
+	for _, f := range c.Finalizers {
+		if f != environmentsFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	c.Finalizers = finalizers
+	return r.client.Update(context.TODO(), c)
+}
+
+func hasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
 	}
-	return apps, nil
+	return false
 }
 
-var execCommand = exec.Command
+// AddCloudConfigEnv creates a new CloudConfigEnv Controller and adds it to the Manager. The Manager will set fields
+// on the Controller and Start it when the Manager is Started.
+func AddCloudConfigEnv(mgr manager.Manager) error {
+	return addCloudConfigEnv(mgr, &ReconcileCloudConfigEnv{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetRecorder("cloudconfigenv-controller"),
+	})
+}
 
-func (r *ReconcileCloudConfig) apply(namespace string, spec *[]byte) error {
-	cmd := execCommand(
-		"kubectl",
-		"--namespace="+namespace,
-		"apply",
-		"--prune",
-		"--all",
-		"-f",
-		"-")
+// addCloudConfigEnv adds a new Controller to mgr with r as the reconcile.Reconciler
+func addCloudConfigEnv(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("cloudconfigenv-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
 
-	cmd.Stdin = bytes.NewReader(*spec)
-	log.Info(strings.Join(cmd.Args, " "))
-	var out []byte
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Error(err, fmt.Sprintf("Could not apply spec for '%s'", namespace),
-			"command", strings.Join(cmd.Args, " "),
-			"output", string(out))
+	// Watch for changes to primary resource CloudConfigEnv
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigEnv{}}, &handler.EnqueueRequestForObject{}, specChanged)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource CloudConfigApp and requeue the owner CloudConfigEnv
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigApp{}}, &handler.EnqueueRequestForOwner{
+		IsController: true,
+		OwnerType:    &k8v1alpha1.CloudConfigEnv{},
+	}, healthChanged)
+	if err != nil {
 		return err
 	}
 
-	log.Info(fmt.Sprintf("Applied spec for '%s'", namespace),
-		"command", strings.Join(cmd.Args, " "),
-		"output", string(out))
 	return nil
 }
 
-func (r *ReconcileCloudConfig) createClient(c *k8v1alpha1.CloudConfig) (*CloudConfigClient, error) {
+var _ reconcile.Reconciler = &ReconcileCloudConfigEnv{}
+
+// ReconcileCloudConfigEnv reconciles a CloudConfigEnv object
+type ReconcileCloudConfigEnv struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+}
+
+// Reconcile resolves the apps of a CloudConfigEnv and maintains one CloudConfigApp for each app in the
+// CloudConfigEnv's namespace.
+func (r *ReconcileCloudConfigEnv) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	start := time.Now()
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling CloudConfigEnv")
+
+	// Fetch the CloudConfigEnv instance
+	env := &k8v1alpha1.CloudConfigEnv{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, env)
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			// Owned objects are automatically garbage collected
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	// propagate the suspension of the CloudConfigEnv to its CloudConfigApps
+	suspendedBy := ""
+	reason := getSuspendReason(env, env.Spec.Suspend)
+	if reason != "" {
+		suspendedBy = getSuspendedBy("CloudConfigEnv", env)
+	}
+	if err := annotateApps(r.client, env, env.Labels, k8v1alpha1.SuspendedByAnnotation, suspendedBy); err != nil {
+		return reconcile.Result{}, err
+	}
+	if reason != "" {
+		reqLogger.Info("CloudConfigEnv is suspended; no synchronization", "Reason", reason)
+		return reconcile.Result{}, nil
+	}
+
+	// propagate sync requests and approvals to the CloudConfigApps
+	for _, key := range []string{k8v1alpha1.SyncAnnotation, k8v1alpha1.ApproveAnnotation} {
+		if value := env.Annotations[key]; value != "" {
+			if err := annotateApps(r.client, env, env.Labels, key, value); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	spec := getEffectiveSpec(&env.Spec.CloudConfigSpec, env.Name)
+	if err = validate(spec); err != nil {
+		log.Error(err, "Validation failed")
+		if err.Error() != env.Status.Error {
+			r.recorder.Event(env, corev1.EventTypeWarning, "ValidationFailed", err.Error())
+		}
+		env.Status.Error = err.Error()
+		r.updateStatus(env)
+		// Return and don't requeue, the environment is reconciled again when its spec changes
+		return reconcile.Result{}, nil
+	}
+
+	apps, err := reconcileApps(r.client, r.scheme, env, env.Name, env.Namespace, spec, env.Labels)
+	if err != nil {
+		reqLogger.Error(err, "Reconciliation failed")
+		env.Status.Error = err.Error()
+	} else {
+		reqLogger.Info(fmt.Sprintf("Reconciled %d app(s) %v in %v", len(apps), getAppNames(apps), time.Since(start)))
+		env.Status.Apps = apps
+		env.Status.Error = ""
+	}
+	r.updateStatus(env)
+
+	next, err := getNextSync(spec, start)
+	if err != nil {
+		reqLogger.Error(err, "Could not schedule the next reconciliation")
+	}
+	if next.IsZero() {
+		reqLogger.Info("Reconciled CloudConfigEnv; no rescheduling")
+		return reconcile.Result{}, nil
+	}
+	reqLogger.Info(fmt.Sprintf("Reconciled CloudConfigEnv; rescheduling at %v", next))
+	return requeueAt(next), nil
+}
+
+// updateStatus updates the status of the CloudConfigEnv
+func (r *ReconcileCloudConfigEnv) updateStatus(env *k8v1alpha1.CloudConfigEnv) {
+	if err := r.client.Status().Update(context.TODO(), env); err != nil {
+		log.Error(err, "Could not update the CloudConfigEnv status", "Namespace", env.Namespace, "Name", env.Name)
+	}
+}
+
+// resolveApps returns the effective spec of each app ordered alphabetically by app name and by the dependencies
+// between the apps of the app list, together with the apps each app depends on
+func resolveApps(
+	client *CloudConfigClient,
+	spec *k8v1alpha1.CloudConfigSpec) ([]*k8v1alpha1.CloudConfigSpec, map[string][]string, error) {
+
+	var apps []k8v1alpha1.AppSpec
+	if spec.AppList == "" {
+		// Synchronize a single app
+		apps = []k8v1alpha1.AppSpec{{Name: spec.AppName}}
+	} else {
+		// Synchronize the apps found in the AppList field of the AppName app
+		var err error
+		apps, err = client.getApps(spec.AppList, spec.AppName, spec.Label, spec.Profile...)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Order alphabetically to maintain consistency when applying the CloudConfig
+		sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	}
+
+	specs := make([]*k8v1alpha1.CloudConfigSpec, len(apps))
+	for i, app := range apps {
+		if override, ok := spec.Apps[app.Name]; ok {
+			app = app.Merge(override)
+		}
+		specs[i] = spec.GetAppSpec(app)
+	}
+
+	if spec.AppList == "" {
+		return specs, nil, nil
+	}
+
+	// Apply the apps in dependency order, apps that do not depend on each other remain in alphabetical order
+	dependencies, err := getDependencies(client, specs)
+	if err != nil {
+		return nil, nil, err
+	}
+	specs, err = sortByDependencies(specs, dependencies)
+	if err != nil {
+		return nil, nil, err
+	}
+	return specs, dependencies, nil
+}
+
+func getAppNames(apps []k8v1alpha1.AppStatus) []string {
+	names := make([]string, len(apps))
+	for i, app := range apps {
+		names[i] = app.Name
+	}
+	return names
+}
+
+// createClient creates a CloudConfigClient for the spec using the credentials and trust store secrets
+// found in the namespace
+func createClient(k8client client.Client, namespace string, spec *k8v1alpha1.CloudConfigSpec) (*CloudConfigClient, error) {
 
 	opts := make([]func(*CloudConfigClient), 0, 10)
 	var err error
 
-	if opts, err = r.appendCredentialsOptions(opts, c); err != nil {
+	if opts, err = appendCredentialsOptions(k8client, opts, namespace, spec); err != nil {
 		return nil, err
 	}
 
-	if opts, err = r.configureTrustStore(opts, c); err != nil {
+	if opts, err = configureTrustStore(k8client, opts, namespace, spec); err != nil {
 		return nil, err
 	}
 
-	if c.Spec.Insecure {
+	if spec.Insecure {
 		opts = append(opts, Insecure())
 	}
 
-	return New(c.Spec.Server, opts...)
+	return New(spec.Server, opts...)
 }
 
-func (r *ReconcileCloudConfig) configureTrustStore(
+func configureTrustStore(
+	k8client client.Client,
 	opts []func(*CloudConfigClient),
-	c *k8v1alpha1.CloudConfig) ([]func(*CloudConfigClient), error) {
+	namespace string,
+	spec *k8v1alpha1.CloudConfigSpec) ([]func(*CloudConfigClient), error) {
 
-	if c.Spec.TrustStore == "" {
+	if spec.TrustStore == "" {
 		return opts, nil
 	}
 
 	secret := &corev1.Secret{}
-	name := types.NamespacedName{Name: c.Spec.TrustStore, Namespace: c.Namespace}
-	if err := r.client.Get(context.TODO(), name, secret); err != nil {
+	name := types.NamespacedName{Name: spec.TrustStore, Namespace: namespace}
+	if err := k8client.Get(context.TODO(), name, secret); err != nil {
 		return nil, err
 	}
 
 	return append(opts, TrustStore(secret.Data)), nil
 }
 
-func (r *ReconcileCloudConfig) appendCredentialsOptions(
+func appendCredentialsOptions(
+	k8client client.Client,
 	opts []func(*CloudConfigClient),
-	c *k8v1alpha1.CloudConfig) ([]func(*CloudConfigClient), error) {
+	namespace string,
+	spec *k8v1alpha1.CloudConfigSpec) ([]func(*CloudConfigClient), error) {
 
 	var err error
-	cr := &c.Spec.Credentials
+	cr := &spec.Credentials
 
 	// Configure credentials only if secret has been set
 	if cr.Secret == "" {
@@ -265,8 +896,8 @@ func (r *ReconcileCloudConfig) appendCredentialsOptions(
 	}
 
 	secret := &corev1.Secret{}
-	name := types.NamespacedName{Name: cr.Secret, Namespace: c.Namespace}
-	if err := r.client.Get(context.TODO(), name, secret); err != nil {
+	name := types.NamespacedName{Name: cr.Secret, Namespace: namespace}
+	if err := k8client.Get(context.TODO(), name, secret); err != nil {
 		return nil, err
 	}
 
@@ -367,12 +998,39 @@ func validate(spec *k8v1alpha1.CloudConfigSpec) error {
 		validationErrors = append(validationErrors, fieldErr)
 	}
 
-	if spec.SpecFile == "" {
+	if len(spec.GetSpecFiles()) == 0 {
 		path := field.NewPath("specFile")
 		fieldErr := field.Invalid(path, spec.SpecFile, "specfile must be specified")
 		validationErrors = append(validationErrors, fieldErr)
 	}
 
+	if spec.SpecManifest == "" && hasSpecFilePatterns(spec.SpecFiles) {
+		path := field.NewPath("specManifest")
+		fieldErr := field.Required(path, "specManifest must be specified for specFiles patterns")
+		validationErrors = append(validationErrors, fieldErr)
+	}
+
+	if spec.TargetNamespace != "" {
+		for _, msg := range validation.IsDNS1123Label(spec.TargetNamespace) {
+			path := field.NewPath("targetNamespace")
+			fieldErr := field.Invalid(path, spec.TargetNamespace, msg)
+			validationErrors = append(validationErrors, fieldErr)
+		}
+	}
+
+	if spec.ServiceAccountName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.ServiceAccountName) {
+			path := field.NewPath("serviceAccountName")
+			fieldErr := field.Invalid(path, spec.ServiceAccountName, msg)
+			validationErrors = append(validationErrors, fieldErr)
+		}
+	}
+
+	validationErrors = append(validationErrors, validateSchedule(spec)...)
+	validationErrors = append(validationErrors, validateKinds(spec)...)
+	validationErrors = append(validationErrors, validateMetadata(spec)...)
+	validationErrors = append(validationErrors, validateImages(spec)...)
+
 	if len(validationErrors) > 0 {
 		// TODO add CloudConfigSpec's group and kind to groupKind instance
 		groupKind := schema.GroupKind{}
@@ -2,13 +2,22 @@ package cloudconfig
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"testing"
 
 	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	httpmock "gopkg.in/jarcoal/httpmock.v1"
 	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // TODO move to cloudconfg_test.go
@@ -147,4 +156,192 @@ func TestValidation(t *testing.T) {
 	assert.NoError(t, validate(&spec), "A secure Config Server URL should not provoke an error")
 	spec.Insecure = false
 	assert.NoError(t, validate(&spec), "A secure Config Server URL should not provoke an error")
+
+	spec.SpecFiles = []string{"deployment.yaml", "optional:*.yaml"}
+	assert.Error(t, validate(&spec), "specFiles patterns require a specManifest")
+
+	spec.SpecManifest = "kubernetes.files"
+	assert.NoError(t, validate(&spec), "specFiles patterns should be allowed with a specManifest")
+
+	spec.TargetNamespace = "Not_A_Namespace"
+	assert.Error(t, validate(&spec), "the targetNamespace must be a valid namespace name")
+
+	spec.TargetNamespace = "apps"
+	assert.NoError(t, validate(&spec), "a valid targetNamespace should not provoke an error")
+}
+
+func TestResolveApps(t *testing.T) {
+	mockHTTPClientFactory()
+	defer restoreDefaultHTTPClientFactory()
+
+	httpmock.RegisterResponder(
+		"GET", TestBaseURL+"master/cluster-dev.json", httpmock.NewStringResponder(
+			200, `{"services": [ "beta", { "name": "alpha", "label": "release-1.0" } ]}`))
+
+	client, _ := New(TestBaseURL)
+	spec := k8v1alpha1.NewCloudConfigSpec()
+	spec.AppName = "cluster"
+	spec.AppList = "services"
+	spec.Profile = []string{"dev"}
+	spec.Apps = map[string]k8v1alpha1.AppSpec{
+		"beta": {Profiles: []string{"canary"}, SpecFile: "canary.yaml"},
+	}
+
+	apps, _, err := resolveApps(client, spec)
+	assert.NoError(t, err)
+	assert.Len(t, apps, 2)
+
+	assert.Equal(t, k8v1alpha1.AppStatus{
+		Name:      "alpha",
+		Label:     "release-1.0",
+		Profiles:  []string{"dev"},
+		SpecFiles: []string{"deployment.yaml"},
+	}, k8v1alpha1.NewAppStatus(apps[0]), "apps should be ordered alphabetically with config overrides")
+
+	assert.Equal(t, k8v1alpha1.AppStatus{
+		Name:      "beta",
+		Label:     "master",
+		Profiles:  []string{"dev", "canary"},
+		SpecFiles: []string{"canary.yaml"},
+	}, k8v1alpha1.NewAppStatus(apps[1]), "CloudConfig app overrides should be applied")
+
+	spec.AppList = ""
+	apps, _, err = resolveApps(client, spec)
+	assert.NoError(t, err)
+	assert.Len(t, apps, 1)
+	assert.Equal(t, "cluster", apps[0].AppName, "the AppName should be used if there is no appList")
+}
+
+func TestNewCloudConfigEnv(t *testing.T) {
+	c := &k8v1alpha1.CloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "config"},
+		Spec: k8v1alpha1.CloudConfigSpec{
+			Label:   "master",
+			Profile: []string{"us-west"},
+			Environments: map[string]k8v1alpha1.EnvironmentSpec{
+				"dev": {Profile: []string{"dev"}, Label: "develop", Namespace: "dev"},
+				"prd": {Profile: []string{"prd"}},
+			},
+		},
+	}
+
+	env := newCloudConfigEnv(c, "dev")
+	assert.Equal(t, "cluster-dev", env.Name)
+	assert.Equal(t, "dev", env.Namespace)
+	assert.Equal(t, map[string]string{
+		k8v1alpha1.CloudConfigLabel:          "cluster",
+		k8v1alpha1.CloudConfigNamespaceLabel: "config",
+		k8v1alpha1.EnvLabel:                  "dev",
+	}, env.Labels)
+	assert.Equal(t, "develop", env.Spec.Label)
+	assert.Equal(t, []string{"us-west", "dev"}, env.Spec.Profile)
+	assert.Nil(t, env.Spec.Environments)
+
+	env = newCloudConfigEnv(c, "prd")
+	assert.Equal(t, "config", env.Namespace, "the namespace should default to the CloudConfig namespace")
+	assert.Equal(t, "master", env.Spec.Label)
+}
+
+func TestMapEnvToCloudConfig(t *testing.T) {
+	c, env, _ := newTeamCloudConfig(t)
+	mapper := mapEnvToCloudConfig(newFakeClient(t, c))
+	requests := mapper(handler.MapObject{Meta: env, Object: env})
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "cluster", Namespace: "ops"}},
+	}, requests)
+
+	// the labels of a CloudConfigEnv not declared by the CloudConfig are ignored
+	env.Name = "cluster-test"
+	assert.Empty(t, mapper(handler.MapObject{Meta: env, Object: env}))
+}
+
+func TestFinalizeWaitsForEnvironments(t *testing.T) {
+	c, env, _ := newTeamCloudConfig(t)
+	c.Finalizers = []string{environmentsFinalizer}
+	k8client := newFakeClient(t, c, env)
+	r := &ReconcileCloudConfig{client: k8client, scheme: newTestScheme(t)}
+
+	assert.NoError(t, r.finalize(c))
+	assert.True(t, hasFinalizer(c, environmentsFinalizer),
+		"the finalizer should be kept until the CloudConfigEnvs are gone so that their apps can be finalized")
+	err := k8client.Get(context.TODO(), types.NamespacedName{Name: env.Name, Namespace: env.Namespace}, env)
+	assert.True(t, k8errors.IsNotFound(err), "the CloudConfigEnv should be deleted")
+
+	assert.NoError(t, r.finalize(c))
+	assert.False(t, hasFinalizer(c, environmentsFinalizer))
+}
+
+func TestCreateOrUpdateAppRefusesCollidingApp(t *testing.T) {
+	c, env, app := newTeamCloudConfig(t)
+	k8client := newFakeClient(t, env, app)
+	scheme := newTestScheme(t)
+
+	// the app 'dev-alpha' of the environment 'cluster' is named like the app 'alpha' of 'cluster-dev'
+	other := env.DeepCopy()
+	other.Name = "cluster"
+	other.UID = "other-uid"
+	child := newCloudConfigApp(other.Name, other.Namespace, c.Spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "dev-alpha"}), other.Labels)
+	if err := controllerutil.SetControllerReference(other, child, scheme); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, app.Name, child.Name)
+	assert.EqualError(t, createOrUpdateApp(k8client, other, child),
+		"CloudConfigApp 'cluster-dev-alpha' in namespace 'team-dev' is not controlled by 'cluster'")
+
+	existing := &k8v1alpha1.CloudConfigApp{}
+	if err := k8client.Get(context.TODO(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, existing); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "alpha", existing.Spec.AppName, "the app of another owner should not be updated")
+
+	// the owner of the app still updates it
+	update := app.DeepCopy()
+	update.Spec.Label = "release"
+	assert.NoError(t, createOrUpdateApp(k8client, env, update))
+}
+
+func TestSetReadyCondition(t *testing.T) {
+	status := k8v1alpha1.CloudConfigStatus{}
+	setReadyCondition(&status, "ReconciliationFailed", errors.New("server unavailable"))
+	assert.Nil(t, status.LastSync)
+	ready := k8v1alpha1.GetCondition(status.Conditions, k8v1alpha1.ConditionReady)
+	assert.Equal(t, corev1.ConditionFalse, ready.Status)
+	assert.Equal(t, "ReconciliationFailed", ready.Reason)
+	assert.Equal(t, "server unavailable", ready.Message)
+
+	setReadyCondition(&status, "ReconciliationFailed", nil)
+	assert.NotNil(t, status.LastSync)
+	assert.True(t, k8v1alpha1.IsConditionTrue(status.Conditions, k8v1alpha1.ConditionReady))
+	assert.Len(t, status.Conditions, 1)
+
+	status.Apps = []k8v1alpha1.AppStatus{{Name: "alpha", Health: k8v1alpha1.HealthDegraded}}
+	setReadyCondition(&status, "ReconciliationFailed", nil)
+	ready = k8v1alpha1.GetCondition(status.Conditions, k8v1alpha1.ConditionReady)
+	assert.Equal(t, corev1.ConditionFalse, ready.Status, "the CloudConfig should not be ready until its apps are healthy")
+	assert.Equal(t, "Degraded", ready.Reason)
+	assert.Equal(t, "Not healthy: app alpha (Degraded)", ready.Message)
+}
+
+func TestGetObjectHealth(t *testing.T) {
+	app := &k8v1alpha1.CloudConfigApp{}
+	app.Status.Health = k8v1alpha1.HealthDegraded
+	assert.Equal(t, k8v1alpha1.HealthDegraded, getObjectHealth(app))
+
+	env := &k8v1alpha1.CloudConfigEnv{}
+	env.Status.Apps = []k8v1alpha1.AppStatus{{Name: "alpha", Health: k8v1alpha1.HealthHealthy}, {Name: "beta"}}
+	assert.Equal(t, k8v1alpha1.HealthProgressing, getObjectHealth(env))
+
+	assert.Empty(t, getObjectHealth(&k8v1alpha1.CloudConfig{}))
+}
+
+func TestGetPendingRevisions(t *testing.T) {
+	app := &k8v1alpha1.CloudConfigApp{}
+	app.Status.PendingRevision = "0123456789ab"
+	assert.Equal(t, "0123456789ab", getPendingRevisions(app))
+
+	env := &k8v1alpha1.CloudConfigEnv{}
+	env.Status.Apps = []k8v1alpha1.AppStatus{{Name: "alpha"}, {Name: "beta", PendingRevision: "0123456789ab"}}
+	assert.Equal(t, ",0123456789ab", getPendingRevisions(env))
+
+	assert.Empty(t, getPendingRevisions(&k8v1alpha1.CloudConfig{}))
 }
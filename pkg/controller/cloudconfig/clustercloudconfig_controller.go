@@ -0,0 +1,278 @@
+package cloudconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// operatorNamespaceEnvVar is the environment variable holding the namespace of the operator where the
+// CloudConfigApps and the credentials and trust store secrets of ClusterCloudConfigs are found
+const operatorNamespaceEnvVar = "OPERATOR_NAMESPACE"
+
+// AddClusterCloudConfig creates a new ClusterCloudConfig Controller and adds it to the Manager. The Manager will set
+// fields on the Controller and Start it when the Manager is Started.
+func AddClusterCloudConfig(mgr manager.Manager) error {
+	return addClusterCloudConfig(mgr, &ReconcileClusterCloudConfig{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetRecorder("clustercloudconfig-controller"),
+	})
+}
+
+// addClusterCloudConfig adds a new Controller to mgr with r as the reconcile.Reconciler
+func addClusterCloudConfig(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("clustercloudconfig-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to primary resource ClusterCloudConfig
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.ClusterCloudConfig{}}, &handler.EnqueueRequestForObject{}, specChanged)
+	if err != nil {
+		return err
+	}
+
+	// Watch for changes to secondary resource CloudConfigApp and requeue the ClusterCloudConfig, which is cluster
+	// scoped unlike its CloudConfigApps
+	err = c.Watch(&source.Kind{Type: &k8v1alpha1.CloudConfigApp{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(mapAppToClusterCloudConfig),
+	}, healthChanged)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// mapAppToClusterCloudConfig maps a CloudConfigApp to a request for its ClusterCloudConfig, if any
+func mapAppToClusterCloudConfig(obj handler.MapObject) []reconcile.Request {
+	name := obj.Meta.GetLabels()[k8v1alpha1.ClusterCloudConfigLabel]
+	if name == "" || obj.Meta.GetNamespace() != getOperatorNamespace() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
+
+var _ reconcile.Reconciler = &ReconcileClusterCloudConfig{}
+
+// ReconcileClusterCloudConfig reconciles a ClusterCloudConfig object
+type ReconcileClusterCloudConfig struct {
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+}
+
+// Reconcile resolves the apps of each environment of a ClusterCloudConfig and maintains one CloudConfigApp for each
+// app in the namespace of the operator. The apps are applied to the namespace of their environment or, if there are
+// no environments, to the target namespace or the namespaces given in the metadata of the objects.
+func (r *ReconcileClusterCloudConfig) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	start := time.Now()
+	reqLogger := log.WithValues("Request.Name", request.Name)
+	reqLogger.Info("Reconciling ClusterCloudConfig")
+
+	// Fetch the ClusterCloudConfig instance
+	c := &k8v1alpha1.ClusterCloudConfig{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, c)
+	if err != nil {
+		if k8errors.IsNotFound(err) {
+			// Owned CloudConfigApps are automatically garbage collected and delete their objects
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	if suspended, err := r.suspend(c); suspended || err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// propagate sync requests and approvals to the CloudConfigApps
+	for _, key := range []string{k8v1alpha1.SyncAnnotation, k8v1alpha1.ApproveAnnotation} {
+		if value := c.Annotations[key]; value != "" {
+			if err := annotateApps(r.client, c, newClusterCloudConfigLabels(c), key, value); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	spec := getEffectiveSpec(&c.Spec, c.Name)
+	if err = validate(spec); err != nil {
+		log.Error(err, "Validation failed")
+		if ready := k8v1alpha1.GetCondition(c.Status.Conditions, k8v1alpha1.ConditionReady); ready == nil || ready.Message != err.Error() {
+			r.recorder.Event(c, corev1.EventTypeWarning, "ValidationFailed", err.Error())
+		}
+		setReady(&c.Status.Conditions, &c.Status.LastSync, "", "", "ValidationFailed", err)
+		r.updateStatus(c)
+		// Return and don't requeue, the ClusterCloudConfig is reconciled again when its spec changes
+		return reconcile.Result{}, nil
+	}
+
+	apps, err := r.reconcileApps(c, spec)
+	if err != nil {
+		reqLogger.Error(err, "Reconciliation failed")
+	} else {
+		reqLogger.Info(fmt.Sprintf("Reconciled %d app(s) %v in %v", len(apps), getAppNames(apps), time.Since(start)))
+		c.Status.Apps = apps
+	}
+
+	next, nextErr := getNextSync(spec, start)
+	if nextErr != nil {
+		reqLogger.Error(nextErr, "Could not schedule the next reconciliation")
+	}
+	c.Status.NextSync = newTime(next)
+	health, message := getStatusHealth(c.Status.Apps, nil)
+	setReady(&c.Status.Conditions, &c.Status.LastSync, health, message, "ReconciliationFailed", err)
+	r.updateStatus(c)
+
+	if next.IsZero() {
+		reqLogger.Info("Reconciled ClusterCloudConfig; no rescheduling")
+		return reconcile.Result{}, nil
+	}
+	reqLogger.Info(fmt.Sprintf("Reconciled ClusterCloudConfig; rescheduling at %v", next))
+	return requeueAt(next), nil
+}
+
+// suspend propagates the suspension of the ClusterCloudConfig to its CloudConfigApps, or resumes them, and records
+// the Suspended condition. The result is true if the ClusterCloudConfig is suspended, in which case the status of
+// the last synchronization is kept.
+func (r *ReconcileClusterCloudConfig) suspend(c *k8v1alpha1.ClusterCloudConfig) (bool, error) {
+	reason := getSuspendReason(c, c.Spec.Suspend)
+	suspendedBy := ""
+	if reason != "" {
+		suspendedBy = getSuspendedBy("ClusterCloudConfig", c)
+	}
+	if err := annotateApps(r.client, c, newClusterCloudConfigLabels(c), k8v1alpha1.SuspendedByAnnotation, suspendedBy); err != nil {
+		return false, err
+	}
+
+	recordSuspension(r.recorder, c, &c.Status.Conditions, reason)
+	if reason == "" {
+		return false, nil
+	}
+
+	log.Info("ClusterCloudConfig is suspended; no synchronization", "Name", c.Name, "Reason", reason)
+	r.updateStatus(c)
+	return true, nil
+}
+
+// updateStatus updates the status of the ClusterCloudConfig
+func (r *ReconcileClusterCloudConfig) updateStatus(c *k8v1alpha1.ClusterCloudConfig) {
+	if err := r.client.Status().Update(context.TODO(), c); err != nil {
+		log.Error(err, "Could not update the ClusterCloudConfig status", "Name", c.Name)
+	}
+}
+
+// reconcileApps maintains one CloudConfigApp in the namespace of the operator for each app of each environment of
+// the ClusterCloudConfig. The apps of an environment are reported with the name of the environment as prefix.
+// CloudConfigApps of apps and environments that have been removed are deleted.
+func (r *ReconcileClusterCloudConfig) reconcileApps(
+	c *k8v1alpha1.ClusterCloudConfig,
+	spec *k8v1alpha1.CloudConfigSpec) ([]k8v1alpha1.AppStatus, error) {
+
+	namespace := getOperatorNamespace()
+	names := make(map[string]bool)
+	status := make([]k8v1alpha1.AppStatus, 0, 10)
+	for _, target := range getClusterTargets(spec) {
+		labels := newClusterCloudConfigLabels(c)
+		if target.env != "" {
+			labels[k8v1alpha1.EnvLabel] = target.env
+		}
+		name := getClusterAppPrefix(c.Name, target.env)
+		apps, err := reconcileApps(r.client, r.scheme, c, name, namespace, target.spec, labels)
+		if err != nil {
+			return nil, err
+		}
+		for i := range apps {
+			names[k8v1alpha1.GetCloudConfigAppName(name, apps[i].Name)] = true
+			apps[i].Name = getClusterAppName(target.env, apps[i].Name)
+			apps[i].Namespace = target.spec.TargetNamespace
+		}
+		status = append(status, apps...)
+	}
+
+	// Delete the CloudConfigApps of removed environments
+	if err := deleteRemovedApps(r.client, c, newClusterCloudConfigLabels(c), names); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// clusterTarget is the effective spec of an environment of a ClusterCloudConfig
+type clusterTarget struct {
+	env  string
+	spec *k8v1alpha1.CloudConfigSpec
+}
+
+// getClusterTargets returns the environments of the spec in alphabetical order or, if there are no
+// environments, a single target for the spec. The target namespace of an environment is the namespace of the
+// environment, defaulting to the target namespace of the spec.
+func getClusterTargets(spec *k8v1alpha1.CloudConfigSpec) []clusterTarget {
+	if len(spec.Environments) == 0 {
+		return []clusterTarget{{spec: spec}}
+	}
+
+	keys := make([]string, 0, len(spec.Environments))
+	for key := range spec.Environments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	targets := make([]clusterTarget, 0, len(keys))
+	for _, key := range keys {
+		env := spec.Environments[key]
+		target := clusterTarget{env: key, spec: spec.GetEnvironmentSpec(env)}
+		if env.Namespace != "" {
+			target.spec.TargetNamespace = env.Namespace
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// newClusterCloudConfigLabels returns the labels of the CloudConfigApps of a ClusterCloudConfig
+func newClusterCloudConfigLabels(c *k8v1alpha1.ClusterCloudConfig) map[string]string {
+	return map[string]string{k8v1alpha1.ClusterCloudConfigLabel: c.Name}
+}
+
+// getClusterAppPrefix returns the prefix of the names of the CloudConfigApps of the environment of the named
+// ClusterCloudConfig, if any. The `cluster-` prefix distinguishes them from the CloudConfigApps of the CloudConfigs
+// in the namespace of the operator.
+func getClusterAppPrefix(name, env string) string {
+	prefix := "cluster-" + name
+	if env == "" {
+		return prefix
+	}
+	return k8v1alpha1.GetCloudConfigEnvName(prefix, env)
+}
+
+// getClusterAppName returns the name of the app qualified by the environment, if any
+func getClusterAppName(env, app string) string {
+	if env == "" {
+		return app
+	}
+	return env + "/" + app
+}
+
+// getOperatorNamespace returns the namespace of the operator, defaults to 'default'
+func getOperatorNamespace() string {
+	namespace := os.Getenv(operatorNamespaceEnvVar)
+	fallBackIfEmpty(&namespace, "default")
+	return namespace
+}
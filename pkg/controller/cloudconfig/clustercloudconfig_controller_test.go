@@ -0,0 +1,108 @@
+package cloudconfig
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	k8v1alpha1 "github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestGetClusterTargets(t *testing.T) {
+	spec := k8v1alpha1.NewCloudConfigSpec()
+	spec.Profile = []string{"us-west"}
+
+	targets := getClusterTargets(spec)
+	assert.Equal(t, []clusterTarget{{spec: spec}}, targets, "a spec without environments should have a single target")
+
+	spec.TargetNamespace = "platform"
+	spec.Environments = map[string]k8v1alpha1.EnvironmentSpec{
+		"prd": {Profile: []string{"prd"}},
+		"dev": {Profile: []string{"dev"}, Label: "develop", Namespace: "dev"},
+	}
+	targets = getClusterTargets(spec)
+	assert.Len(t, targets, 2)
+	assert.Equal(t, "dev", targets[0].env, "environments should be ordered alphabetically")
+	assert.Equal(t, "dev", targets[0].spec.TargetNamespace)
+	assert.Equal(t, "develop", targets[0].spec.Label)
+	assert.Equal(t, []string{"us-west", "dev"}, targets[0].spec.Profile)
+	assert.Empty(t, targets[0].spec.Environments)
+	assert.Equal(t, "prd", targets[1].env)
+	assert.Equal(t, "platform", targets[1].spec.TargetNamespace, "the namespace should default to the target namespace")
+	assert.Equal(t, "master", targets[1].spec.Label)
+	assert.Equal(t, "platform", spec.TargetNamespace)
+}
+
+func TestClusterAppRollback(t *testing.T) {
+	spec := k8v1alpha1.NewCloudConfigSpec()
+	spec.Rollback = &k8v1alpha1.RollbackSpec{ProgressDeadline: &metav1.Duration{Duration: 5 * time.Minute}}
+	spec.Environments = map[string]k8v1alpha1.EnvironmentSpec{"dev": {Namespace: "dev"}}
+
+	target := getClusterTargets(spec)[0]
+	app := newCloudConfigApp(getClusterAppPrefix("platform", target.env), "operators",
+		target.spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "quotas"}), nil)
+	assert.Equal(t, spec.Rollback, app.Spec.Rollback, "the apps should be rolled back by their CloudConfigApps")
+}
+
+func TestClusterAppHistory(t *testing.T) {
+	spec := k8v1alpha1.NewCloudConfigSpec()
+	spec.RevisionHistoryLimit = 5
+	spec.PinnedRevision = "3f2a9c61b0de"
+	override := k8v1alpha1.AppSpec{PinnedRevision: "8e41c07d5a92"}
+
+	target := getClusterTargets(spec)[0]
+	quotas := newCloudConfigApp(getClusterAppPrefix("platform", target.env), "operators",
+		target.spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "quotas"}.Merge(override)), nil)
+	crds := newCloudConfigApp(getClusterAppPrefix("platform", target.env), "operators",
+		target.spec.GetAppSpec(k8v1alpha1.AppSpec{Name: "crds"}), nil)
+	assert.Equal(t, 5, quotas.Spec.RevisionHistoryLimit, "the history should be kept by the CloudConfigApps")
+	assert.Equal(t, "8e41c07d5a92", quotas.Spec.PinnedRevision, "the pinned revision of the app should take precedence")
+	assert.Equal(t, "3f2a9c61b0de", crds.Spec.PinnedRevision)
+}
+
+func TestGetClusterAppPrefix(t *testing.T) {
+	assert.Equal(t, "cluster-platform", getClusterAppPrefix("platform", ""))
+	assert.Equal(t, "cluster-platform-dev", getClusterAppPrefix("platform", "dev"))
+	assert.Equal(t, "cluster-platform-dev-quotas", k8v1alpha1.GetCloudConfigAppName(getClusterAppPrefix("platform", "dev"), "quotas"))
+}
+
+func TestGetClusterAppName(t *testing.T) {
+	assert.Equal(t, "quotas", getClusterAppName("", "quotas"))
+	assert.Equal(t, "dev/quotas", getClusterAppName("dev", "quotas"))
+}
+
+func TestMapAppToClusterCloudConfig(t *testing.T) {
+	defer os.Unsetenv(operatorNamespaceEnvVar)
+	os.Setenv(operatorNamespaceEnvVar, "operators")
+
+	c := &k8v1alpha1.ClusterCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "platform"}}
+	app := newCloudConfigApp(getClusterAppPrefix(c.Name, ""), "operators", &k8v1alpha1.CloudConfigSpec{AppName: "quotas"},
+		newClusterCloudConfigLabels(c))
+	assert.Equal(t, "cluster-platform-quotas", app.Name)
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "platform"}},
+	}, mapAppToClusterCloudConfig(handler.MapObject{Meta: app, Object: app}))
+
+	app.Namespace = "team"
+	assert.Empty(t, mapAppToClusterCloudConfig(handler.MapObject{Meta: app, Object: app}),
+		"only apps in the operator namespace should belong to ClusterCloudConfigs")
+
+	app.Namespace = "operators"
+	app.Labels = nil
+	assert.Empty(t, mapAppToClusterCloudConfig(handler.MapObject{Meta: app, Object: app}))
+}
+
+func TestGetOperatorNamespace(t *testing.T) {
+	defer os.Unsetenv(operatorNamespaceEnvVar)
+
+	os.Unsetenv(operatorNamespaceEnvVar)
+	assert.Equal(t, "default", getOperatorNamespace())
+
+	os.Setenv(operatorNamespaceEnvVar, "operators")
+	assert.Equal(t, "operators", getOperatorNamespace())
+}
@@ -0,0 +1,251 @@
+// Package cron parses standard five field cron expressions, e.g. `0 8 * * MON-FRI`, and computes the times
+// they activate.
+//
+// The fields are minute (0-59), hour (0-23), day of month (1-31), month (1-12 or JAN-DEC) and day of week (0-6
+// or SUN-SAT, 7 is also Sunday). Each field is a comma separated list of `*`, values and ranges `a-b`,
+// optionally followed by a step `/n`. If both the day of month and the day of week are restricted, i.e. not
+// `*`, the schedule activates on days matching either field. The descriptors @yearly, @annually, @monthly,
+// @weekly, @daily, @midnight and @hourly are supported, as is a `CRON_TZ=<zone>` or `TZ=<zone>` prefix setting
+// the time zone of the schedule.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are true if the day of month or day of week fields are unrestricted
+	domStar, dowStar bool
+	// location of the schedule, nil for the location of the times given to Next
+	location *time.Location
+}
+
+// field defines the bounds and names of a cron field
+type field struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses the cron expression
+func Parse(expr string) (*Schedule, error) {
+	s := &Schedule{}
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		i := strings.IndexAny(expr, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("missing fields after the time zone of '%s'", expr)
+		}
+		zone := expr[strings.Index(expr, "=")+1 : i]
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone '%s': %s", zone, err.Error())
+		}
+		s.location = loc
+		expr = strings.TrimSpace(expr[i:])
+	}
+
+	if strings.HasPrefix(expr, "@") {
+		d, ok := descriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown descriptor '%s'", expr)
+		}
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in '%s', found %d", expr, len(fields))
+	}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, err
+	}
+	// 7 is an alias for Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// ParseInLocation parses the cron expression of a schedule in the location, a time zone prefix of the expression
+// takes precedence over the location
+func ParseInLocation(expr string, loc *time.Location) (*Schedule, error) {
+	s, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	if s.location == nil {
+		s.location = loc
+	}
+	return s, nil
+}
+
+// parseField returns the bit set of the values matched by the comma separated list of a cron field
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		b, err := parseRange(part, f)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+// parseRange returns the bit set of the values matched by `*`, a value or a range with an optional step
+func parseRange(value string, f field) (uint64, error) {
+	expr, step := value, uint(1)
+	if i := strings.Index(value, "/"); i >= 0 {
+		n, err := strconv.ParseUint(value[i+1:], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step '%s' of the %s field", value[i+1:], f.name)
+		}
+		expr, step = value[:i], uint(n)
+	}
+
+	var start, end uint
+	switch {
+	case expr == "*":
+		start, end = f.min, f.max
+		if f.name == dowField.name {
+			end = 6
+		}
+	case strings.Contains(expr, "-"):
+		i := strings.Index(expr, "-")
+		var err error
+		if start, err = parseValue(expr[:i], f); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(expr[i+1:], f); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range '%s' of the %s field", expr, f.name)
+		}
+	default:
+		var err error
+		if start, err = parseValue(expr, f); err != nil {
+			return 0, err
+		}
+		end = start
+		// a single value with a step, e.g. `5/15`, runs from the value to the end of the range
+		if step > 1 {
+			end = f.max
+		}
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << v
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or name of a cron field
+func parseValue(value string, f field) (uint, error) {
+	if n, ok := f.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 8)
+	if err != nil || uint(n) < f.min || uint(n) > f.max {
+		return 0, fmt.Errorf("invalid value '%s' of the %s field, expected %d-%d", value, f.name, f.min, f.max)
+	}
+	return uint(n), nil
+}
+
+// maxYears is the number of years searched for the next activation, the schedule never activates if there
+// is no activation within that time, e.g. for `0 0 30 2 *`
+const maxYears = 5
+
+// Next returns the first activation of the schedule strictly after t, or the zero time if the schedule never
+// activates. The result is in the location of t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	if s.location != nil {
+		t = t.In(s.location)
+	}
+
+	// start at the next whole minute
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + maxYears
+
+	for t.Year() <= limit {
+		if !has(s.month, uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.hour, uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !has(s.minute, uint(t.Minute())) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.In(loc)
+	}
+	return time.Time{}
+}
+
+// matchesDay returns true if the day of t matches the day of month and day of week fields
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := has(s.dom, uint(t.Day()))
+	dow := has(s.dow, uint(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, v uint) bool {
+	return bits&(1<<v) != 0
+}
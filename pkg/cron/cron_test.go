@@ -0,0 +1,92 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, expr string) *Schedule {
+	s, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("could not parse '%s': %s", expr, err.Error())
+	}
+	return s
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"@fortnightly",
+		"CRON_TZ=Mars/Olympus 0 * * * *",
+		"TZ=UTC",
+	} {
+		_, err := Parse(expr)
+		assert.Error(t, err, "'%s' should be invalid", expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Wednesday
+	now := time.Date(2019, 5, 15, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2019, 5, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, 5, 15, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2019, 5, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2019, 5, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 8-17 * * MON-FRI", time.Date(2019, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2019, 5, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2019, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2019, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2019, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.next, mustParse(t, test.expr).Next(now), test.expr)
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	now := time.Date(2019, 5, 15, 10, 30, 0, 0, time.UTC)
+	next := mustParse(t, "CRON_TZ=Europe/Stockholm 0 8 * * *").Next(now)
+	assert.Equal(t, time.Date(2019, 5, 16, 8, 0, 0, 0, stockholm).UTC(), next.UTC())
+	assert.Equal(t, time.UTC, next.Location(), "the result should be in the location of the given time")
+}
+
+func TestParseInLocation(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	now := time.Date(2019, 5, 15, 10, 30, 0, 0, time.UTC)
+	s, err := ParseInLocation("0 8 * * *", stockholm)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2019, 5, 16, 8, 0, 0, 0, stockholm).UTC(), s.Next(now).UTC())
+
+	s, err = ParseInLocation("TZ=UTC 0 8 * * *", stockholm)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2019, 5, 16, 8, 0, 0, 0, time.UTC), s.Next(now), "the prefix should take precedence")
+}
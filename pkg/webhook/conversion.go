@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var log = logf.Log.WithName("webhook")
+
+// ConversionReview describes a conversion request/response, it mirrors the apiextensions.k8s.io/v1beta1
+// ConversionReview sent by the API server to CRD conversion webhooks
+type ConversionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	// Request describes the attributes for the conversion request
+	Request *ConversionRequest `json:"request,omitempty"`
+	// Response describes the attributes for the conversion response
+	Response *ConversionResponse `json:"response,omitempty"`
+}
+
+// ConversionRequest describes the conversion request parameters
+type ConversionRequest struct {
+	// UID is an identifier for the individual request/response
+	UID types.UID `json:"uid"`
+	// DesiredAPIVersion is the version to convert given objects to, e.g. "k8s.jabberwocky.se/v1alpha2"
+	DesiredAPIVersion string `json:"desiredAPIVersion"`
+	// Objects is the list of CR objects to be converted
+	Objects []runtime.RawExtension `json:"objects"`
+}
+
+// ConversionResponse describes a conversion response
+type ConversionResponse struct {
+	// UID is an identifier for the individual request/response, copied from the request
+	UID types.UID `json:"uid"`
+	// ConvertedObjects is the list of converted objects in the order of the request objects
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	// Result contains the result of conversion with extra details if the conversion failed
+	Result metav1.Status `json:"result"`
+}
+
+// ConversionHandler is the http.Handler of the CRD conversion webhook converting CloudConfigs between the
+// v1alpha1 and v1alpha2 versions
+type ConversionHandler struct{}
+
+// ServeHTTP converts the objects of the ConversionReview request to the desired API version
+func (h ConversionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := ConversionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "could not parse the ConversionReview request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convertReview(review.Request)
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error(err, "Could not write the ConversionReview response")
+	}
+}
+
+// convertReview converts all objects of the request, the conversion fails if a single object fails
+func convertReview(request *ConversionRequest) *ConversionResponse {
+	response := &ConversionResponse{
+		UID:              request.UID,
+		ConvertedObjects: make([]runtime.RawExtension, 0, len(request.Objects)),
+	}
+
+	for _, obj := range request.Objects {
+		converted, err := convert(obj.Raw, request.DesiredAPIVersion)
+		if err != nil {
+			log.Error(err, "Conversion failed", "desiredAPIVersion", request.DesiredAPIVersion)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+// convert converts the JSON of a CloudConfig to the desired API version
+func convert(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.Kind != "CloudConfig" {
+		return nil, fmt.Errorf("unsupported kind '%s'", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	switch {
+	case typeMeta.APIVersion == v1alpha1.SchemeGroupVersion.String() &&
+		desiredAPIVersion == v1alpha2.SchemeGroupVersion.String():
+		src := &v1alpha1.CloudConfig{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &v1alpha2.CloudConfig{}
+		if err := src.ConvertTo(dst); err != nil {
+			return nil, err
+		}
+		return json.Marshal(dst)
+
+	case typeMeta.APIVersion == v1alpha2.SchemeGroupVersion.String() &&
+		desiredAPIVersion == v1alpha1.SchemeGroupVersion.String():
+		src := &v1alpha2.CloudConfig{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &v1alpha1.CloudConfig{}
+		if err := dst.ConvertFrom(src); err != nil {
+			return nil, err
+		}
+		return json.Marshal(dst)
+	}
+
+	return nil, fmt.Errorf("unsupported conversion from '%s' to '%s'", typeMeta.APIVersion, desiredAPIVersion)
+}
@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha1"
+	"github.com/chrsoo/cloud-config-operator/pkg/apis/k8s/v1alpha2"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testCloudConfig = `{
+	"apiVersion": "k8s.jabberwocky.se/v1alpha1",
+	"kind": "CloudConfig",
+	"metadata": { "name": "test" },
+	"spec": { "server": "https://config", "profile": [ "prd" ], "period": 10 },
+	"status": { "namsepaceStatus": { "status": "Success" } }
+}`
+
+func TestConversionHandler(t *testing.T) {
+	review := ConversionReview{Request: &ConversionRequest{
+		UID:               "1234",
+		DesiredAPIVersion: "k8s.jabberwocky.se/v1alpha2",
+		Objects:           []runtime.RawExtension{{Raw: []byte(testCloudConfig)}},
+	}}
+	body, _ := json.Marshal(review)
+
+	rec := httptest.NewRecorder()
+	ConversionHandler{}.ServeHTTP(rec, httptest.NewRequest("POST", ConversionPath, bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	response := ConversionReview{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Nil(t, response.Request)
+	assert.Equal(t, "1234", string(response.Response.UID))
+	assert.Equal(t, metav1.StatusSuccess, response.Response.Result.Status)
+	assert.Len(t, response.Response.ConvertedObjects, 1)
+
+	c := v1alpha2.CloudConfig{}
+	assert.NoError(t, json.Unmarshal(response.Response.ConvertedObjects[0].Raw, &c))
+	assert.Equal(t, "k8s.jabberwocky.se/v1alpha2", c.APIVersion)
+	assert.Equal(t, []string{"https://config"}, c.Spec.Servers)
+	assert.Equal(t, []string{"prd"}, c.Spec.Profiles)
+	assert.Equal(t, 10*time.Second, c.Spec.Interval.Duration)
+	assert.Equal(t, metav1.StatusSuccess, c.Status.NamespaceStatus.Status)
+}
+
+func TestConversionHandlerBadRequest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ConversionHandler{}.ServeHTTP(rec, httptest.NewRequest("POST", ConversionPath, bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestConvert(t *testing.T) {
+	v2, err := convert([]byte(testCloudConfig), "k8s.jabberwocky.se/v1alpha2")
+	assert.NoError(t, err)
+
+	v1, err := convert(v2, "k8s.jabberwocky.se/v1alpha1")
+	assert.NoError(t, err)
+	c := v1alpha1.CloudConfig{}
+	assert.NoError(t, json.Unmarshal(v1, &c))
+	assert.Equal(t, "k8s.jabberwocky.se/v1alpha1", c.APIVersion)
+	assert.Equal(t, "https://config", c.Spec.Server)
+	assert.Equal(t, []string{"prd"}, c.Spec.Profile)
+	assert.Equal(t, 10, c.Spec.Period)
+	assert.Equal(t, metav1.StatusSuccess, c.Status.NamespaceStatus.Status)
+	assert.Empty(t, c.Annotations, "a lossless conversion should not add the conversion annotation")
+
+	same, err := convert(v1, "k8s.jabberwocky.se/v1alpha1")
+	assert.NoError(t, err)
+	assert.Equal(t, v1, same, "objects in the desired version should not be converted")
+
+	_, err = convert([]byte(`{"apiVersion": "k8s.jabberwocky.se/v1alpha1", "kind": "CloudConfigApp"}`), "k8s.jabberwocky.se/v1alpha2")
+	assert.Error(t, err, "only CloudConfigs should be converted")
+
+	_, err = convert([]byte(testCloudConfig), "k8s.jabberwocky.se/v1beta1")
+	assert.Error(t, err, "unknown versions should not be converted")
+}
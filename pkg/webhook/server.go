@@ -0,0 +1,24 @@
+package webhook
+
+import (
+	"net/http"
+	"path/filepath"
+)
+
+const (
+	// ConversionPath is the URL path of the CRD conversion webhook
+	ConversionPath = "/convert"
+	// DefaultPort is the default port of the webhook server
+	DefaultPort = ":9443"
+)
+
+// Serve serves the webhooks over TLS on the address using the tls.crt and tls.key files of the certificate
+// directory. Serve blocks until the server fails.
+func Serve(addr, certDir string) error {
+	mux := http.NewServeMux()
+	mux.Handle(ConversionPath, ConversionHandler{})
+
+	log.Info("Starting the webhook server", "address", addr, "certDir", certDir)
+	server := &http.Server{Addr: addr, Handler: mux}
+	return server.ListenAndServeTLS(filepath.Join(certDir, "tls.crt"), filepath.Join(certDir, "tls.key"))
+}